@@ -1,21 +1,34 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/openshift/bpfman-catalog/pkg/analysis"
 	"github.com/openshift/bpfman-catalog/pkg/bundle"
+	"github.com/openshift/bpfman-catalog/pkg/cachedir"
+	"github.com/openshift/bpfman-catalog/pkg/catalog"
 	"github.com/openshift/bpfman-catalog/pkg/manifests"
+	"github.com/openshift/bpfman-catalog/pkg/provenance"
+	"github.com/openshift/bpfman-catalog/pkg/pullrequest"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
+	"github.com/openshift/bpfman-catalog/pkg/serve"
 	"github.com/openshift/bpfman-catalog/pkg/writer"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"sigs.k8s.io/yaml"
 )
 
 // Default output directories
@@ -26,54 +39,335 @@ const (
 
 // GlobalContext contains global dependencies injected into commands.
 type GlobalContext struct {
-	Context context.Context
-	Logger  *slog.Logger
+	Context     context.Context
+	Logger      *slog.Logger
+	Environment bundle.Environment
+	CacheDir    string   // Cache directory ("" disables caching)
+	Secrets     []string // Credentials in effect, kept out of provenance
+
+	// PullRequests returns the pull request resolver selected on the
+	// command line, or nil if pull requests are not to be resolved.
+	// Only commands that show pull requests call it, so that the
+	// pull request cache is not read otherwise.
+	PullRequests func() (*pullrequest.Resolver, error)
+}
+
+// withPullRequests returns the command context carrying the pull
+// request resolver selected on the command line, if any.
+func (g *GlobalContext) withPullRequests() (context.Context, error) {
+	if g.PullRequests == nil {
+		return g.Context, nil
+	}
+	resolver, err := g.PullRequests()
+	if err != nil || resolver == nil {
+		return g.Context, err
+	}
+	return pullrequest.WithResolver(g.Context, resolver), nil
 }
 
 // CLI defines the command-line interface structure.
 type CLI struct {
-	PrepareCatalogBuildFromBundle     PrepareCatalogBuildFromBundleCmd     `cmd:"prepare-catalog-build-from-bundle" help:"Prepare catalog build artefacts from a bundle image"`
+	PrepareCatalogBuildFromBundle     PrepareCatalogBuildFromBundleCmd     `cmd:"prepare-catalog-build-from-bundle" help:"Prepare catalog build artefacts from one or more bundle images"`
 	PrepareCatalogBuildFromYAML       PrepareCatalogBuildFromYAMLCmd       `cmd:"prepare-catalog-build-from-yaml" help:"Prepare catalog build artefacts from an existing catalog.yaml file"`
 	PrepareCatalogDeploymentFromImage PrepareCatalogDeploymentFromImageCmd `cmd:"prepare-catalog-deployment-from-image" help:"Prepare deployment manifests from existing catalog image"`
+	BuildCatalogImage                 BuildCatalogImageCmd                 `cmd:"build-catalog-image" help:"Build a catalog image from a rendered catalog and push it, without a container engine"`
+	RebuildBundle                     RebuildBundleCmd                     `cmd:"rebuild-bundle" help:"Rebuild a bundle image with overridden operator, daemon and agent images and push it"`
+	VerifyArtefacts                   VerifyArtefactsCmd                   `cmd:"verify-artefacts" help:"Check an artefacts directory against the artefacts.json written when it was prepared"`
+	DumpTemplates                     DumpTemplatesCmd                     `cmd:"dump-templates" help:"Write the default Makefile and WORKFLOW.txt templates as a starting point for --templates-dir"`
 	BundleInfo                        BundleInfoCmd                        `cmd:"bundle-info" help:"Show bundle contents and dependencies"`
+	CatalogInfo                       CatalogInfoCmd                       `cmd:"catalog-info" help:"Analyse every bundle in a catalog"`
+	Catalog                           CatalogCmd                           `cmd:"catalog" help:"Inspect the contents of a catalog image, rendered catalog or template"`
+	Serve                             ServeCmd                             `cmd:"serve" help:"Serve a catalog over the registry gRPC API on a local port, as opm serve does"`
+	QueryServed                       QueryServedCmd                       `cmd:"query-served" help:"Query a catalog served over the registry gRPC API"`
 	ListBundles                       ListBundlesCmd                       `cmd:"list-bundles" help:"List available bundle images"`
+	FindBundles                       FindBundlesCmd                       `cmd:"find-bundles" help:"Find the bundle builds that reference an image digest"`
+	WatchBundles                      WatchBundlesCmd                      `cmd:"watch-bundles" help:"Poll bundle repositories and report new builds as they appear"`
 
 	// Global flags
 	LogLevel  string `env:"LOG_LEVEL" default:"info" help:"Log level (debug, info, warn, error)"`
 	LogFormat string `env:"LOG_FORMAT" default:"text" help:"Log format (text, json)"`
+
+	// Registry access flags
+	AuthFile       string `name:"authfile" type:"path" env:"BPFMAN_CATALOG_AUTHFILE,REGISTRY_AUTH_FILE" help:"Path to registry auth file (containers-auth.json format; not supported with --container-tool=docker)"`
+	Creds          string `env:"BPFMAN_CATALOG_CREDS" help:"Registry credentials as username[:password] (not supported with --container-tool=docker)"`
+	RegistriesConf string `type:"path" env:"BPFMAN_CATALOG_REGISTRIES_CONF,CONTAINERS_REGISTRIES_CONF" help:"Path to registries.conf file"`
+	TLSVerify      bool   `name:"tls-verify" env:"BPFMAN_CATALOG_TLS_VERIFY" default:"true" negatable:"" help:"Require HTTPS and verify certificates when accessing registries (--no-tls-verify is not supported with --container-tool=docker)"`
+	ContainerTool  string `env:"BPFMAN_CATALOG_CONTAINER_TOOL" default:"none" enum:"none,podman,docker" help:"Container tool used to pull and unpack images (none uses the built-in puller)"`
+
+	// Registry retry flags
+	RetryAttempts   int           `name:"retry-attempts" env:"BPFMAN_CATALOG_RETRY_ATTEMPTS" default:"4" help:"Attempts per registry operation before giving up on rate limiting or transient failures (1 disables retries)"`
+	RetryBackoff    time.Duration `name:"retry-backoff" env:"BPFMAN_CATALOG_RETRY_BACKOFF" default:"1s" help:"Delay before the first retry, doubled on each further attempt"`
+	RetryMaxBackoff time.Duration `name:"retry-max-backoff" env:"BPFMAN_CATALOG_RETRY_MAX_BACKOFF" default:"30s" help:"Upper bound on the delay between retries"`
+
+	// Reproducible output flags
+	Reproducible    bool   `env:"BPFMAN_CATALOG_REPRODUCIBLE" help:"Generate byte-identical artefacts from the same inputs: seeded ttl.sh names, fixed timestamps and bpfman-catalog run from PATH"`
+	Seed            *int64 `env:"BPFMAN_CATALOG_SEED" help:"Seed for the ttl.sh names in reproducible artefacts; implies --reproducible (default: --source-date-epoch, or 0)"`
+	SourceDateEpoch *int64 `name:"source-date-epoch" env:"SOURCE_DATE_EPOCH" help:"Unix time recorded in reproducible artefacts and images; implies --reproducible (default: 0)"`
+	QuayUser        string `name:"quay-user" env:"BPFMAN_CATALOG_QUAY_USER" help:"User in the quay.io image examples of generated artefacts (default: $USER, or $(USER) for make to expand with --reproducible)"`
+
+	// Cache flags
+	CacheDir string `name:"cache-dir" env:"BPFMAN_CATALOG_CACHE_DIR" default:"${default_cache_dir}" help:"Directory for cached bundle metadata, bundle image references, pull requests and watch state (empty disables caching)"`
+
+	// Pull request resolution flags
+	PRProvider  string `name:"pr-provider" env:"BPFMAN_CATALOG_PR_PROVIDER" default:"file" enum:"github,file,none" help:"How on-pr-<commit> tags are resolved to pull requests: file (--pr-cache only, no network), github (GitHub API, cached in --pr-cache) or none"`
+	PRCache     string `name:"pr-cache" type:"path" env:"BPFMAN_CATALOG_PR_CACHE" help:"Pull request cache file (default: pull-requests.json under --cache-dir)"`
+	PRRepo      string `name:"pr-repo" env:"BPFMAN_CATALOG_PR_REPO" default:"${default_pr_repo}" help:"GitHub repository (owner/name) bundles are built from"`
+	GitHubURL   string `name:"github-url" env:"GITHUB_API_URL" default:"${default_github_url}" help:"GitHub API URL"`
+	GitHubToken string `name:"github-token" env:"GITHUB_TOKEN,GH_TOKEN" help:"GitHub token for pull request lookups (raises the API rate limit)"`
+}
+
+// registryOptions returns the registry access options selected on
+// the command line.
+func (c *CLI) registryOptions() registry.Options {
+	return registry.Options{
+		AuthFile:       c.AuthFile,
+		Creds:          c.Creds,
+		RegistriesConf: c.RegistriesConf,
+		TLSVerify:      c.TLSVerify,
+		ContainerTool:  c.ContainerTool,
+		Retry: registry.RetryPolicy{
+			MaxAttempts:    c.RetryAttempts,
+			InitialBackoff: c.RetryBackoff,
+			MaxBackoff:     c.RetryMaxBackoff,
+			Jitter:         registry.DefaultRetryPolicy().Jitter,
+		},
+	}
+}
+
+// secrets returns the credentials in effect, whether given as flags,
+// in the environment or in the configuration file, so that they can
+// be redacted wherever they appear in a recorded command line.
+func (c *CLI) secrets() []string {
+	secrets := []string{c.Creds, c.GitHubToken}
+	if _, password, ok := strings.Cut(c.Creds, ":"); ok {
+		secrets = append(secrets, password)
+	}
+	return secrets
 }
 
-// PrepareCatalogBuildFromBundleCmd prepares catalog build artefacts from a bundle image.
+// environment returns the run-dependent values of generated
+// artefacts: those of this run, or fixed ones derived from --seed and
+// --source-date-epoch when output is to be reproducible.
+func (c *CLI) environment() bundle.Environment {
+	var env bundle.Environment
+	if !c.Reproducible && c.Seed == nil && c.SourceDateEpoch == nil {
+		env = bundle.CurrentEnvironment()
+	} else {
+		var epoch int64
+		if c.SourceDateEpoch != nil {
+			epoch = *c.SourceDateEpoch
+		}
+		seed := epoch
+		if c.Seed != nil {
+			seed = *c.Seed
+		}
+		env = bundle.ReproducibleEnvironment(seed, time.Unix(epoch, 0))
+	}
+	if c.QuayUser != "" {
+		env.Username = c.QuayUser
+	}
+	return env
+}
+
+// prResolver returns the pull request resolver selected on the
+// command line, or nil if pull requests are not to be resolved.
+func (c *CLI) prResolver() (*pullrequest.Resolver, error) {
+	if c.PRProvider == "none" {
+		return nil, nil
+	}
+
+	cachePath := c.PRCache
+	if cachePath == "" {
+		cachePath = cachedir.Path(c.CacheDir, cachedir.PullRequests)
+	}
+	file, err := pullrequest.LoadFile(cachePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var provider pullrequest.Provider = file
+	if c.PRProvider == "github" {
+		provider = pullrequest.Cached(file, pullrequest.NewGitHub(c.GitHubURL, c.GitHubToken))
+	}
+	return &pullrequest.Resolver{Provider: provider, Repository: c.PRRepo}, nil
+}
+
+// PrepareCatalogBuildFromBundleCmd prepares catalog build artefacts from bundle images.
 type PrepareCatalogBuildFromBundleCmd struct {
-	BundleImage   string `arg:"" required:"" help:"Bundle image reference"`
-	OutputDir     string `default:"${default_artefacts_dir}" help:"Output directory for generated artefacts"`
-	OpmBin        string `type:"path" help:"Path to opm binary for external rendering (uses library by default)"`
-	OperatorImage string `help:"Override operator image (generates post-install patch)"`
+	BundleImages  []string `arg:"" required:"" help:"Bundle image references (registry, oci:, oci-archive:, docker-archive: or dir:); several bundles form an upgrade chain ordered by CSV version"`
+	OutputDir     string   `default:"${default_artefacts_dir}" help:"Output directory for generated artefacts"`
+	OpmBin        string   `type:"path" help:"Path to opm binary for external rendering (uses library by default)"`
+	OperatorImage string   `help:"Override operator image, baked into a rebuilt bundle with --bundle-destination or patched in after install otherwise"`
+	DaemonImage   string   `help:"Override daemon image (bpfman.image in bpfman-config, bpfman-operator only); needs --bundle-destination"`
+	AgentImage    string   `help:"Override agent image (bpfman.agent.image in bpfman-config, bpfman-operator only); needs --bundle-destination"`
+
+	Package string `help:"Package the bundles must belong to (default: that of the bundles)"`
+
+	BundleDestination string `name:"bundle-destination" help:"Registry reference to push the newest bundle rebuilt with the image overrides to; the catalog uses it pinned by digest"`
+
+	Channels       []string `name:"channel" help:"Channel to add the bundles to; repeat to share them between channels (default: preview, or the released template's default channel)"`
+	DefaultChannel string   `help:"Package default channel (default: the first channel, or unchanged with --upgrade-from-released)"`
+	Skips          []string `help:"Bundle names the newest bundle skips; repeatable"`
+	SkipRange      string   `help:"Version range the newest bundle skips, e.g. '>=0.5.0 <0.6.0'"`
+
+	UpgradeFromReleased bool   `name:"upgrade-from-released" help:"Add the bundles after the head of the released template's channels and generate upgrade test targets"`
+	ReleasedTemplate    string `name:"released-template" help:"Basic catalog template of released bundles used by --upgrade-from-released, e.g. templates/released.yaml in this repository"`
+
+	OCPVersions []string `name:"ocp-version" help:"OpenShift version to build the catalog for, selecting the opm base image and migrate level; repeat for per-version Dockerfiles and Makefile targets (default: ${default_ocp_version})"`
+	BaseImage   string   `help:"opm base image for the catalog image, overriding the one mapped from --ocp-version"`
+
+	BuildVersion string `name:"build-version" help:"Version label of the catalog image (default: the newest bundle's version)"`
+	Commit       string `help:"Commit recorded in the catalog image's upstream-vcs-ref label"`
+
+	TemplatesDir string `name:"templates-dir" type:"path" env:"BPFMAN_CATALOG_TEMPLATES_DIR" help:"Directory of Makefile.tmpl and WORKFLOW.txt.tmpl overriding the embedded templates (see dump-templates)"`
 }
 
 // PrepareCatalogBuildFromYAMLCmd prepares catalog build artefacts from existing catalog.yaml.
 type PrepareCatalogBuildFromYAMLCmd struct {
 	CatalogYAML string `arg:"" type:"path" required:"" help:"Path to existing catalog.yaml file"`
 	OutputDir   string `default:"${default_artefacts_dir}" help:"Output directory for generated artefacts"`
+	Package     string `help:"Package to install from the catalog; required if it has several (default: its only package)"`
+
+	OCPVersions []string `name:"ocp-version" help:"OpenShift version to build the catalog for, selecting the opm base image; repeat for per-version Dockerfiles and Makefile targets (default: ${default_ocp_version})"`
+	BaseImage   string   `help:"opm base image for the catalog image, overriding the one mapped from --ocp-version"`
+
+	BuildVersion string `name:"build-version" help:"Version label of the catalog image"`
+	Commit       string `help:"Commit recorded in the catalog image's upstream-vcs-ref label"`
+
+	TemplatesDir string `name:"templates-dir" type:"path" env:"BPFMAN_CATALOG_TEMPLATES_DIR" help:"Directory of Makefile.tmpl and WORKFLOW.txt.tmpl overriding the embedded templates (see dump-templates)"`
+}
+
+// VerifyArtefactsCmd checks an artefacts directory for changes.
+type VerifyArtefactsCmd struct {
+	Dir    string `arg:"" optional:"" type:"path" default:"${default_artefacts_dir}" help:"Artefacts or manifests directory containing artefacts.json"`
+	Format string `default:"text" enum:"text,json" help:"Output format (text, json)"`
+}
+
+// DumpTemplatesCmd writes the embedded artefact templates.
+type DumpTemplatesCmd struct {
+	Dir       string `arg:"" type:"path" help:"Directory to write Makefile.tmpl and WORKFLOW.txt.tmpl into"`
+	Overwrite bool   `help:"Replace templates already in the directory"`
 }
 
 // PrepareCatalogDeploymentFromImageCmd prepares deployment manifests from catalog image.
 type PrepareCatalogDeploymentFromImageCmd struct {
-	CatalogImage string `arg:"" required:"" help:"Catalog image reference"`
+	CatalogImage string `arg:"" required:"" help:"Catalog image reference (registry, oci:, oci-archive:, docker-archive: or dir:)"`
 	OutputDir    string `default:"${default_manifests_dir}" help:"Output directory for generated manifests"`
 	SkipIDMS     bool   `help:"Skip generating ImageDigestMirrorSet (for clusters where IDMS is not supported, e.g. ROSA/HyperShift)"`
+	Package      string `help:"Package to subscribe to; required if the catalog has several (default: its only package)"`
+	Namespace    string `help:"Namespace to install the operator in (default: bpfman for bpfman-operator, otherwise the package name)"`
+
+	Channel             string `help:"Channel to subscribe to (default: the catalog's default channel)"`
+	StartingCSV         string `name:"starting-csv" help:"CSV the subscription installs first, e.g. a released version to upgrade from"`
+	InstallPlanApproval string `name:"install-plan-approval" default:"Automatic" enum:"Automatic,Manual" help:"Subscription install plan approval (Automatic, Manual)"`
 }
 
 // BundleInfoCmd shows bundle contents and dependencies.
 type BundleInfoCmd struct {
-	BundleImages []string `arg:"" required:"" help:"Bundle image references"`
+	BundleImages []string `arg:"" required:"" help:"Bundle image references (registry, oci:, oci-archive:, docker-archive: or dir:)"`
 	Format       string   `default:"text" enum:"text,json" help:"Output format (text, json)"`
 }
 
+// CatalogInfoCmd analyses every bundle in a catalog.
+type CatalogInfoCmd struct {
+	Catalog string `arg:"" required:"" help:"Catalog image reference, rendered catalog file or directory, or basic template"`
+	Format  string `default:"text" enum:"text,json" help:"Output format (text, json)"`
+}
+
 // ListBundlesCmd lists available bundle images.
 type ListBundlesCmd struct {
-	Repository string `help:"Bundle repository (default: quay.io/redhat-user-workloads/ocp-bpfman-tenant/bpfman-operator-bundle-ystream)"`
-	Limit      int    `short:"n" default:"5" help:"Number of bundles to display"`
+	Repository string `help:"Bundle repository or local oci:, oci-archive:, docker-archive: or dir: path (default: quay.io/redhat-user-workloads/ocp-bpfman-tenant/bpfman-operator-bundle-ystream)"`
+	Limit      int    `short:"n" default:"5" help:"Number of bundles to display (0 for all matching bundles)"`
+	Since      string `help:"Only bundles built at or after this time (RFC 3339, YYYY-MM-DD, or an age such as 36h or 7d)"`
+	Until      string `help:"Only bundles built at or before this time (RFC 3339, YYYY-MM-DD, or an age such as 36h or 7d)"`
+	Version    string `help:"Only bundles whose version label matches, exactly or as a glob such as 0.6.*"`
+	PROnly     bool   `name:"pr-only" help:"Only list on-pr-<commit> builds" xor:"pr"`
+	ExcludePR  bool   `name:"exclude-pr" help:"Exclude on-pr-<commit> builds" xor:"pr"`
+	Commit     string `help:"Only bundles built from a git commit starting with this prefix"`
+	TagRegex   string `name:"tag-regex" help:"Also consider tags matching this regular expression alongside git commit and on-pr- tags"`
+	Output     string `short:"o" default:"json" enum:"table,json,yaml,name" help:"Output format (table, json, yaml, name)"`
+	Correlate  bool   `help:"Match each bundle with the operator, agent and daemon builds from the same commit"`
+	Refresh    bool   `help:"Discard the local index and inspect every tag again"`
+
+	indexFlags `embed:""`
+}
+
+// indexFlags select the local tag metadata index of the commands that
+// list bundle repositories.
+type indexFlags struct {
+	IndexDir string `name:"index-dir" env:"BPFMAN_CATALOG_INDEX_DIR" help:"Directory for the local tag metadata index (default: bundles under --cache-dir)"`
+	NoIndex  bool   `name:"no-index" help:"Inspect every tag without reading or writing the local index"`
+}
+
+// indexDir returns the index directory selected by the flags, or ""
+// for no index.
+func (f indexFlags) indexDir(cacheDir string) string {
+	switch {
+	case f.NoIndex:
+		return ""
+	case f.IndexDir != "":
+		return f.IndexDir
+	}
+	return cachedir.Path(cacheDir, cachedir.BundleIndexes)
+}
+
+// FindBundlesCmd finds the bundle builds that reference an image.
+type FindBundlesCmd struct {
+	Image        string   `required:"" help:"Image reference pinned by digest (name@sha256:...), e.g. an operator or agent build"`
+	Repositories []string `name:"repository" help:"Bundle repository to scan, repeatable (default: quay.io/redhat-user-workloads/ocp-bpfman-tenant/bpfman-operator-bundle-ystream)"`
+	Limit        int      `short:"n" default:"20" help:"Number of most recent bundles to scan per repository (0 for all)"`
+	Since        string   `help:"Only scan bundles built at or after this time (RFC 3339, YYYY-MM-DD, or an age such as 36h or 7d)"`
+	Catalogs     []string `name:"catalog" help:"Catalog image, rendered catalog or template to check for the matching bundles, repeatable"`
+	Format       string   `default:"text" enum:"text,json" help:"Output format (text, json)"`
+	ReferenceDir string   `name:"reference-cache-dir" env:"BPFMAN_CATALOG_REFERENCE_CACHE_DIR" help:"Directory caching the images referenced by each bundle (default: bundle-references under --cache-dir)"`
+	NoCache      bool     `name:"no-cache" help:"Extract every bundle without reading or writing the cache"`
+
+	indexFlags `embed:""`
+}
+
+// BuildCatalogImageCmd builds and pushes a catalog image without a
+// container engine.
+type BuildCatalogImageCmd struct {
+	Catalog        string   `arg:"" type:"path" required:"" help:"Rendered catalog file or directory to serve from /configs"`
+	Package        string   `help:"Package the image is labelled for, and directory under /configs a catalog file is stored in; required if the catalog has several packages (default: its only package)"`
+	Destination    string   `short:"d" required:"" help:"Where to push the image: a registry reference, or oci:<dir>:<tag> for an OCI layout"`
+	OCPVersion     string   `name:"ocp-version" default:"${default_ocp_version}" help:"OpenShift version whose opm image the catalog is layered on"`
+	BaseImage      string   `help:"opm base image, overriding the one mapped from --ocp-version"`
+	Platform       string   `default:"linux/amd64" help:"Platform of the base image to build on (os/arch)"`
+	BuildVersion   string   `name:"build-version" help:"Version label of the catalog image (default: the newest bundle's version)"`
+	Commit         string   `help:"Commit recorded in the catalog image's upstream-vcs-ref label"`
+	Labels         []string `name:"label" help:"Extra image label as key=value, repeatable"`
+	DigestFile     string   `name:"digest-file" type:"path" help:"Also write the pushed manifest digest to this file"`
+	SkipServeCache bool     `name:"skip-serve-cache" help:"Acknowledge that the image lacks the opm serve cache the Dockerfile pre-builds with a container engine, and builds it on every start (required)"`
+}
+
+// RebuildBundleCmd rebuilds a bundle image with overridden images.
+type RebuildBundleCmd struct {
+	BundleImage   string `arg:"" required:"" help:"Bundle image reference (registry, oci:, oci-archive:, docker-archive: or dir:)"`
+	Destination   string `short:"d" required:"" help:"Where to push the rebuilt bundle: a registry reference, or oci:<dir>:<tag> for an OCI layout"`
+	OperatorImage string `help:"Operator image for the CSV deployment and relatedImages"`
+	DaemonImage   string `help:"Daemon image (bpfman.image in bpfman-config, bpfman-operator only)"`
+	AgentImage    string `help:"Agent image (bpfman.agent.image in bpfman-config, bpfman-operator only)"`
+	Format        string `default:"text" enum:"text,json" help:"Output format (text, json)"`
+	DigestFile    string `name:"digest-file" type:"path" help:"Also write the pushed manifest digest to this file"`
+}
+
+// WatchBundlesCmd polls bundle repositories for new builds.
+type WatchBundlesCmd struct {
+	Repositories []string      `name:"repository" help:"Bundle repository to watch, repeatable (default: quay.io/redhat-user-workloads/ocp-bpfman-tenant/bpfman-operator-bundle-ystream)"`
+	Interval     time.Duration `default:"5m" help:"Time between polls"`
+	Limit        int           `short:"n" default:"10" help:"Number of most recent builds of each repository to check per poll (0 for all)"`
+	Version      string        `help:"Only report bundles whose version label matches, exactly or as a glob such as 0.6.*"`
+	PROnly       bool          `name:"pr-only" help:"Only report on-pr-<commit> builds" xor:"pr"`
+	ExcludePR    bool          `name:"exclude-pr" help:"Do not report on-pr-<commit> builds" xor:"pr"`
+	TagRegex     string        `name:"tag-regex" help:"Also consider tags matching this regular expression alongside git commit and on-pr- tags"`
+	StateFile    string        `name:"state-file" type:"path" env:"BPFMAN_CATALOG_WATCH_STATE" help:"File recording the builds already reported (default: watch-state.json under --cache-dir)"`
+	EmitExisting bool          `name:"emit-existing" help:"Report the builds already present when a repository is first watched"`
+	EventURL     string        `name:"event-url" env:"BPFMAN_CATALOG_EVENT_URL" help:"Also POST each new build as a CloudEvent to this URL"`
+	EventSource  string        `name:"event-source" default:"${default_event_source}" help:"CloudEvents source attribute"`
+	Once         bool          `help:"Poll once and exit"`
+
+	indexFlags `embed:""`
 }
 
 func (r *PrepareCatalogBuildFromBundleCmd) Run(globals *GlobalContext) error {
@@ -81,16 +375,62 @@ func (r *PrepareCatalogBuildFromBundleCmd) Run(globals *GlobalContext) error {
 		return fmt.Errorf("output directory cannot be the current working directory, please specify a named subdirectory like '%s'", DefaultArtefactsDir)
 	}
 
+	targets, err := bundle.ResolveOCPTargets(r.OCPVersions, r.BaseImage)
+	if err != nil {
+		return err
+	}
+
+	images := bundle.ImageOverrides{Operator: r.OperatorImage, Daemon: r.DaemonImage, Agent: r.AgentImage}
+	if r.BundleDestination == "" && (r.DaemonImage != "" || r.AgentImage != "") {
+		return fmt.Errorf("--daemon-image and --agent-image need --bundle-destination to rebuild the bundle")
+	}
+	if r.BundleDestination != "" && images.IsZero() {
+		return fmt.Errorf("--bundle-destination needs at least one of --operator-image, --daemon-image or --agent-image")
+	}
+	if registry.IsLocal(r.BundleDestination) {
+		return fmt.Errorf("--bundle-destination %s is local; the catalog needs a registry reference it can pin by digest", r.BundleDestination)
+	}
+	if r.UpgradeFromReleased && r.ReleasedTemplate == "" {
+		return fmt.Errorf("--upgrade-from-released needs --released-template")
+	}
+
+	templates, err := bundle.LoadTemplates(r.TemplatesDir)
+	if err != nil {
+		return err
+	}
+
 	if err := os.RemoveAll(r.OutputDir); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("cleaning output directory: %w", err)
 	}
 
-	var gen *bundle.Generator
-	if r.OpmBin != "" {
-		gen = bundle.NewGeneratorWithOmp(r.BundleImage, "preview", r.OpmBin, r.OperatorImage)
-	} else {
-		gen = bundle.NewGenerator(r.BundleImage, "preview", r.OperatorImage)
+	opts := bundle.GeneratorOptions{
+		Package: r.Package,
+		Channels: bundle.ChannelOptions{
+			Channels:       r.Channels,
+			DefaultChannel: r.DefaultChannel,
+			Skips:          r.Skips,
+			SkipRange:      r.SkipRange,
+		},
+		OpmBinPath:   r.OpmBin,
+		Targets:      targets,
+		BuildVersion: r.BuildVersion,
+		Commit:       r.Commit,
+		Namespace:    catalog.PackageNamespace,
+		Templates:    templates,
+		Environment:  &globals.Environment,
+	}
+	if r.BundleDestination != "" {
+		opts.Rebuild = images
+		opts.RebuildDestination = r.BundleDestination
+	} else if r.OperatorImage != "" {
+		globals.Logger.Warn("operator image will be patched in after install, where OLM can revert it; use --bundle-destination to rebuild the bundle instead",
+			slog.String("image", r.OperatorImage))
+		opts.OperatorImageOverride = r.OperatorImage
 	}
+	if r.UpgradeFromReleased {
+		opts.UpgradeFrom = r.ReleasedTemplate
+	}
+	gen := bundle.NewGeneratorWithOptions(r.BundleImages, opts)
 
 	artefacts, err := gen.Generate(globals.Context)
 	if err != nil {
@@ -115,24 +455,126 @@ func (r *PrepareCatalogBuildFromBundleCmd) Run(globals *GlobalContext) error {
 	if err := w.WriteSingle("Makefile", []byte(artefacts.Makefile)); err != nil {
 		return fmt.Errorf("writing Makefile: %w", err)
 	}
+	for _, v := range artefacts.Variants {
+		if err := w.WriteSingle(v.Target.CatalogFile(), []byte(v.CatalogYAML)); err != nil {
+			return fmt.Errorf("writing catalog for OpenShift %s: %w", v.Target.Version, err)
+		}
+		if err := w.WriteSingle(v.Target.Dockerfile(), []byte(v.Dockerfile)); err != nil {
+			return fmt.Errorf("writing Dockerfile for OpenShift %s: %w", v.Target.Version, err)
+		}
+	}
 
 	catalogRendered := artefacts.CatalogYAML != ""
-	imageUUID, randomTTL := bundle.GenerateImageUUIDAndTTL()
-	workflow := bundle.GenerateWorkflow(0, catalogRendered, r.OutputDir, imageUUID, randomTTL)
+	bundleCount := 0
+	if len(r.BundleImages) > 1 {
+		bundleCount = len(r.BundleImages)
+	}
+	workflowData := globals.Environment.WorkflowData(bundleCount, catalogRendered, r.OutputDir)
+	workflowData.Upgrade = artefacts.Upgrade
+	workflowData.Targets = workflowTargets(targets)
+	workflowData.Rebuilt = artefacts.Rebuilt
+	workflow, err := templates.Workflow(workflowData)
+	if err != nil {
+		return fmt.Errorf("generating WORKFLOW.txt: %w", err)
+	}
 	if err := w.WriteSingle("WORKFLOW.txt", []byte(workflow)); err != nil {
 		return fmt.Errorf("writing WORKFLOW.txt: %w", err)
 	}
 
+	prov := provenance.New(os.Args[1:], globals.Environment.Time, globals.Secrets...)
+	for _, info := range artefacts.Bundles {
+		prov.AddResolvedImage(provenance.InputBundle, info.Reference, info.Digest)
+	}
+	if rebuilt := artefacts.Rebuilt; rebuilt != nil {
+		prov.Inputs = append(prov.Inputs, provenance.Input{
+			Kind:      provenance.InputRebuiltBundle,
+			Reference: rebuilt.Destination,
+			Digest:    rebuilt.Digest.String(),
+		})
+	}
+	if r.UpgradeFromReleased {
+		if err := prov.AddFile(provenance.InputReleasedTemplate, r.ReleasedTemplate); err != nil {
+			return err
+		}
+	}
+	if err := addTemplateInputs(prov, r.TemplatesDir); err != nil {
+		return err
+	}
+	if catalogRendered {
+		if prov.Render, err = renderProvenance(globals.Context, r.OpmBin); err != nil {
+			return err
+		}
+	}
+	if err := provenance.Write(r.OutputDir, prov); err != nil {
+		return err
+	}
+
 	fmt.Print(workflow)
 	fmt.Printf("\nThis information is saved in %s/WORKFLOW.txt\n", r.OutputDir)
 	return nil
 }
 
+// addTemplateInputs records the templates used from templatesDir.
+func addTemplateInputs(prov *provenance.Manifest, templatesDir string) error {
+	if templatesDir == "" {
+		return nil
+	}
+	for _, name := range bundle.TemplateNames {
+		path := filepath.Join(templatesDir, name)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		if err := prov.AddFile(provenance.InputTemplate, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderProvenance records how catalogs were rendered: with opmBin
+// if set, otherwise with the built-in library.
+func renderProvenance(ctx context.Context, opmBin string) (*provenance.Render, error) {
+	if opmBin == "" {
+		return provenance.LibraryRender(), nil
+	}
+	return provenance.OpmRender(ctx, opmBin)
+}
+
+// formatRebuildText describes a rebuilt bundle and the images
+// replaced in it.
+func formatRebuildText(result *bundle.RebuildResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Rebuilt bundle %s\n  from %s\n", result.Image, result.Bundle)
+	for _, c := range result.Changes {
+		fmt.Fprintf(&b, "  %s %s:\n    %s\n    -> %s\n", c.File, c.Path, c.From, c.To)
+	}
+	return b.String()
+}
+
+// workflowTargets returns the OpenShift targets WORKFLOW.txt lists:
+// all of them when building for several versions, otherwise none.
+func workflowTargets(targets []bundle.OCPTarget) []bundle.OCPTarget {
+	if len(targets) < 2 {
+		return nil
+	}
+	return targets
+}
+
 func (r *PrepareCatalogBuildFromYAMLCmd) Run(globals *GlobalContext) error {
 	if filepath.Clean(r.OutputDir) == "." {
 		return fmt.Errorf("output directory cannot be the current working directory, please specify a named subdirectory like '%s'", DefaultArtefactsDir)
 	}
 
+	targets, err := bundle.ResolveOCPTargets(r.OCPVersions, r.BaseImage)
+	if err != nil {
+		return err
+	}
+
+	templates, err := bundle.LoadTemplates(r.TemplatesDir)
+	if err != nil {
+		return err
+	}
+
 	if err := os.RemoveAll(r.OutputDir); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("cleaning output directory: %w", err)
 	}
@@ -141,6 +583,14 @@ func (r *PrepareCatalogBuildFromYAMLCmd) Run(globals *GlobalContext) error {
 	if err != nil {
 		return fmt.Errorf("reading catalog.yaml: %w", err)
 	}
+	cfg, err := declcfg.LoadReader(bytes.NewReader(catalogContent))
+	if err != nil {
+		return fmt.Errorf("loading catalog.yaml: %w", err)
+	}
+	packageName, err := catalog.SelectPackage(catalog.CatalogPackages(cfg), r.Package)
+	if err != nil {
+		return err
+	}
 
 	w := writer.New(r.OutputDir)
 
@@ -148,28 +598,66 @@ func (r *PrepareCatalogBuildFromYAMLCmd) Run(globals *GlobalContext) error {
 		return fmt.Errorf("writing catalog.yaml: %w", err)
 	}
 
-	dockerfile := bundle.GenerateCatalogDockerfile()
+	dockerfileOpts := bundle.DockerfileOptions{
+		Name:        "Dockerfile",
+		BaseImage:   targets[0].BaseImage,
+		CatalogFile: "catalog.yaml",
+		Package:     packageName,
+		Version:     r.BuildVersion,
+		Commit:      r.Commit,
+	}
+	dockerfile := bundle.GenerateCatalogDockerfile(dockerfileOpts)
 	if err := w.WriteSingle("Dockerfile", []byte(dockerfile)); err != nil {
 		return fmt.Errorf("writing Dockerfile: %w", err)
 	}
+	// The catalog is already rendered, so every version builds the
+	// same catalog.yaml on its own base image.
+	if len(targets) > 1 {
+		for _, t := range targets {
+			variant := dockerfileOpts
+			variant.Name = t.Dockerfile()
+			variant.BaseImage = t.BaseImage
+			dockerfile := bundle.GenerateCatalogDockerfile(variant)
+			if err := w.WriteSingle(t.Dockerfile(), []byte(dockerfile)); err != nil {
+				return fmt.Errorf("writing Dockerfile for OpenShift %s: %w", t.Version, err)
+			}
+		}
+	}
 
-	execPath, err := os.Executable()
+	makefileData, err := globals.Environment.MakefileData("from-yaml", packageName, "", nil, targets)
 	if err != nil {
-		execPath = "bpfman-catalog"
+		return err
+	}
+	makefileData.Namespace = catalog.PackageNamespace(packageName)
+	makefile, err := templates.Makefile(makefileData)
+	if err != nil {
+		return fmt.Errorf("generating Makefile: %w", err)
 	}
-
-	imageUUID, randomTTL := bundle.GenerateImageUUIDAndTTL()
-
-	makefile := bundle.GenerateMakefile("from-yaml", execPath, imageUUID, randomTTL, "")
 	if err := w.WriteSingle("Makefile", []byte(makefile)); err != nil {
 		return fmt.Errorf("writing Makefile: %w", err)
 	}
 
-	workflow := bundle.GenerateWorkflow(0, true, r.OutputDir, imageUUID, randomTTL)
+	workflowData := globals.Environment.WorkflowData(0, true, r.OutputDir)
+	workflowData.Targets = workflowTargets(targets)
+	workflow, err := templates.Workflow(workflowData)
+	if err != nil {
+		return fmt.Errorf("generating WORKFLOW.txt: %w", err)
+	}
 	if err := w.WriteSingle("WORKFLOW.txt", []byte(workflow)); err != nil {
 		return fmt.Errorf("writing WORKFLOW.txt: %w", err)
 	}
 
+	prov := provenance.New(os.Args[1:], globals.Environment.Time, globals.Secrets...)
+	if err := prov.AddFile(provenance.InputCatalog, r.CatalogYAML); err != nil {
+		return err
+	}
+	if err := addTemplateInputs(prov, r.TemplatesDir); err != nil {
+		return err
+	}
+	if err := provenance.Write(r.OutputDir, prov); err != nil {
+		return err
+	}
+
 	fmt.Print(workflow)
 	fmt.Printf("\nThis information is saved in %s/WORKFLOW.txt\n", r.OutputDir)
 
@@ -185,11 +673,22 @@ func (r *PrepareCatalogDeploymentFromImageCmd) Run(globals *GlobalContext) error
 		return fmt.Errorf("cleaning output directory: %w", err)
 	}
 
+	if registry.IsLocal(r.CatalogImage) {
+		globals.Logger.Warn("catalog image is local; push it to a registry and update the CatalogSource image before applying",
+			slog.String("image", r.CatalogImage))
+	}
+
 	config := manifests.GeneratorConfig{
-		Namespace:     "bpfman",
+		Namespace:     r.Namespace,
 		UseDigestName: true,
 		ImageRef:      r.CatalogImage,
+		Package:       r.Package,
 		SkipIDMS:      r.SkipIDMS,
+		Time:          globals.Environment.Time,
+
+		Channel:             r.Channel,
+		StartingCSV:         r.StartingCSV,
+		InstallPlanApproval: r.InstallPlanApproval,
 	}
 
 	generator := manifests.NewGenerator(config)
@@ -204,13 +703,36 @@ func (r *PrepareCatalogDeploymentFromImageCmd) Run(globals *GlobalContext) error
 		return fmt.Errorf("writing manifests: %w", err)
 	}
 
+	// Manifests generated inside a prepared artefacts directory, as
+	// its Makefile does, are recorded in that directory's
+	// artefacts.json rather than in a second one.
+	enclosing, err := provenance.Enclosing(r.OutputDir)
+	if err != nil {
+		return err
+	}
+	prov := provenance.New(os.Args[1:], globals.Environment.Time, globals.Secrets...)
+	if err := prov.AddImage(globals.Context, provenance.InputCatalogImage, r.CatalogImage); err != nil {
+		return err
+	}
+	if enclosing != "" {
+		if err := provenance.Merge(enclosing, r.OutputDir, prov.Inputs...); err != nil {
+			return err
+		}
+	} else if err := provenance.Write(r.OutputDir, prov); err != nil {
+		return err
+	}
+
 	fmt.Printf("Manifests generated in %s\n", r.OutputDir)
 	return nil
 }
 
 func (r *BundleInfoCmd) Run(globals *GlobalContext) error {
+	ctx, err := globals.withPullRequests()
+	if err != nil {
+		return err
+	}
 	for _, bundleImage := range r.BundleImages {
-		result, err := analysis.AnalyseBundle(globals.Context, bundleImage)
+		result, err := analysis.AnalyseBundle(ctx, bundleImage)
 		if err != nil {
 			return fmt.Errorf("failed to analyse bundle %s: %w", bundleImage, err)
 		}
@@ -226,6 +748,21 @@ func (r *BundleInfoCmd) Run(globals *GlobalContext) error {
 	return nil
 }
 
+func (r *CatalogInfoCmd) Run(globals *GlobalContext) error {
+	result, err := analysis.AnalyseCatalog(globals.Context, r.Catalog)
+	if err != nil {
+		return fmt.Errorf("failed to analyse catalog %s: %w", r.Catalog, err)
+	}
+
+	output, err := analysis.FormatCatalogResult(result, r.Format)
+	if err != nil {
+		return fmt.Errorf("failed to format output for %s: %w", r.Catalog, err)
+	}
+
+	fmt.Print(output)
+	return nil
+}
+
 func (r *ListBundlesCmd) Run(globals *GlobalContext) error {
 	var bundleRef bundle.BundleRef
 	var err error
@@ -239,29 +776,424 @@ func (r *ListBundlesCmd) Run(globals *GlobalContext) error {
 		bundleRef = bundle.NewDefaultBundleRef()
 	}
 
-	bundles, err := bundle.ListLatestBundles(globals.Context, bundleRef, r.Limit)
+	opts, err := r.listOptions(time.Now(), globals.CacheDir)
+	if err != nil {
+		return err
+	}
+
+	if r.Correlate {
+		return r.runCorrelate(globals, bundleRef, opts)
+	}
+
+	bundles, failures, err := bundle.ListBundles(globals.Context, bundleRef, opts)
 	if err != nil {
 		return fmt.Errorf("listing bundles: %w", err)
 	}
+	if err := resolvePullRequests(globals, bundles); err != nil {
+		return err
+	}
 
-	output, err := formatBundlesJSON(bundles)
+	var output string
+	switch r.Output {
+	case "table":
+		output = formatBundlesTable(bundles, failures)
+	case "yaml":
+		output, err = formatBundlesYAML(bundles, failures)
+	case "name":
+		output = formatBundlesNames(bundleRef, bundles)
+		logTagFailures(globals.Logger, failures)
+	default:
+		output, err = formatBundlesJSON(bundles, failures)
+	}
 	if err != nil {
-		return fmt.Errorf("formatting JSON output: %w", err)
+		return fmt.Errorf("formatting %s output: %w", r.Output, err)
 	}
 	fmt.Println(output)
 
 	return nil
 }
 
-func formatBundlesJSON(bundles []*bundle.BundleMetadata) (string, error) {
-	type output struct {
-		Count   int                      `json:"count"`
-		Bundles []*bundle.BundleMetadata `json:"bundles"`
+func (r *FindBundlesCmd) Run(globals *GlobalContext) error {
+	opts := analysis.FindOptions{
+		List: bundle.ListOptions{
+			Limit:    r.Limit,
+			IndexDir: r.indexDir(globals.CacheDir),
+		},
+		Catalogs: r.Catalogs,
+	}
+	if !r.NoCache {
+		opts.CacheDir = r.ReferenceDir
+		if opts.CacheDir == "" {
+			opts.CacheDir = cachedir.Path(globals.CacheDir, cachedir.BundleReferences)
+		}
+	}
+	if r.Since != "" {
+		since, err := bundle.ParseTimeBound(r.Since, time.Now())
+		if err != nil {
+			return fmt.Errorf("--since: %w", err)
+		}
+		opts.List.Since = since
+	}
+	if err := opts.List.Validate(); err != nil {
+		return err
 	}
 
-	out := output{
-		Count:   len(bundles),
-		Bundles: bundles,
+	if len(r.Repositories) == 0 {
+		opts.Repositories = []bundle.BundleRef{bundle.NewDefaultBundleRef()}
+	}
+	for _, repo := range r.Repositories {
+		bundleRef, err := bundle.ParseBundleRef(repo)
+		if err != nil {
+			return fmt.Errorf("parsing repository %s: %w", repo, err)
+		}
+		opts.Repositories = append(opts.Repositories, bundleRef)
+	}
+
+	result, err := analysis.FindBundles(globals.Context, r.Image, opts)
+	if err != nil {
+		return fmt.Errorf("finding bundles for %s: %w", r.Image, err)
+	}
+
+	output, err := analysis.FormatFindResult(result, r.Format)
+	if err != nil {
+		return fmt.Errorf("failed to format output: %w", err)
+	}
+
+	fmt.Print(output)
+	return nil
+}
+
+func (r *BuildCatalogImageCmd) Run(globals *GlobalContext) error {
+	targets, err := bundle.ResolveOCPTargets([]string{r.OCPVersion}, r.BaseImage)
+	if err != nil {
+		return err
+	}
+
+	osName, arch, ok := strings.Cut(r.Platform, "/")
+	if !ok || osName == "" || arch == "" {
+		return fmt.Errorf("--platform must be os/arch, got %q", r.Platform)
+	}
+
+	labels := make(map[string]string, len(r.Labels))
+	for _, label := range r.Labels {
+		k, v, ok := strings.Cut(label, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("--label must be key=value, got %q", label)
+		}
+		labels[k] = v
+	}
+
+	globals.Logger.Info("building catalog image", "catalog", r.Catalog, "base", targets[0].BaseImage, "destination", r.Destination)
+	result, err := catalog.BuildImage(globals.Context, catalog.BuildOptions{
+		Catalog:        r.Catalog,
+		Package:        r.Package,
+		BaseImage:      targets[0].BaseImage,
+		Destination:    r.Destination,
+		OS:             osName,
+		Arch:           arch,
+		Version:        r.BuildVersion,
+		Commit:         r.Commit,
+		Labels:         labels,
+		Created:        globals.Environment.Time,
+		SkipServeCache: r.SkipServeCache,
+	})
+	if err != nil {
+		return fmt.Errorf("building catalog image: %w", err)
+	}
+
+	if r.DigestFile != "" {
+		if err := os.WriteFile(r.DigestFile, []byte(result.Digest.String()+"\n"), 0644); err != nil {
+			return fmt.Errorf("writing digest file: %w", err)
+		}
+	}
+	fmt.Println(result.Digest)
+	return nil
+}
+
+func (r *RebuildBundleCmd) Run(globals *GlobalContext) error {
+	images := bundle.ImageOverrides{Operator: r.OperatorImage, Daemon: r.DaemonImage, Agent: r.AgentImage}
+	if images.IsZero() {
+		return fmt.Errorf("at least one of --operator-image, --daemon-image or --agent-image is required")
+	}
+
+	globals.Logger.Info("rebuilding bundle", "bundle", r.BundleImage, "destination", r.Destination)
+	result, err := bundle.RebuildBundle(globals.Context, bundle.RebuildOptions{
+		Bundle:      r.BundleImage,
+		Destination: r.Destination,
+		Images:      images,
+		Created:     globals.Environment.Time,
+	})
+	if err != nil {
+		return fmt.Errorf("rebuilding bundle: %w", err)
+	}
+
+	if r.DigestFile != "" {
+		if err := os.WriteFile(r.DigestFile, []byte(result.Digest.String()+"\n"), 0644); err != nil {
+			return fmt.Errorf("writing digest file: %w", err)
+		}
+	}
+	if r.Format == "json" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	fmt.Print(formatRebuildText(result))
+	return nil
+}
+
+func (r *VerifyArtefactsCmd) Run(globals *GlobalContext) error {
+	prov, diffs, err := provenance.Verify(r.Dir)
+	if err != nil {
+		return err
+	}
+
+	if r.Format == "json" {
+		data, err := json.MarshalIndent(struct {
+			Provenance  *provenance.Manifest    `json:"provenance"`
+			Differences []provenance.Difference `json:"differences"`
+		}{prov, diffs}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Print(formatVerifyText(r.Dir, prov, diffs))
+	}
+
+	if len(diffs) > 0 {
+		return fmt.Errorf("%d file(s) in %s differ from %s", len(diffs), r.Dir, provenance.FileName)
+	}
+	return nil
+}
+
+// formatVerifyText describes how an artefacts directory was prepared
+// and what has changed in it since.
+func formatVerifyText(dir string, prov *provenance.Manifest, diffs []provenance.Difference) string {
+	var b strings.Builder
+	tool := prov.Tool.Version
+	if prov.Tool.Commit != "" {
+		tool += " (" + prov.Tool.Commit + ")"
+	}
+	fmt.Fprintf(&b, "Prepared by bpfman-catalog %s at %s\n", tool, prov.Created.Format(time.RFC3339))
+	fmt.Fprintf(&b, "  %s\n", strings.Join(prov.Command, " "))
+	for _, in := range prov.Inputs {
+		fmt.Fprintf(&b, "  %s %s\n    %s\n", in.Kind, in.Reference, in.Digest)
+	}
+	if current := provenance.CurrentTool(); current != prov.Tool {
+		fmt.Fprintf(&b, "  Running bpfman-catalog %s %s differs from the one that prepared them\n", current.Version, current.Commit)
+	}
+	if len(diffs) == 0 {
+		fmt.Fprintf(&b, "\nAll %d files in %s match %s\n", len(prov.Files), dir, provenance.FileName)
+		return b.String()
+	}
+	b.WriteString("\n")
+	for _, d := range diffs {
+		fmt.Fprintf(&b, "  %-10s %s\n", d.Change, d.Path)
+	}
+	return b.String()
+}
+
+func (r *DumpTemplatesCmd) Run(globals *GlobalContext) error {
+	written, err := bundle.DumpTemplates(r.Dir, r.Overwrite)
+	for _, path := range written {
+		fmt.Println(path)
+	}
+	if err != nil {
+		return fmt.Errorf("dumping templates: %w", err)
+	}
+	return nil
+}
+
+func (r *WatchBundlesCmd) Run(globals *GlobalContext) error {
+	opts := bundle.WatchOptions{
+		List: bundle.ListOptions{
+			Limit:     r.Limit,
+			Version:   r.Version,
+			PROnly:    r.PROnly,
+			ExcludePR: r.ExcludePR,
+			IndexDir:  r.indexDir(globals.CacheDir),
+		},
+		StatePath:    r.StateFile,
+		EmitExisting: r.EmitExisting,
+	}
+	if opts.StatePath == "" {
+		opts.StatePath = cachedir.Path(globals.CacheDir, cachedir.WatchState)
+	}
+	if r.TagRegex != "" {
+		pattern, err := regexp.Compile(r.TagRegex)
+		if err != nil {
+			return fmt.Errorf("--tag-regex: %w", err)
+		}
+		opts.List.TagPattern = pattern
+	}
+	if r.Interval <= 0 {
+		return fmt.Errorf("--interval must be positive, got %s", r.Interval)
+	}
+
+	if len(r.Repositories) == 0 {
+		opts.Repositories = []bundle.BundleRef{bundle.NewDefaultBundleRef()}
+	}
+	for _, repo := range r.Repositories {
+		bundleRef, err := bundle.ParseBundleRef(repo)
+		if err != nil {
+			return fmt.Errorf("parsing repository %s: %w", repo, err)
+		}
+		opts.Repositories = append(opts.Repositories, bundleRef)
+	}
+
+	// The endpoint goes first so that a build it rejects is not
+	// written to stdout until it is delivered by a later poll.
+	if r.EventURL != "" {
+		opts.Sinks = append(opts.Sinks, bundle.NewCloudEvents(r.EventURL, r.EventSource))
+	}
+	opts.Sinks = append(opts.Sinks, bundle.NewJSONLines(os.Stdout))
+
+	watcher, err := bundle.NewWatcher(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx := globals.Context
+	for {
+		events, failures, err := watcher.Poll(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		for _, f := range failures {
+			globals.Logger.Warn("could not read bundle tag", "tag", f.Tag, "error", f.Error)
+		}
+		if err != nil {
+			if r.Once {
+				return err
+			}
+			globals.Logger.Warn("poll failed, retrying at the next interval", "error", err)
+		}
+		globals.Logger.Debug("poll complete", "new_bundles", len(events))
+		if r.Once {
+			return nil
+		}
+
+		timer := time.NewTimer(r.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// logPullRequestErrors reports pull requests that could not be
+// resolved; the bundles are still listed.
+func logPullRequestErrors(logger *slog.Logger, errs []error) {
+	for _, err := range errs {
+		logger.Warn("could not resolve pull request", "error", err)
+	}
+}
+
+// resolvePullRequests records the pull request of each on-pr- bundle
+// with the resolver selected on the command line, logging lookups
+// that failed.
+func resolvePullRequests(globals *GlobalContext, bundles []*bundle.BundleMetadata) error {
+	ctx, err := globals.withPullRequests()
+	if err != nil {
+		return err
+	}
+	logPullRequestErrors(globals.Logger, bundle.ResolvePullRequests(ctx, bundles))
+	return nil
+}
+
+// logTagFailures reports tags that could not be read for output
+// formats with no room to list them.
+func logTagFailures(logger *slog.Logger, failures []bundle.TagFailure) {
+	for _, f := range failures {
+		logger.Warn("could not read bundle tag", "tag", f.Tag, "error", f.Error)
+	}
+}
+
+// runCorrelate lists bundles alongside the component builds from the
+// same commit.
+func (r *ListBundlesCmd) runCorrelate(globals *GlobalContext, bundleRef bundle.BundleRef, opts bundle.ListOptions) error {
+	correlations, failures, err := bundle.CorrelateBundles(globals.Context, bundleRef, opts)
+	if err != nil {
+		return fmt.Errorf("correlating bundles: %w", err)
+	}
+	bundles := make([]*bundle.BundleMetadata, 0, len(correlations))
+	for _, c := range correlations {
+		bundles = append(bundles, c.Bundle)
+	}
+	if err := resolvePullRequests(globals, bundles); err != nil {
+		return err
+	}
+
+	var output string
+	switch r.Output {
+	case "table":
+		output = formatCorrelationsTable(correlations, failures)
+	case "yaml":
+		output, err = formatCorrelationsYAML(correlations, failures)
+	case "name":
+		output = formatCorrelationsNames(bundleRef, correlations)
+		logTagFailures(globals.Logger, failures)
+	default:
+		output, err = formatCorrelationsJSON(correlations, failures)
+	}
+	if err != nil {
+		return fmt.Errorf("formatting %s output: %w", r.Output, err)
+	}
+	fmt.Println(output)
+
+	for _, c := range correlations {
+		if !c.Complete() {
+			globals.Logger.Warn("bundle has unconfirmed component builds", "tag", c.Tag, "status", correlationStatus(c))
+		}
+	}
+
+	return nil
+}
+
+// listOptions converts the command's filter flags into
+// bundle.ListOptions, resolving relative times against now and
+// keeping the index under cacheDir unless --index-dir is given.
+func (r *ListBundlesCmd) listOptions(now time.Time, cacheDir string) (bundle.ListOptions, error) {
+	opts := bundle.ListOptions{
+		Limit:        r.Limit,
+		Version:      r.Version,
+		PROnly:       r.PROnly,
+		ExcludePR:    r.ExcludePR,
+		CommitPrefix: r.Commit,
+		Refresh:      r.Refresh,
+		IndexDir:     r.indexDir(cacheDir),
+	}
+
+	var err error
+	if r.Since != "" {
+		if opts.Since, err = bundle.ParseTimeBound(r.Since, now); err != nil {
+			return opts, fmt.Errorf("--since: %w", err)
+		}
+	}
+	if r.Until != "" {
+		if opts.Until, err = bundle.ParseTimeBound(r.Until, now); err != nil {
+			return opts, fmt.Errorf("--until: %w", err)
+		}
+	}
+	if r.TagRegex != "" {
+		if opts.TagPattern, err = regexp.Compile(r.TagRegex); err != nil {
+			return opts, fmt.Errorf("--tag-regex: %w", err)
+		}
+	}
+
+	return opts, opts.Validate()
+}
+
+func formatBundlesJSON(bundles []*bundle.BundleMetadata, failures []bundle.TagFailure) (string, error) {
+	out := bundleListOutput{
+		Count:    len(bundles),
+		Bundles:  bundles,
+		Failures: failures,
 	}
 
 	data, err := json.MarshalIndent(out, "", "  ")
@@ -271,6 +1203,177 @@ func formatBundlesJSON(bundles []*bundle.BundleMetadata) (string, error) {
 	return string(data), nil
 }
 
+// bundleListOutput is the document written by the json and yaml
+// output formats.
+type bundleListOutput struct {
+	Count    int                      `json:"count"`
+	Bundles  []*bundle.BundleMetadata `json:"bundles"`
+	Failures []bundle.TagFailure      `json:"failures,omitempty"`
+}
+
+func formatBundlesYAML(bundles []*bundle.BundleMetadata, failures []bundle.TagFailure) (string, error) {
+	data, err := yaml.Marshal(bundleListOutput{Count: len(bundles), Bundles: bundles, Failures: failures})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+func formatBundlesTable(bundles []*bundle.BundleMetadata, failures []bundle.TagFailure) string {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TAG\tVERSION\tBUILD DATE\tDIGEST\tPULL REQUEST")
+	for _, b := range bundles {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", b.Tag, b.Version, b.BuildDate, b.Digest, formatPullRequest(b.PullRequest))
+	}
+	tw.Flush()
+	writeFailuresTable(&sb, failures)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// formatPullRequest summarises a pull request for tabular output.
+func formatPullRequest(pr *pullrequest.PullRequest) string {
+	if pr == nil {
+		return ""
+	}
+	return fmt.Sprintf("#%d (%s, %s) %s", pr.Number, pr.State, pr.Author, pr.Title)
+}
+
+// writeFailuresTable appends the tags that could not be read, if any,
+// as a second table.
+func writeFailuresTable(sb *strings.Builder, failures []bundle.TagFailure) {
+	if len(failures) == 0 {
+		return
+	}
+	sb.WriteString("\n")
+	tw := tabwriter.NewWriter(sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FAILED TAG\tERROR")
+	for _, f := range failures {
+		fmt.Fprintf(tw, "%s\t%s\n", f.Tag, f.Error)
+	}
+	tw.Flush()
+}
+
+// formatBundlesNames prints one digest reference per line so the
+// output can be fed straight into other commands.
+func formatBundlesNames(bundleRef bundle.BundleRef, bundles []*bundle.BundleMetadata) string {
+	names := make([]string, 0, len(bundles))
+	for _, b := range bundles {
+		names = append(names, bundleRef.DigestRef(b))
+	}
+	return strings.Join(names, "\n")
+}
+
+// correlationOutput is the document written by the json and yaml
+// output formats of list-bundles --correlate.
+type correlationOutput struct {
+	Count      int                   `json:"count"`
+	Incomplete int                   `json:"incomplete"`
+	Commits    []*bundle.Correlation `json:"commits"`
+	Failures   []bundle.TagFailure   `json:"failures,omitempty"`
+}
+
+func newCorrelationOutput(correlations []*bundle.Correlation, failures []bundle.TagFailure) correlationOutput {
+	out := correlationOutput{Count: len(correlations), Commits: correlations, Failures: failures}
+	for _, c := range correlations {
+		if !c.Complete() {
+			out.Incomplete++
+		}
+	}
+	return out
+}
+
+func formatCorrelationsJSON(correlations []*bundle.Correlation, failures []bundle.TagFailure) (string, error) {
+	data, err := json.MarshalIndent(newCorrelationOutput(correlations, failures), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func formatCorrelationsYAML(correlations []*bundle.Correlation, failures []bundle.TagFailure) (string, error) {
+	data, err := yaml.Marshal(newCorrelationOutput(correlations, failures))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+func formatCorrelationsTable(correlations []*bundle.Correlation, failures []bundle.TagFailure) string {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+
+	header := []string{"TAG", "VERSION", "BUILD DATE", "BUNDLE"}
+	for _, component := range bundle.CorrelatedComponents {
+		header = append(header, strings.ToUpper(component))
+	}
+	header = append(header, "STATUS")
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	for _, c := range correlations {
+		row := []string{c.Tag, c.Bundle.Version, c.Bundle.BuildDate, shortDigest(c.Bundle.Digest.String())}
+		for _, component := range bundle.CorrelatedComponents {
+			build := c.Components[component]
+			if build.Missing {
+				row = append(row, "MISSING")
+			} else if build.Unknown {
+				row = append(row, "UNKNOWN")
+			} else {
+				row = append(row, shortDigest(build.Digest.String()))
+			}
+		}
+		status := "complete"
+		if !c.Complete() {
+			status = correlationStatus(c)
+		}
+		row = append(row, status)
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	tw.Flush()
+	writeFailuresTable(&sb, failures)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// correlationStatus describes the components an incomplete
+// correlation lacks or could not check.
+func correlationStatus(c *bundle.Correlation) string {
+	var parts []string
+	if len(c.Missing) > 0 {
+		parts = append(parts, "missing "+strings.Join(c.Missing, ","))
+	}
+	if len(c.Unknown) > 0 {
+		parts = append(parts, "unknown "+strings.Join(c.Unknown, ","))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// formatCorrelationsNames prints the digest references of the bundle
+// and each component build, one per line, with a blank line between
+// commits.
+func formatCorrelationsNames(bundleRef bundle.BundleRef, correlations []*bundle.Correlation) string {
+	groups := make([]string, 0, len(correlations))
+	for _, c := range correlations {
+		names := []string{bundleRef.DigestRef(c.Bundle)}
+		for _, component := range bundle.CorrelatedComponents {
+			if ref := c.Components[component].DigestRef(); ref != "" {
+				names = append(names, ref)
+			}
+		}
+		groups = append(groups, strings.Join(names, "\n"))
+	}
+	return strings.Join(groups, "\n\n")
+}
+
+// shortDigest abbreviates a sha256 digest for tabular output.
+func shortDigest(d string) string {
+	const length = len("sha256:") + 12
+	if len(d) > length {
+		return d[:length]
+	}
+	return d
+}
+
 func printWorkflowGuide() {
 	fmt.Printf(`
 Workflows:
@@ -306,6 +1409,37 @@ Workflows:
 `, DefaultArtefactsDir, DefaultArtefactsDir, DefaultManifestsDir)
 }
 
+// configFile returns the JSON file that flag defaults are read from,
+// keyed by flag name with dashes replaced by underscores, or "" if
+// there is no user configuration directory.
+func configFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "bpfman-catalog", "config.json")
+}
+
+// envFirst wraps a configuration loader so that a flag's environment
+// variables take precedence over the configuration file, as the
+// command line does over both.
+func envFirst(loader kong.ConfigurationLoader) kong.ConfigurationLoader {
+	return func(r io.Reader) (kong.Resolver, error) {
+		resolver, err := loader(r)
+		if err != nil {
+			return nil, err
+		}
+		return kong.ResolverFunc(func(ctx *kong.Context, parent *kong.Path, flag *kong.Flag) (any, error) {
+			for _, env := range flag.Envs {
+				if _, ok := os.LookupEnv(env); ok {
+					return nil, nil
+				}
+			}
+			return resolver.Resolve(ctx, parent, flag)
+		}), nil
+	}
+}
+
 func main() {
 	var cli CLI
 
@@ -321,13 +1455,19 @@ func main() {
 		}
 	}
 
-	kongCtx := kong.Parse(&cli,
+	options := []kong.Option{
 		kong.Name("bpfman-catalog"),
 		kong.Description("Deploy and manage bpfman operator catalogs on OpenShift"),
 		kong.UsageOnError(),
 		kong.Vars{
 			"default_artefacts_dir": DefaultArtefactsDir,
 			"default_manifests_dir": DefaultManifestsDir,
+			"default_pr_repo":       pullrequest.DefaultRepository,
+			"default_github_url":    pullrequest.DefaultGitHubURL,
+			"default_event_source":  bundle.DefaultEventSource,
+			"default_ocp_version":   bundle.DefaultOCPVersion,
+			"default_serve_address": serve.DefaultAddress,
+			"default_cache_dir":     cachedir.Default(),
 		},
 		kong.Exit(func(code int) {
 			// Print workflow guide before exiting on help
@@ -336,7 +1476,11 @@ func main() {
 			}
 			os.Exit(code)
 		}),
-	)
+	}
+	if path := configFile(); path != "" {
+		options = append(options, kong.Configuration(envFirst(kong.JSON), path))
+	}
+	kongCtx := kong.Parse(&cli, options...)
 
 	// Print workflow guide after Kong help for non-exit cases
 	if showWorkflowGuide && len(os.Args) == 2 {
@@ -345,12 +1489,27 @@ func main() {
 
 	logger := setupLogger(cli.LogLevel, cli.LogFormat)
 
+	registryOpts := cli.registryOptions()
+	if err := registryOpts.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := registry.ExportEnvironment(registryOpts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	ctx = registry.WithOptions(ctx, registryOpts)
+
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	globals := &GlobalContext{
-		Context: ctx,
-		Logger:  logger,
+		Context:      ctx,
+		Logger:       logger,
+		Environment:  cli.environment(),
+		CacheDir:     cli.CacheDir,
+		Secrets:      cli.secrets(),
+		PullRequests: cli.prResolver,
 	}
 
 	errChan := make(chan error, 1)
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/alecthomas/kong"
 )
 
 // TestOutputDirValidation tests that we never allow the current working directory
@@ -116,3 +119,66 @@ func TestFilePathCleaningBehaviour(t *testing.T) {
 		})
 	}
 }
+
+// TestIndexFlags checks the index directory chosen by list-bundles,
+// find-bundles and watch-bundles.
+func TestIndexFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		flags    indexFlags
+		cacheDir string
+		want     string
+	}{
+		{name: "default", cacheDir: "/cache", want: filepath.Join("/cache", "bundles")},
+		{name: "no cache directory", want: ""},
+		{name: "index dir", flags: indexFlags{IndexDir: "/index"}, cacheDir: "/cache", want: "/index"},
+		{name: "no index", flags: indexFlags{IndexDir: "/index", NoIndex: true}, cacheDir: "/cache", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.flags.indexDir(tt.cacheDir); got != tt.want {
+				t.Errorf("indexDir = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestConfigFilePrecedence checks that flags and environment
+// variables override the configuration file.
+func TestConfigFilePrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"templates_dir": "/config"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		env  string
+		args []string
+		want string
+	}{
+		{name: "config file", want: "/config"},
+		{name: "environment", env: "/env", want: "/env"},
+		{name: "flag", env: "/env", args: []string{"--templates-dir", "/flag"}, want: "/flag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("BPFMAN_CATALOG_TEMPLATES_DIR", tt.env)
+			}
+			var cli struct {
+				TemplatesDir string `name:"templates-dir" env:"BPFMAN_CATALOG_TEMPLATES_DIR"`
+			}
+			parser, err := kong.New(&cli, kong.Configuration(envFirst(kong.JSON), path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := parser.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if cli.TemplatesDir != tt.want {
+				t.Errorf("templates dir = %q, want %q", cli.TemplatesDir, tt.want)
+			}
+		})
+	}
+}
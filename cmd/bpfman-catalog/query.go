@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/openshift/bpfman-catalog/pkg/catalog"
+)
+
+// CatalogCmd groups the commands that inspect a catalog.
+type CatalogCmd struct {
+	Query CatalogQueryCmd `cmd:"query" help:"Query the packages, channels, bundles and properties of a catalog"`
+}
+
+// CatalogQueryCmd queries the contents of a catalog.
+type CatalogQueryCmd struct {
+	Packages   QueryPackagesCmd   `cmd:"packages" help:"List packages with their default channel and channels"`
+	Channels   QueryChannelsCmd   `cmd:"channels" help:"List channels with their head and number of entries"`
+	Bundles    QueryBundlesCmd    `cmd:"bundles" help:"List channel entries with their version, replaces, skips and skipRange"`
+	Properties QueryPropertiesCmd `cmd:"properties" help:"List the properties bundles declare"`
+}
+
+// catalogQueryFlags are the arguments shared by every catalog query.
+type catalogQueryFlags struct {
+	Catalog string `arg:"" required:"" help:"Catalog image reference, rendered catalog file or directory, or basic template"`
+	Package string `help:"Only this package"`
+	Output  string `short:"o" default:"table" enum:"table,json" help:"Output format (table, json)"`
+}
+
+// bundleQueryFlags select bundles.
+type bundleQueryFlags struct {
+	Channel      string `help:"Only bundles in this channel"`
+	Bundle       string `help:"Only the bundle with this name, e.g. bpfman-operator.v0.6.0"`
+	Version      string `help:"Only bundles whose version matches, exactly or as a glob such as 0.6.*"`
+	RelatedImage string `name:"related-image" help:"Only bundles with a related image containing this reference or digest"`
+}
+
+// QueryPackagesCmd lists the packages of a catalog.
+type QueryPackagesCmd struct {
+	catalogQueryFlags `embed:""`
+}
+
+// QueryChannelsCmd lists the channels of a catalog.
+type QueryChannelsCmd struct {
+	catalogQueryFlags `embed:""`
+	Channel           string `help:"Only this channel"`
+}
+
+// QueryBundlesCmd lists the channel entries of a catalog.
+type QueryBundlesCmd struct {
+	catalogQueryFlags `embed:""`
+	bundleQueryFlags  `embed:""`
+}
+
+// QueryPropertiesCmd lists the properties of bundles in a catalog.
+type QueryPropertiesCmd struct {
+	catalogQueryFlags `embed:""`
+	bundleQueryFlags  `embed:""`
+	Type              string `help:"Only properties of this type, e.g. olm.gvk"`
+}
+
+// load loads the catalog being queried.
+func (f catalogQueryFlags) load(globals *GlobalContext) (*catalog.Catalog, error) {
+	cat, err := catalog.Load(globals.Context, f.Catalog)
+	if err != nil {
+		return nil, fmt.Errorf("loading catalog %s: %w", f.Catalog, err)
+	}
+	return cat, nil
+}
+
+// filter returns the query filter selected by the bundle flags.
+func (f bundleQueryFlags) filter(packageName string) catalog.QueryFilter {
+	return catalog.QueryFilter{
+		Package:      packageName,
+		Channel:      f.Channel,
+		Bundle:       f.Bundle,
+		Version:      f.Version,
+		RelatedImage: f.RelatedImage,
+	}
+}
+
+func (r *QueryPackagesCmd) Run(globals *GlobalContext) error {
+	cat, err := r.load(globals)
+	if err != nil {
+		return err
+	}
+	packages, err := cat.QueryPackages(catalog.QueryFilter{Package: r.Package})
+	if err != nil {
+		return err
+	}
+	return printQuery(r.Output, packages, []string{"PACKAGE", "DEFAULT CHANNEL", "CHANNELS", "BUNDLES"}, func(tw *tabwriter.Writer) {
+		for _, p := range packages {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", p.Name, p.DefaultChannel, strings.Join(p.Channels, ","), p.Bundles)
+		}
+	})
+}
+
+func (r *QueryChannelsCmd) Run(globals *GlobalContext) error {
+	cat, err := r.load(globals)
+	if err != nil {
+		return err
+	}
+	channels, err := cat.QueryChannels(catalog.QueryFilter{Package: r.Package, Channel: r.Channel})
+	if err != nil {
+		return err
+	}
+	return printQuery(r.Output, channels, []string{"PACKAGE", "CHANNEL", "DEFAULT", "HEAD", "ENTRIES"}, func(tw *tabwriter.Writer) {
+		for _, ch := range channels {
+			head := ch.Head
+			if head == "" {
+				head = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\n", ch.Package, ch.Name, strconv.FormatBool(ch.Default), head, ch.Entries)
+		}
+	})
+}
+
+func (r *QueryBundlesCmd) Run(globals *GlobalContext) error {
+	cat, err := r.load(globals)
+	if err != nil {
+		return err
+	}
+	bundles, err := cat.QueryBundles(r.filter(r.Package))
+	if err != nil {
+		return err
+	}
+	return printQuery(r.Output, bundles, []string{"PACKAGE", "CHANNEL", "BUNDLE", "VERSION", "REPLACES", "SKIPS", "SKIP RANGE"}, func(tw *tabwriter.Writer) {
+		for _, b := range bundles {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", b.Package, b.Channel, b.Name, b.Version, b.Replaces, strings.Join(b.Skips, ","), b.SkipRange)
+		}
+	})
+}
+
+func (r *QueryPropertiesCmd) Run(globals *GlobalContext) error {
+	cat, err := r.load(globals)
+	if err != nil {
+		return err
+	}
+	filter := r.filter(r.Package)
+	filter.PropertyType = r.Type
+	properties, err := cat.QueryProperties(filter)
+	if err != nil {
+		return err
+	}
+	return printQuery(r.Output, properties, []string{"PACKAGE", "BUNDLE", "TYPE", "VALUE"}, func(tw *tabwriter.Writer) {
+		for _, p := range properties {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.Package, p.Bundle, p.Type, p.Value)
+		}
+	})
+}
+
+// printQuery prints query results as indented JSON, or as a table
+// with header written by rows.
+func printQuery[T any](output string, results []T, header []string, rows func(*tabwriter.Writer)) error {
+	if output == "json" {
+		if results == nil {
+			results = []T{}
+		}
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	rows(tw)
+	tw.Flush()
+	fmt.Print(sb.String())
+	return nil
+}
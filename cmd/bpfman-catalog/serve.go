@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/openshift/bpfman-catalog/pkg/catalog"
+	"github.com/openshift/bpfman-catalog/pkg/serve"
+)
+
+// ServeCmd serves a catalog over the registry gRPC API.
+type ServeCmd struct {
+	Catalog string `arg:"" required:"" help:"Rendered catalog file or directory, basic template, or catalog image to serve"`
+	Address string `default:"${default_serve_address}" help:"Address to listen on (host:port)"`
+}
+
+// QueryServedCmd queries a catalog served over the registry gRPC API.
+type QueryServedCmd struct {
+	Address  string `default:"${default_serve_address}" help:"Registry to query (host:port): serve, opm serve or a port-forwarded catalog pod"`
+	Package  string `help:"Only this package"`
+	Replaces string `help:"Also list the channel entries that replace or skip this bundle, e.g. bpfman-operator.v0.5.9"`
+	Output   string `short:"o" default:"table" enum:"table,json" help:"Output format (table, json)"`
+}
+
+func (r *ServeCmd) Run(globals *GlobalContext) error {
+	cat, err := catalog.Load(globals.Context, r.Catalog)
+	if err != nil {
+		return fmt.Errorf("loading catalog %s: %w", r.Catalog, err)
+	}
+	c, err := serve.LoadCache(globals.Context, cat.Config)
+	if err != nil {
+		return fmt.Errorf("loading catalog %s: %w", r.Catalog, err)
+	}
+	defer c.Close()
+
+	lis, err := net.Listen("tcp", r.Address)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", r.Address, err)
+	}
+	globals.Logger.Info("serving catalog", "catalog", r.Catalog, "address", lis.Addr().String())
+	return serve.Serve(globals.Context, lis, c)
+}
+
+// queryServedOutput is the document written by query-served -o json.
+type queryServedOutput struct {
+	Packages     []string            `json:"packages"`
+	Heads        []serve.ChannelHead `json:"heads"`
+	Replacements []serve.Replacement `json:"replacements,omitempty"`
+}
+
+func (r *QueryServedCmd) Run(globals *GlobalContext) error {
+	client, err := serve.NewClient(r.Address)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	packages, err := client.ListPackages(globals.Context)
+	if err != nil {
+		return err
+	}
+	if r.Package != "" {
+		found := false
+		for _, p := range packages {
+			found = found || p == r.Package
+		}
+		if !found {
+			return fmt.Errorf("package %s is not served by %s (served: %s)", r.Package, r.Address, strings.Join(packages, ", "))
+		}
+		packages = []string{r.Package}
+	}
+
+	out := queryServedOutput{Packages: packages, Heads: []serve.ChannelHead{}}
+	for _, p := range packages {
+		heads, err := client.ChannelHeads(globals.Context, p)
+		if err != nil {
+			return err
+		}
+		out.Heads = append(out.Heads, heads...)
+	}
+	if r.Replaces != "" {
+		out.Replacements, err = client.EntriesThatReplace(globals.Context, r.Replaces)
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.Output == "json" {
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format output: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	fmt.Print(formatQueryServedTable(out))
+	return nil
+}
+
+func formatQueryServedTable(out queryServedOutput) string {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tCHANNEL\tDEFAULT\tHEAD\tVERSION\tIMAGE")
+	for _, h := range out.Heads {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", h.Package, h.Channel, strconv.FormatBool(h.Default), h.Bundle, h.Version, h.Image)
+	}
+	tw.Flush()
+
+	if len(out.Replacements) > 0 {
+		sb.WriteString("\n")
+		tw = tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PACKAGE\tCHANNEL\tBUNDLE\tREPLACES")
+		for _, e := range out.Replacements {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Package, e.Channel, e.Bundle, e.Replaces)
+		}
+		tw.Flush()
+	}
+	return sb.String()
+}
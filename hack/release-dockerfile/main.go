@@ -0,0 +1,23 @@
+// Command release-dockerfile writes the repository's release
+// Dockerfile from pkg/bundle/templates/catalog.Dockerfile.tmpl.
+//
+//	go run ./hack/release-dockerfile [path]
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/openshift/bpfman-catalog/pkg/bundle"
+)
+
+func main() {
+	path := "Dockerfile"
+	if len(os.Args) > 1 {
+		path = os.Args[1]
+	}
+	if err := os.WriteFile(path, []byte(bundle.ReleaseDockerfile()), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "writing %s: %v\n", path, err)
+		os.Exit(1)
+	}
+}
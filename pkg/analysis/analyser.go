@@ -2,18 +2,19 @@ package analysis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
-	"github.com/operator-framework/operator-registry/pkg/containertools"
-	"github.com/operator-framework/operator-registry/pkg/image/execregistry"
+	"github.com/openshift/bpfman-catalog/pkg/pullrequest"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
 	"github.com/sirupsen/logrus"
 )
 
 // AnalyseBundle performs analysis of a bundle image.
 func AnalyseBundle(ctx context.Context, bundleRefStr string) (*BundleAnalysis, error) {
 	resolvedRefStr := bundleRefStr
-	if !strings.Contains(bundleRefStr, "@sha256:") {
+	if !strings.Contains(bundleRefStr, "@sha256:") && !registry.IsLocal(bundleRefStr) {
 		logrus.Infof("Resolving tag reference to digest: %s", bundleRefStr)
 		resolved, err := ResolveToDigest(ctx, bundleRefStr)
 		if err != nil {
@@ -46,6 +47,7 @@ func AnalyseBundle(ctx context.Context, bundleRefStr string) (*BundleAnalysis, e
 		return nil, fmt.Errorf("failed to extract bundle metadata: %w", err)
 	}
 	analysis.BundleInfo = bundleInfo
+	resolveBundlePullRequest(ctx, bundleRefStr, bundleInfo)
 
 	logrus.Infof("Extracting image references from bundle")
 	imageRefs, err := ExtractImageReferences(ctx, bundleRef)
@@ -75,6 +77,40 @@ func AnalyseBundle(ctx context.Context, bundleRefStr string) (*BundleAnalysis, e
 	return analysis, nil
 }
 
+// resolveBundlePullRequest records the pull request a bundle referenced
+// by an on-pr-<commit> tag was built for, using the resolver carried
+// by ctx. The tag is taken from the reference as given, since the
+// analysed reference has been resolved to a digest.
+func resolveBundlePullRequest(ctx context.Context, bundleRefStr string, info *ImageInfo) {
+	resolver := pullrequest.ResolverFromContext(ctx)
+	if resolver == nil {
+		return
+	}
+
+	ref, err := ParseImageRef(bundleRefStr)
+	if err != nil || ref.Tag == "" {
+		return
+	}
+
+	pr, err := resolver.ResolveTag(ctx, extractGitHubOwnerRepo(info.GitURL), ref.Tag)
+	if errors.Is(err, pullrequest.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Warnf("failed to resolve pull request for %s", bundleRefStr)
+		return
+	}
+	if pr == nil {
+		return
+	}
+
+	info.PRNumber = pr.Number
+	info.PRTitle = pr.Title
+	info.PRAuthor = pr.Author
+	info.PRState = pr.State
+	info.PRURL = pr.URL
+}
+
 // extractBundleMetadata extracts metadata from the bundle image
 // itself.
 func extractBundleMetadata(ctx context.Context, bundleRef ImageRef, stream string) (*ImageInfo, error) {
@@ -117,13 +153,13 @@ func extractCSVMetadataFromBundle(ctx context.Context, bundleRef ImageRef) (*CSV
 	logger := logrus.NewEntry(logrus.New())
 	logger.Logger.SetLevel(logrus.WarnLevel)
 
-	registry, err := execregistry.NewRegistry(containertools.PodmanTool, logger)
+	reg, err := registry.New(ctx, logger)
 	if err != nil {
 		return nil, fmt.Errorf("creating image registry: %w", err)
 	}
-	defer registry.Destroy()
+	defer reg.Destroy()
 
-	return ExtractCSVMetadata(ctx, bundleRef, registry)
+	return ExtractCSVMetadata(ctx, bundleRef, reg)
 }
 
 // AnalyseConfig holds configuration options for bundle analysis.
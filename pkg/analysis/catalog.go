@@ -0,0 +1,150 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/openshift/bpfman-catalog/pkg/catalog"
+	"github.com/sirupsen/logrus"
+)
+
+// CatalogAnalysis represents analysis results for every bundle in a
+// catalog.
+type CatalogAnalysis struct {
+	Source   string           `json:"source"`
+	Kind     string           `json:"kind"`
+	Packages []CatalogPackage `json:"packages"`
+	Bundles  []CatalogBundle  `json:"bundles"`
+	Summary  CatalogSummary   `json:"summary"`
+}
+
+// CatalogPackage lists the channels of a package in a catalog.
+type CatalogPackage struct {
+	Name           string           `json:"name"`
+	DefaultChannel string           `json:"default_channel,omitempty"`
+	Channels       []CatalogChannel `json:"channels"`
+}
+
+// CatalogChannel lists the bundles in a channel.
+type CatalogChannel struct {
+	Name    string   `json:"name"`
+	Bundles []string `json:"bundles"`
+}
+
+// CatalogBundle contains the analysis of a single bundle in a
+// catalog.
+type CatalogBundle struct {
+	catalog.BundleEntry
+	Analysis *BundleAnalysis `json:"analysis,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// CatalogSummary aggregates image accessibility across all bundles.
+type CatalogSummary struct {
+	TotalBundles       int      `json:"total_bundles"`
+	AnalysedBundles    int      `json:"analysed_bundles"`
+	FailedBundles      int      `json:"failed_bundles"`
+	Images             Summary  `json:"images"`
+	InaccessibleImages []string `json:"inaccessible_images,omitempty"`
+	TenantOnlyImages   []string `json:"tenant_only_images,omitempty"`
+}
+
+// AnalyseCatalog loads a catalog from an image, rendered catalog or
+// basic template and analyses every bundle it contains. A bundle that
+// cannot be analysed is recorded with its error rather than aborting
+// the whole catalog.
+func AnalyseCatalog(ctx context.Context, source string) (*CatalogAnalysis, error) {
+	logrus.Infof("Loading catalog from %s", source)
+	cat, err := catalog.Load(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load catalog: %w", err)
+	}
+
+	result := &CatalogAnalysis{
+		Source:   cat.Source,
+		Kind:     string(cat.Kind),
+		Packages: catalogPackages(cat),
+	}
+
+	entries := cat.Bundles()
+	logrus.Infof("Found %d bundles, analysing each", len(entries))
+	for i, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("operation cancelled: %w", err)
+		}
+
+		logrus.Infof("Analysing bundle %d/%d: %s", i+1, len(entries), entry.Name)
+		bundle := CatalogBundle{BundleEntry: entry}
+		if entry.Image == "" {
+			bundle.Error = "bundle has no image reference"
+		} else if analysis, err := AnalyseBundle(ctx, entry.Image); err != nil {
+			bundle.Error = err.Error()
+		} else {
+			bundle.Analysis = analysis
+		}
+		result.Bundles = append(result.Bundles, bundle)
+	}
+
+	result.Summary = calculateCatalogSummary(result.Bundles)
+	return result, nil
+}
+
+// catalogPackages lists the packages and channels of a catalog.
+func catalogPackages(cat *catalog.Catalog) []CatalogPackage {
+	var packages []CatalogPackage
+	for _, pkg := range cat.Config.Packages {
+		p := CatalogPackage{
+			Name:           pkg.Name,
+			DefaultChannel: pkg.DefaultChannel,
+		}
+		for _, ch := range cat.Config.Channels {
+			if ch.Package != pkg.Name {
+				continue
+			}
+			c := CatalogChannel{Name: ch.Name}
+			for _, entry := range ch.Entries {
+				c.Bundles = append(c.Bundles, entry.Name)
+			}
+			p.Channels = append(p.Channels, c)
+		}
+		packages = append(packages, p)
+	}
+	return packages
+}
+
+// calculateCatalogSummary aggregates per-bundle results into a
+// catalog-wide summary. Images shared between bundles are counted
+// once.
+func calculateCatalogSummary(bundles []CatalogBundle) CatalogSummary {
+	summary := CatalogSummary{
+		TotalBundles: len(bundles),
+	}
+
+	seen := make(map[string]bool)
+	var images []ImageResult
+	for _, b := range bundles {
+		if b.Analysis == nil {
+			summary.FailedBundles++
+			continue
+		}
+		summary.AnalysedBundles++
+		for _, img := range b.Analysis.Images {
+			if seen[img.Reference] {
+				continue
+			}
+			seen[img.Reference] = true
+			images = append(images, img)
+			if !img.Accessible {
+				summary.InaccessibleImages = append(summary.InaccessibleImages, img.Reference)
+			} else if img.Registry == TenantWorkspace {
+				summary.TenantOnlyImages = append(summary.TenantOnlyImages, img.Reference)
+			}
+		}
+	}
+
+	summary.Images = CalculateSummary(images)
+	sort.Strings(summary.InaccessibleImages)
+	sort.Strings(summary.TenantOnlyImages)
+	return summary
+}
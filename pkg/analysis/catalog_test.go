@@ -0,0 +1,66 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCalculateCatalogSummary(t *testing.T) {
+	downstream := ImageResult{Reference: "registry.redhat.io/bpfman/bpfman@sha256:aaaa", Accessible: true, Registry: DownstreamRegistry}
+	tenant := ImageResult{Reference: "registry.redhat.io/bpfman/bpfman-agent@sha256:bbbb", Accessible: true, Registry: TenantWorkspace}
+	missing := ImageResult{Reference: "registry.redhat.io/bpfman/bpfman-operator@sha256:cccc", Registry: NotAccessible}
+	missingToo := ImageResult{Reference: "registry.redhat.io/bpfman/bpfman-operator@sha256:0000", Registry: NotAccessible}
+
+	analysed := func(images ...ImageResult) CatalogBundle {
+		return CatalogBundle{Analysis: &BundleAnalysis{Images: images}}
+	}
+	failed := CatalogBundle{Error: "pull failed"}
+
+	tests := []struct {
+		name    string
+		bundles []CatalogBundle
+		want    CatalogSummary
+	}{
+		{
+			name: "empty",
+			want: CatalogSummary{},
+		},
+		{
+			name:    "images shared between bundles count once",
+			bundles: []CatalogBundle{analysed(downstream, tenant), analysed(downstream, tenant)},
+			want: CatalogSummary{
+				TotalBundles:     2,
+				AnalysedBundles:  2,
+				Images:           Summary{TotalImages: 2, AccessibleImages: 2, DownstreamImages: 1, TenantImages: 1},
+				TenantOnlyImages: []string{tenant.Reference},
+			},
+		},
+		{
+			name:    "inaccessible images are listed once and sorted",
+			bundles: []CatalogBundle{analysed(missing, downstream), analysed(missingToo, missing)},
+			want: CatalogSummary{
+				TotalBundles:       2,
+				AnalysedBundles:    2,
+				Images:             Summary{TotalImages: 3, AccessibleImages: 1, DownstreamImages: 1, InaccessibleImages: 2},
+				InaccessibleImages: []string{missingToo.Reference, missing.Reference},
+			},
+		},
+		{
+			name:    "failed bundles contribute no images",
+			bundles: []CatalogBundle{failed, analysed(downstream), failed},
+			want: CatalogSummary{
+				TotalBundles:    3,
+				AnalysedBundles: 1,
+				FailedBundles:   2,
+				Images:          Summary{TotalImages: 1, AccessibleImages: 1, DownstreamImages: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calculateCatalogSummary(tt.bundles); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("calculateCatalogSummary =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,14 +8,11 @@ import (
 	"regexp"
 	"strings"
 
-	"github.com/containers/image/v5/docker"
-	"github.com/containers/image/v5/docker/reference"
-	"github.com/containers/image/v5/types"
+	"github.com/openshift/bpfman-catalog/pkg/imageref"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
 	"github.com/operator-framework/operator-registry/alpha/action"
 	"github.com/operator-framework/operator-registry/alpha/action/migrations"
-	"github.com/operator-framework/operator-registry/pkg/containertools"
 	"github.com/operator-framework/operator-registry/pkg/image"
-	"github.com/operator-framework/operator-registry/pkg/image/execregistry"
 	"github.com/sirupsen/logrus"
 	"sigs.k8s.io/yaml"
 )
@@ -30,11 +27,11 @@ func ExtractImageReferences(ctx context.Context, bundleRef ImageRef) ([]string,
 	logger := logrus.NewEntry(logrus.New())
 	logger.Logger.SetLevel(logrus.WarnLevel)
 
-	registry, err := execregistry.NewRegistry(containertools.PodmanTool, logger)
+	reg, err := registry.New(ctx, logger)
 	if err != nil {
 		return nil, fmt.Errorf("creating image registry: %w", err)
 	}
-	defer registry.Destroy()
+	defer reg.Destroy()
 
 	migs, err := migrations.NewMigrations("bundle-object-to-csv-metadata")
 	if err != nil {
@@ -43,7 +40,7 @@ func ExtractImageReferences(ctx context.Context, bundleRef ImageRef) ([]string,
 
 	r := action.Render{
 		Refs:           []string{bundleRef.String()},
-		Registry:       registry,
+		Registry:       reg,
 		AllowedRefMask: action.RefBundleImage,
 		Migrations:     migs,
 	}
@@ -69,7 +66,7 @@ func ExtractImageReferences(ctx context.Context, bundleRef ImageRef) ([]string,
 		}
 	}
 
-	configmapImages, err := extractConfigMapImages(ctx, bundleRef, registry)
+	configmapImages, err := extractConfigMapImages(ctx, bundleRef, reg)
 	if err != nil {
 		logrus.WithError(err).Warn("failed to extract configmap images")
 	} else {
@@ -97,7 +94,7 @@ func deduplicateStrings(slice []string) []string {
 // extractConfigMapImages extracts image references from the bpfman-config ConfigMap
 // in the bundle manifests. These images (daemon and agent) are not tracked in
 // relatedImages but are configured via ConfigMap at runtime.
-func extractConfigMapImages(ctx context.Context, bundleRef ImageRef, registry image.Registry) ([]string, error) {
+func extractConfigMapImages(ctx context.Context, bundleRef ImageRef, reg image.Registry) ([]string, error) {
 	tmpDir, err := os.MkdirTemp("", "bundle-manifests-*")
 	if err != nil {
 		return nil, fmt.Errorf("creating temp directory: %w", err)
@@ -105,7 +102,7 @@ func extractConfigMapImages(ctx context.Context, bundleRef ImageRef, registry im
 	defer os.RemoveAll(tmpDir)
 
 	ref := image.SimpleReference(bundleRef.String())
-	if err := registry.Unpack(ctx, ref, tmpDir); err != nil {
+	if err := reg.Unpack(ctx, ref, tmpDir); err != nil {
 		return nil, fmt.Errorf("unpacking bundle image: %w", err)
 	}
 
@@ -137,7 +134,7 @@ type CSVMetadata struct {
 }
 
 // ExtractCSVMetadata extracts version and createdAt from the ClusterServiceVersion in a bundle image.
-func ExtractCSVMetadata(ctx context.Context, bundleRef ImageRef, registry image.Registry) (*CSVMetadata, error) {
+func ExtractCSVMetadata(ctx context.Context, bundleRef ImageRef, reg image.Registry) (*CSVMetadata, error) {
 	tmpDir, err := os.MkdirTemp("", "bundle-csv-*")
 	if err != nil {
 		return nil, fmt.Errorf("creating temp directory: %w", err)
@@ -145,7 +142,10 @@ func ExtractCSVMetadata(ctx context.Context, bundleRef ImageRef, registry image.
 	defer os.RemoveAll(tmpDir)
 
 	ref := image.SimpleReference(bundleRef.String())
-	if err := registry.Unpack(ctx, ref, tmpDir); err != nil {
+	if err := reg.Pull(ctx, ref); err != nil {
+		return nil, fmt.Errorf("pulling bundle image: %w", err)
+	}
+	if err := reg.Unpack(ctx, ref, tmpDir); err != nil {
 		return nil, fmt.Errorf("unpacking bundle image: %w", err)
 	}
 
@@ -199,27 +199,21 @@ func ExtractCSVMetadata(ctx context.Context, bundleRef ImageRef, registry image.
 // ResolveToDigest resolves an image reference to a digest-based reference.
 // If the reference already uses a digest (@sha256:...), it returns it unchanged.
 // If the reference uses a tag (:latest, :v1.0, etc.), it inspects the image
-// and returns a digest-based reference for reproducibility.
+// and returns a digest-based reference for reproducibility. References
+// to local OCI layouts, archives and directories are returned unchanged
+// because those transports cannot be addressed by digest.
 func ResolveToDigest(ctx context.Context, imageRef string) (string, error) {
-	if strings.Contains(imageRef, "@sha256:") {
-		return imageRef, nil
-	}
-
-	named, err := reference.ParseNormalizedNamed(imageRef)
+	ref, err := imageref.Parse(imageRef)
 	if err != nil {
-		return "", fmt.Errorf("parsing image reference: %w", err)
+		return "", err
 	}
-
-	ref, err := docker.ParseReference("//" + imageRef)
-	if err != nil {
-		return "", fmt.Errorf("parsing docker reference: %w", err)
+	if ref.IsLocal() || ref.Digest != "" {
+		return imageRef, nil
 	}
 
-	sys := &types.SystemContext{}
-	manifestDigest, err := docker.GetDigest(ctx, sys, ref)
+	ref.Digest, err = registry.Digest(ctx, imageRef)
 	if err != nil {
 		return "", fmt.Errorf("getting image digest: %w", err)
 	}
-
-	return fmt.Sprintf("%s@%s", reference.FamiliarName(named), manifestDigest.String()), nil
+	return ref.DigestRef(), nil
 }
@@ -0,0 +1,253 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/openshift/bpfman-catalog/pkg/bundle"
+	"github.com/openshift/bpfman-catalog/pkg/cachedir"
+	"github.com/openshift/bpfman-catalog/pkg/catalog"
+	"github.com/sirupsen/logrus"
+)
+
+// maxFindConcurrency bounds the bundles rendered at once, each of
+// which pulls and unpacks an image.
+const maxFindConcurrency = 4
+
+// FindOptions selects where FindBundles looks for an image.
+type FindOptions struct {
+	Repositories []bundle.BundleRef // Bundle repositories to scan
+	List         bundle.ListOptions // Which bundles of each repository to scan
+	Catalogs     []string           // Catalogs to check for matching bundles
+	CacheDir     string             // Directory of cached bundle image references ("" disables)
+}
+
+// FindResult lists the bundles, and catalogs, that reference an
+// image digest.
+type FindResult struct {
+	Image    string         `json:"image"`
+	Digest   digest.Digest  `json:"digest"`
+	Scanned  int            `json:"scanned_bundles"`
+	Bundles  []BundleMatch  `json:"bundles"`
+	Catalogs []CatalogMatch `json:"catalogs,omitempty"`
+	Failures []FindFailure  `json:"failures,omitempty"`
+}
+
+// BundleMatch is a bundle build that references the image.
+type BundleMatch struct {
+	Repository string        `json:"repository"`
+	Tag        string        `json:"tag"`
+	Digest     digest.Digest `json:"digest"`
+	Image      string        `json:"image"`
+	Version    string        `json:"version,omitempty"`
+	BuildDate  string        `json:"build_date,omitempty"`
+	References []string      `json:"references"`
+}
+
+// CatalogMatch is a catalog bundle entry for a matching bundle build.
+type CatalogMatch struct {
+	Catalog  string   `json:"catalog"`
+	Package  string   `json:"package"`
+	Bundle   string   `json:"bundle"`
+	Channels []string `json:"channels"`
+	Image    string   `json:"image"`
+}
+
+// FindFailure records a repository, bundle or catalog that could not
+// be searched.
+type FindFailure struct {
+	Source string `json:"source"`
+	Error  string `json:"error"`
+}
+
+// referenceDigest returns the digest of a reference pinned by digest.
+func referenceDigest(ref string) (digest.Digest, bool) {
+	_, d, ok := strings.Cut(ref, "@")
+	if !ok {
+		return "", false
+	}
+	parsed, err := digest.Parse(d)
+	if err != nil {
+		return "", false
+	}
+	return parsed, true
+}
+
+// FindBundles scans the bundle builds selected by opts for references
+// to imageRef, which must be pinned by digest. A bundle matches when
+// its relatedImages or bpfman ConfigMap reference the same digest,
+// whichever registry they name, since mirrored images keep their
+// digest. The references found in each bundle are cached by bundle
+// digest, so rescanning a bundle is free.
+func FindBundles(ctx context.Context, imageRef string, opts FindOptions) (*FindResult, error) {
+	target, ok := referenceDigest(imageRef)
+	if !ok {
+		return nil, fmt.Errorf("image %s must be pinned by digest (name@sha256:...)", imageRef)
+	}
+
+	result := &FindResult{Image: imageRef, Digest: target, Bundles: []BundleMatch{}}
+
+	type candidate struct {
+		repo     bundle.BundleRef
+		metadata *bundle.BundleMetadata
+	}
+	var candidates []candidate
+	for _, repo := range opts.Repositories {
+		logrus.Infof("Listing bundles in %s", repo)
+		bundles, failures, err := bundle.ListBundles(ctx, repo, opts.List)
+		if err != nil {
+			result.Failures = append(result.Failures, FindFailure{Source: repo.String(), Error: err.Error()})
+			continue
+		}
+		for _, f := range failures {
+			result.Failures = append(result.Failures, FindFailure{Source: repo.TaggedRef(f.Tag), Error: f.Error})
+		}
+		for _, b := range bundles {
+			candidates = append(candidates, candidate{repo: repo, metadata: b})
+		}
+	}
+	result.Scanned = len(candidates)
+
+	logrus.Infof("Scanning %d bundles for %s", len(candidates), target)
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		semaphore = make(chan struct{}, maxFindConcurrency)
+	)
+	for _, c := range candidates {
+		wg.Add(1)
+		go func(c candidate) {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				return
+			}
+
+			bundleImage := c.repo.DigestRef(c.metadata)
+			refs, err := bundleReferences(ctx, bundleImage, c.metadata.Digest, opts.CacheDir)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Failures = append(result.Failures, FindFailure{Source: bundleImage, Error: err.Error()})
+				return
+			}
+
+			var matching []string
+			for _, ref := range refs {
+				if d, ok := referenceDigest(ref); ok && d == target {
+					matching = append(matching, ref)
+				}
+			}
+			if len(matching) == 0 {
+				return
+			}
+			result.Bundles = append(result.Bundles, BundleMatch{
+				Repository: c.repo.String(),
+				Tag:        c.metadata.Tag,
+				Digest:     c.metadata.Digest,
+				Image:      bundleImage,
+				Version:    c.metadata.Version,
+				BuildDate:  c.metadata.BuildDate,
+				References: matching,
+			})
+		}(c)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("operation cancelled: %w", err)
+	}
+
+	sort.Slice(result.Bundles, func(i, j int) bool {
+		if result.Bundles[i].BuildDate != result.Bundles[j].BuildDate {
+			return result.Bundles[i].BuildDate > result.Bundles[j].BuildDate
+		}
+		return result.Bundles[i].Image < result.Bundles[j].Image
+	})
+	sort.Slice(result.Failures, func(i, j int) bool { return result.Failures[i].Source < result.Failures[j].Source })
+
+	matched := make(map[digest.Digest]bool, len(result.Bundles))
+	for _, m := range result.Bundles {
+		matched[m.Digest] = true
+	}
+	for _, source := range opts.Catalogs {
+		matches, err := findInCatalog(ctx, source, matched)
+		if err != nil {
+			result.Failures = append(result.Failures, FindFailure{Source: source, Error: err.Error()})
+			continue
+		}
+		result.Catalogs = append(result.Catalogs, matches...)
+	}
+
+	return result, nil
+}
+
+// findInCatalog returns the bundle entries of a catalog whose image
+// is one of the matched bundle digests.
+func findInCatalog(ctx context.Context, source string, matched map[digest.Digest]bool) ([]CatalogMatch, error) {
+	logrus.Infof("Checking catalog %s", source)
+	cat, err := catalog.Load(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []CatalogMatch
+	for _, entry := range cat.Bundles() {
+		if d, ok := referenceDigest(entry.Image); ok && matched[d] {
+			matches = append(matches, CatalogMatch{
+				Catalog:  source,
+				Package:  entry.Package,
+				Bundle:   entry.Name,
+				Channels: entry.Channels,
+				Image:    entry.Image,
+			})
+		}
+	}
+	return matches, nil
+}
+
+// bundleReferences returns the image references in a bundle, from the
+// cache when the bundle digest has been seen before.
+func bundleReferences(ctx context.Context, bundleImage string, bundleDigest digest.Digest, cacheDir string) ([]string, error) {
+	cachePath := ""
+	if cacheDir != "" && bundleDigest != "" {
+		cachePath = filepath.Join(cacheDir, bundleDigest.Encoded()+".json")
+		if data, err := os.ReadFile(cachePath); err == nil {
+			var refs []string
+			if err := json.Unmarshal(data, &refs); err == nil {
+				return refs, nil
+			}
+		}
+	}
+
+	ref, err := ParseImageRef(bundleImage)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := ExtractImageReferences(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if cachePath != "" {
+		data, err := json.Marshal(refs)
+		if err == nil {
+			err = cachedir.WriteFile(cachePath, data)
+		}
+		if err != nil {
+			logrus.WithError(err).Warnf("not caching image references of %s", bundleImage)
+		}
+	}
+	return refs, nil
+}
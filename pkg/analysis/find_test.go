@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/openshift/bpfman-catalog/pkg/bundle"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
+	"github.com/openshift/bpfman-catalog/pkg/registry/registrytest"
+)
+
+// TestFindBundles matches bundles by the digest of the images they
+// reference, whichever registry names them, using cached references
+// so no bundle has to be rendered.
+func TestFindBundles(t *testing.T) {
+	srv := registrytest.NewServer(t)
+	cacheDir := t.TempDir()
+
+	operator := digest.FromString("operator")
+	other := digest.FromString("other")
+	repo := "tenant/bpfman-operator-bundle-ystream"
+
+	push := func(commit, buildDate string, refs ...string) digest.Digest {
+		d := srv.Push(t, repo, strings.Repeat(commit, 40), registrytest.Image{
+			Labels: map[string]string{"build-date": buildDate, "version": "0.6.0"},
+		})
+		data, err := json.Marshal(refs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(cacheDir, d.Encoded()+".json"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	older := push("a", "2025-01-01T00:00:00Z", "quay.io/tenant/bpfman-operator@"+operator.String())
+	newer := push("b", "2025-02-01T00:00:00Z", "registry.redhat.io/bpfman/bpfman-rhel9-operator@"+operator.String())
+	push("c", "2025-03-01T00:00:00Z", "quay.io/tenant/bpfman-operator@"+other.String())
+
+	catalogPath := filepath.Join(t.TempDir(), "catalog.yaml")
+	catalogYAML := fmt.Sprintf(`---
+schema: olm.package
+name: bpfman-operator
+defaultChannel: latest
+---
+schema: olm.channel
+package: bpfman-operator
+name: latest
+entries:
+  - name: bpfman-operator.v0.6.0
+---
+schema: olm.bundle
+package: bpfman-operator
+name: bpfman-operator.v0.6.0
+image: registry.redhat.io/bpfman/bpfman-operator-bundle@%s
+properties:
+  - type: olm.package
+    value:
+      packageName: bpfman-operator
+      version: 0.6.0
+`, newer)
+	if err := os.WriteFile(catalogPath, []byte(catalogYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := registry.DefaultOptions()
+	opts.TLSVerify = false
+	ctx := registry.WithOptions(context.Background(), opts)
+
+	bundleRef, err := bundle.ParseBundleRef(srv.Host() + "/" + repo)
+	if err != nil {
+		t.Fatalf("ParseBundleRef: %v", err)
+	}
+
+	result, err := FindBundles(ctx, "example.com/mirror/operator@"+operator.String(), FindOptions{
+		Repositories: []bundle.BundleRef{bundleRef},
+		Catalogs:     []string{catalogPath},
+		CacheDir:     cacheDir,
+	})
+	if err != nil {
+		t.Fatalf("FindBundles: %v", err)
+	}
+
+	if result.Scanned != 3 {
+		t.Errorf("scanned %d bundles, want 3", result.Scanned)
+	}
+	if len(result.Failures) != 0 {
+		t.Errorf("unexpected failures: %v", result.Failures)
+	}
+	if len(result.Bundles) != 2 {
+		t.Fatalf("got %d matching bundles, want 2", len(result.Bundles))
+	}
+	if result.Bundles[0].Digest != newer || result.Bundles[1].Digest != older {
+		t.Errorf("matches = %s, %s; want %s, %s newest first",
+			result.Bundles[0].Digest, result.Bundles[1].Digest, newer, older)
+	}
+	if len(result.Catalogs) != 1 || result.Catalogs[0].Bundle != "bpfman-operator.v0.6.0" {
+		t.Errorf("catalog matches = %+v, want bpfman-operator.v0.6.0", result.Catalogs)
+	}
+}
+
+func TestFindBundlesRequiresDigest(t *testing.T) {
+	if _, err := FindBundles(context.Background(), "quay.io/tenant/bpfman-operator:latest", FindOptions{}); err == nil {
+		t.Error("expected error for an image not pinned by digest")
+	}
+}
@@ -53,13 +53,19 @@ func formatText(analysis *BundleAnalysis) string {
 			b.WriteString(fmt.Sprintf("  Git: %s\n", commitURL))
 		}
 		if analysis.BundleInfo.PRNumber > 0 {
-			prURL := buildPRURL(analysis.BundleInfo.GitURL, analysis.BundleInfo.PRNumber)
+			prURL := analysis.BundleInfo.PRURL
+			if prURL == "" {
+				prURL = buildPRURL(analysis.BundleInfo.GitURL, analysis.BundleInfo.PRNumber)
+			}
 			if prURL != "" {
 				title := analysis.BundleInfo.PRTitle
 				if title == "" {
 					title = fmt.Sprintf("PR #%d", analysis.BundleInfo.PRNumber)
 				}
 				b.WriteString(fmt.Sprintf("  PR: %s - %s\n", prURL, title))
+				if analysis.BundleInfo.PRAuthor != "" || analysis.BundleInfo.PRState != "" {
+					b.WriteString(fmt.Sprintf("  PR author: %s, state: %s\n", analysis.BundleInfo.PRAuthor, analysis.BundleInfo.PRState))
+				}
 			}
 		}
 	}
@@ -109,6 +115,8 @@ func formatImageResult(img ImageResult) string {
 		if img.TenantRef != "" {
 			b.WriteString(fmt.Sprintf("    Source: %s\n", img.TenantRef))
 		}
+	case LocalImage:
+		b.WriteString("    ✓ Read from local image (OCI layout, archive or directory)\n")
 	default:
 		b.WriteString("    ✗ Registry status unknown\n")
 	}
@@ -219,3 +227,127 @@ func identifyComponent(imageRef string) string {
 
 	return ""
 }
+
+// FormatCatalogResult formats catalog analysis results according to
+// the specified format.
+func FormatCatalogResult(analysis *CatalogAnalysis, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "json":
+		data, err := json.MarshalIndent(analysis, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		return string(data), nil
+	case "text", "":
+		return formatCatalogText(analysis), nil
+	default:
+		return "", fmt.Errorf("unsupported format: %s (supported: text, json)", format)
+	}
+}
+
+// formatCatalogText returns human-readable catalog analysis results:
+// the catalog layout, a catalog-wide summary and then one section per
+// bundle.
+func formatCatalogText(analysis *CatalogAnalysis) string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("Catalog: %s (%s)\n", analysis.Source, analysis.Kind))
+	for _, pkg := range analysis.Packages {
+		b.WriteString(fmt.Sprintf("  Package: %s (default channel: %s)\n", pkg.Name, pkg.DefaultChannel))
+		for _, ch := range pkg.Channels {
+			b.WriteString(fmt.Sprintf("    Channel: %s\n", ch.Name))
+			for _, name := range ch.Bundles {
+				b.WriteString(fmt.Sprintf("      - %s\n", name))
+			}
+		}
+	}
+	b.WriteString("\n")
+
+	summary := analysis.Summary
+	b.WriteString(fmt.Sprintf("Catalog summary: %d bundles, %d analysed", summary.TotalBundles, summary.AnalysedBundles))
+	if summary.FailedBundles > 0 {
+		b.WriteString(fmt.Sprintf(", %d failed", summary.FailedBundles))
+	}
+	b.WriteString("\n")
+	b.WriteString(formatSummary(summary.Images))
+	if len(summary.InaccessibleImages) > 0 {
+		b.WriteString(fmt.Sprintf("\nInaccessible images (%d):\n", len(summary.InaccessibleImages)))
+		for _, ref := range summary.InaccessibleImages {
+			b.WriteString(fmt.Sprintf("  ✗ %s\n", ref))
+		}
+	}
+	if len(summary.TenantOnlyImages) > 0 {
+		b.WriteString(fmt.Sprintf("\nTenant workspace only images (%d):\n", len(summary.TenantOnlyImages)))
+		for _, ref := range summary.TenantOnlyImages {
+			b.WriteString(fmt.Sprintf("  ⚠ %s\n", ref))
+		}
+	}
+
+	for _, bundle := range analysis.Bundles {
+		b.WriteString(fmt.Sprintf("\n--- %s/%s (channels: %s) ---\n", bundle.Package, bundle.Name, strings.Join(bundle.Channels, ", ")))
+		if bundle.Analysis == nil {
+			b.WriteString(fmt.Sprintf("Bundle: %s\n", bundle.Image))
+			b.WriteString(fmt.Sprintf("  ✗ %s\n", bundle.Error))
+			continue
+		}
+		b.WriteString(formatText(bundle.Analysis))
+	}
+
+	return b.String()
+}
+
+// FormatFindResult formats the bundles found to reference an image
+// according to the specified format.
+func FormatFindResult(result *FindResult, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "json":
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		return string(data) + "\n", nil
+	case "text", "":
+		return formatFindText(result), nil
+	default:
+		return "", fmt.Errorf("unsupported format: %s (supported: text, json)", format)
+	}
+}
+
+// formatFindText returns human-readable find results: the matching
+// bundles, newest first, then the catalogs that ship them.
+func formatFindText(result *FindResult) string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("Image: %s\n", result.Image))
+	b.WriteString(fmt.Sprintf("Scanned %d bundles, %d reference %s\n", result.Scanned, len(result.Bundles), result.Digest))
+
+	for _, m := range result.Bundles {
+		b.WriteString(fmt.Sprintf("\n✓ %s\n", m.Image))
+		b.WriteString(fmt.Sprintf("  Tag: %s\n", m.Tag))
+		if m.Version != "" {
+			b.WriteString(fmt.Sprintf("  Version: %s\n", m.Version))
+		}
+		if m.BuildDate != "" {
+			b.WriteString(fmt.Sprintf("  Build Date: %s\n", m.BuildDate))
+		}
+		for _, ref := range m.References {
+			b.WriteString(fmt.Sprintf("  References: %s\n", ref))
+		}
+	}
+
+	if len(result.Catalogs) > 0 {
+		b.WriteString(fmt.Sprintf("\nCatalogs (%d entries):\n", len(result.Catalogs)))
+		for _, c := range result.Catalogs {
+			b.WriteString(fmt.Sprintf("  %s: %s/%s (channels: %s)\n", c.Catalog, c.Package, c.Bundle, strings.Join(c.Channels, ", ")))
+		}
+	}
+
+	if len(result.Failures) > 0 {
+		b.WriteString(fmt.Sprintf("\nNot searched (%d):\n", len(result.Failures)))
+		for _, f := range result.Failures {
+			b.WriteString(fmt.Sprintf("  ✗ %s: %s\n", f.Source, f.Error))
+		}
+	}
+
+	return b.String()
+}
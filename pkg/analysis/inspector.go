@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/containers/image/v5/docker"
 	"github.com/containers/image/v5/types"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
 	"github.com/sirupsen/logrus"
 )
 
@@ -34,7 +34,9 @@ func InspectImage(ctx context.Context, imageRefStr string, stream string) (*Imag
 	logrus.Debugf("Attempting to inspect: %s", imageRef.String())
 	if info, err := inspectImageRef(ctx, imageRef); err == nil {
 		result.Accessible = true
-		if imageRef.Registry == "registry.redhat.io" {
+		if imageRef.Transport != "" {
+			result.Registry = LocalImage
+		} else if imageRef.Registry == "registry.redhat.io" {
 			result.Registry = DownstreamRegistry
 		} else if imageRef.Registry == "quay.io" && strings.Contains(imageRef.Repo, "redhat-user-workloads") {
 			result.Registry = TenantWorkspace
@@ -78,19 +80,23 @@ func InspectImage(ctx context.Context, imageRefStr string, stream string) (*Imag
 func inspectImageRef(ctx context.Context, imageRef ImageRef) (*types.ImageInspectInfo, error) {
 	logrus.Debugf("inspectImageRef: %s", imageRef.String())
 
-	ref, err := docker.ParseReference("//" + imageRef.String())
+	ref, err := registry.ParseReference(imageRef.String())
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse reference: %w", err)
 	}
 
-	systemCtx := &types.SystemContext{}
-	img, err := ref.NewImage(ctx, systemCtx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create image: %w", err)
-	}
-	defer img.Close()
+	systemCtx := registry.SystemContext(ctx)
+	var info *types.ImageInspectInfo
+	err = registry.Retry(ctx, func() error {
+		img, err := ref.NewImage(ctx, systemCtx)
+		if err != nil {
+			return fmt.Errorf("failed to create image: %w", err)
+		}
+		defer img.Close()
 
-	info, err := img.Inspect(ctx)
+		info, err = img.Inspect(ctx)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -129,10 +135,6 @@ func convertToImageInfo(info *types.ImageInspectInfo) *ImageInfo {
 		} else if url := info.Labels["vcs-url"]; url != "" {
 			imageInfo.GitURL = url
 		}
-
-		if buildName := info.Labels["io.openshift.build.name"]; buildName != "" {
-			imageInfo.PRTitle = buildName
-		}
 	}
 
 	if imageInfo.GitCommit != "" && imageInfo.GitURL != "" {
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"os/exec"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
@@ -44,7 +43,6 @@ func extractMetadataFromLabels(info *types.ImageInspectInfo) *ImageInfo {
 	metadata.Version = extractVersion(labels)
 	metadata.GitCommit = extractGitCommit(labels)
 	metadata.GitURL = extractGitURL(labels)
-	metadata.PRNumber, metadata.PRTitle = extractPRInfo(labels)
 
 	if metadata.GitCommit != "" && metadata.GitURL != "" {
 		if commitDate := fetchCommitDate(metadata.GitURL, metadata.GitCommit); commitDate != nil {
@@ -140,26 +138,6 @@ func extractGitURL(labels map[string]string) string {
 	return ""
 }
 
-// extractPRInfo attempts to extract PR number and title from various
-// sources.
-func extractPRInfo(labels map[string]string) (int, string) {
-	prKeys := []string{
-		"io.openshift.build.name",
-		"build.name",
-		"name",
-	}
-
-	for _, key := range prKeys {
-		if value := labels[key]; value != "" {
-			if prNum := extractPRNumber(value); prNum > 0 {
-				return prNum, value
-			}
-		}
-	}
-
-	return 0, ""
-}
-
 // isValidCommitHash checks if a string looks like a git commit hash.
 func isValidCommitHash(s string) bool {
 	if len(s) < 7 || len(s) > 40 {
@@ -185,27 +163,6 @@ func cleanGitURL(rawURL string) string {
 	return rawURL
 }
 
-// extractPRNumber attempts to extract a PR number from a string.
-func extractPRNumber(s string) int {
-	patterns := []string{
-		`pr-(\d+)`,
-		`pull-(\d+)`,
-		`#(\d+)`,
-		`-(\d+)$`, // Number at the end
-	}
-
-	for _, pattern := range patterns {
-		re := regexp.MustCompile(pattern)
-		if matches := re.FindStringSubmatch(s); len(matches) > 1 {
-			if num, err := strconv.Atoi(matches[1]); err == nil {
-				return num
-			}
-		}
-	}
-
-	return 0
-}
-
 // fetchCommitDate fetches the commit date from GitHub using the gh CLI.
 // Returns nil if gh is not available or the fetch fails.
 func fetchCommitDate(gitURL, commitHash string) *time.Time {
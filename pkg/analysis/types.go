@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/openshift/bpfman-catalog/pkg/imageref"
 )
 
 // BundleAnalysis represents complete analysis results for a bundle
@@ -37,6 +40,9 @@ type ImageInfo struct {
 	CommitDate   *time.Time `json:"commit_date,omitempty"`
 	PRNumber     int        `json:"pr_number,omitempty"`
 	PRTitle      string     `json:"pr_title,omitempty"`
+	PRAuthor     string     `json:"pr_author,omitempty"`
+	PRState      string     `json:"pr_state,omitempty"`
+	PRURL        string     `json:"pr_url,omitempty"`
 }
 
 // Summary provides aggregate statistics from the analysis.
@@ -55,89 +61,51 @@ const (
 	DownstreamRegistry RegistryType = "downstream"
 	TenantWorkspace    RegistryType = "tenant"
 	NotAccessible      RegistryType = "inaccessible"
+	LocalImage         RegistryType = "local"
 )
 
-// ImageRef represents a parsed container image reference.
+// ImageRef represents a parsed container image reference. References
+// using a local transport (oci:, oci-archive:, docker-archive: or
+// dir:) carry the transport name and keep the transport-specific
+// remainder in Repo.
 type ImageRef struct {
-	Registry string
-	Repo     string
-	Tag      string
-	Digest   string
+	Transport string
+	Registry  string
+	Repo      string
+	Tag       string
+	Digest    string
 }
 
-// String returns the full image reference string.
+// String returns the full image reference string: pinned by digest
+// if there is one, otherwise by tag.
 func (r ImageRef) String() string {
-	if r.Digest != "" {
-		return fmt.Sprintf("%s/%s@%s", r.Registry, r.Repo, r.Digest)
-	}
-	if r.Tag != "" {
-		return fmt.Sprintf("%s/%s:%s", r.Registry, r.Repo, r.Tag)
+	ref := imageref.Reference{Registry: r.Registry, Repository: r.Repo, Tag: r.Tag, Digest: digest.Digest(r.Digest)}
+	if r.Transport != "" {
+		ref = imageref.Reference{Transport: r.Transport, Path: r.Repo}
 	}
-	return fmt.Sprintf("%s/%s", r.Registry, r.Repo)
+	return ref.DigestRef()
 }
 
 // ParseImageRef parses a container image reference string into
-// components.
+// components; see imageref.Parse. Registry references without a tag
+// or digest are tagged latest, as docker and podman pull them.
 func ParseImageRef(ref string) (ImageRef, error) {
-	if ref == "" {
-		return ImageRef{}, fmt.Errorf("empty image reference")
+	parsed, err := imageref.Parse(ref)
+	if err != nil {
+		return ImageRef{}, err
 	}
-
-	// Handle digest-based references (preferred).
-	if strings.Contains(ref, "@sha256:") {
-		parts := strings.Split(ref, "@")
-		if len(parts) != 2 {
-			return ImageRef{}, fmt.Errorf("invalid digest reference format: %s", ref)
-		}
-
-		registryRepo := parts[0]
-		digest := parts[1]
-
-		slashIndex := strings.Index(registryRepo, "/")
-		if slashIndex == -1 {
-			return ImageRef{}, fmt.Errorf("invalid reference format, missing registry: %s", ref)
-		}
-
-		result := ImageRef{
-			Registry: registryRepo[:slashIndex],
-			Repo:     registryRepo[slashIndex+1:],
-			Digest:   digest,
-		}
-
-		if result.Digest == "" {
-			return ImageRef{}, fmt.Errorf("digest-based reference has empty digest: %s", ref)
-		}
-
-		return result, nil
+	if parsed.IsLocal() {
+		return ImageRef{Transport: parsed.Transport, Repo: parsed.Path}, nil
 	}
-
-	var registry, repo, tag string
-	slashIndex := strings.Index(ref, "/")
-	if slashIndex == -1 {
-		return ImageRef{}, fmt.Errorf("invalid reference format, missing registry: %s", ref)
+	if parsed.Tag == "" && parsed.Digest == "" {
+		parsed.Tag = "latest"
 	}
-
-	registry = ref[:slashIndex]
-	repoWithTag := ref[slashIndex+1:]
-
-	if colonIndex := strings.LastIndex(repoWithTag, ":"); colonIndex != -1 {
-		repo = repoWithTag[:colonIndex]
-		tag = repoWithTag[colonIndex+1:]
-	} else {
-		repo = repoWithTag
-	}
-
-	result := ImageRef{
-		Registry: registry,
-		Repo:     repo,
-		Tag:      tag,
-	}
-
-	if result.Tag == "" {
-		return ImageRef{}, fmt.Errorf("image reference missing tag (and not digest-based): %s", ref)
-	}
-
-	return result, nil
+	return ImageRef{
+		Registry: parsed.Registry,
+		Repo:     parsed.Repository,
+		Tag:      parsed.Tag,
+		Digest:   parsed.Digest.String(),
+	}, nil
 }
 
 // DetectStreamFromRepo detects the stream (ystream/zstream) from a repository name.
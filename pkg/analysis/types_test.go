@@ -0,0 +1,28 @@
+package analysis
+
+import "testing"
+
+func TestParseImageRef(t *testing.T) {
+	for _, tc := range []struct {
+		ref  string
+		want string
+	}{
+		{ref: "quay.io/bpfman/bpfman-operator-bundle", want: "quay.io/bpfman/bpfman-operator-bundle:latest"},
+		{ref: "quay.io/bpfman/bpfman-operator-bundle:v0.6.0", want: "quay.io/bpfman/bpfman-operator-bundle:v0.6.0"},
+		{
+			ref:  "quay.io/bpfman/bpfman-operator-bundle:v0.6.0@sha256:1111111111111111111111111111111111111111111111111111111111111111",
+			want: "quay.io/bpfman/bpfman-operator-bundle@sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		},
+		{ref: "busybox", want: "docker.io/library/busybox:latest"},
+		{ref: "oci:/tmp/layout:v1", want: "oci:/tmp/layout:v1"},
+	} {
+		ref, err := ParseImageRef(tc.ref)
+		if err != nil {
+			t.Errorf("ParseImageRef(%q): %v", tc.ref, err)
+			continue
+		}
+		if got := ref.String(); got != tc.want {
+			t.Errorf("ParseImageRef(%q).String() = %q, want %q", tc.ref, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/openshift/bpfman-catalog/pkg/registry"
+)
+
+// Defaults for the CloudEvents attributes of watch events.
+const (
+	DefaultEventSource   = "bpfman-catalog/watch-bundles"
+	EventTypeBundleBuilt = "io.bpfman.catalog.bundle.built"
+)
+
+// cloudEvent is a CloudEvents 1.0 event in structured JSON mode.
+type cloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            Event     `json:"data"`
+}
+
+// CloudEvents posts each event to an HTTP endpoint as a CloudEvent in
+// structured JSON mode. The event ID identifies the bundle build, so
+// receivers can discard an event delivered twice. Requests are retried
+// under the retry policy carried by the context.
+type CloudEvents struct {
+	url    string
+	source string
+	client *http.Client
+}
+
+var _ Sink = &CloudEvents{}
+
+// NewCloudEvents returns a sink posting to url with the given event
+// source attribute.
+func NewCloudEvents(url, source string) *CloudEvents {
+	return &CloudEvents{
+		url:    url,
+		source: source,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Emit posts the event.
+func (c *CloudEvents) Emit(ctx context.Context, event Event) error {
+	data, err := json.Marshal(cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              event.ID(),
+		Source:          c.source,
+		Type:            EventTypeBundleBuilt,
+		Subject:         event.Image,
+		Time:            event.Observed,
+		DataContentType: "application/json",
+		Data:            event,
+	})
+	if err != nil {
+		return fmt.Errorf("marshalling cloud event: %w", err)
+	}
+
+	return registry.Retry(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			// The status is given as "HTTP <code>" so that
+			// rate limiting and server errors are retried.
+			return fmt.Errorf("posting event to %s: HTTP %s", c.url, resp.Status)
+		}
+		return nil
+	})
+}
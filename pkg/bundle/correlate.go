@@ -0,0 +1,254 @@
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/opencontainers/go-digest"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
+)
+
+// Component names used when correlating builds across repositories.
+const (
+	ComponentOperator = "operator"
+	ComponentAgent    = "agent"
+	ComponentDaemon   = "daemon"
+)
+
+// bundleRepoPrefix prefixes the bundle repository name; the remainder
+// names the stream (e.g. ystream) shared by the component repositories.
+const bundleRepoPrefix = "bpfman-operator-bundle-"
+
+// componentRepoPrefixes maps each component built alongside the
+// bundle to its repository name prefix.
+var componentRepoPrefixes = map[string]string{
+	ComponentOperator: "bpfman-operator-",
+	ComponentAgent:    "bpfman-agent-",
+	ComponentDaemon:   "bpfman-daemon-",
+}
+
+// CorrelatedComponents lists the components correlated with each
+// bundle, in display order.
+var CorrelatedComponents = []string{ComponentOperator, ComponentAgent, ComponentDaemon}
+
+// ComponentBuild is a component image built from the same commit as a
+// bundle.
+type ComponentBuild struct {
+	Repository string        `json:"repository"`
+	Image      string        `json:"image"`
+	Digest     digest.Digest `json:"digest,omitempty"`
+	Missing    bool          `json:"missing,omitempty"`
+	Unknown    bool          `json:"unknown,omitempty"` // The repository could not be listed
+	Error      string        `json:"error,omitempty"`
+}
+
+// DigestRef returns the digest reference of the build, or "" if the
+// build is missing.
+func (b *ComponentBuild) DigestRef() string {
+	if b.Missing || b.Digest == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s@%s", b.Repository, b.Digest)
+}
+
+// Correlation groups the images built from one commit tag.
+type Correlation struct {
+	Tag        string                     `json:"tag"`
+	Commit     string                     `json:"commit"`
+	Bundle     *BundleMetadata            `json:"bundle"`
+	Components map[string]*ComponentBuild `json:"components"`
+	Missing    []string                   `json:"missing,omitempty"`
+	Unknown    []string                   `json:"unknown,omitempty"`
+}
+
+// Complete reports whether every component is known to have been
+// built for the bundle's commit.
+func (c *Correlation) Complete() bool {
+	return len(c.Missing) == 0 && len(c.Unknown) == 0
+}
+
+// ComponentRefs returns the repositories holding the operator, agent
+// and daemon builds for the stream of a bundle repository. The
+// component repositories live alongside the bundle repository, e.g.
+// bpfman-operator-bundle-ystream pairs with bpfman-agent-ystream.
+func ComponentRefs(bundleRef BundleRef) (map[string]BundleRef, error) {
+	if bundleRef.IsLocal() {
+		return nil, fmt.Errorf("correlating components requires a registry repository, not %s", bundleRef)
+	}
+
+	stream, ok := strings.CutPrefix(bundleRef.Repo, bundleRepoPrefix)
+	if !ok || stream == "" {
+		return nil, fmt.Errorf("cannot derive component repositories from %s (expected a %s<stream> repository)", bundleRef.Repo, bundleRepoPrefix)
+	}
+
+	refs := make(map[string]BundleRef, len(componentRepoPrefixes))
+	for component, prefix := range componentRepoPrefixes {
+		ref := bundleRef
+		ref.Repo = prefix + stream
+		refs[component] = ref
+	}
+	return refs, nil
+}
+
+// CorrelateBundles lists the bundles selected by opts and, for each,
+// finds the operator, agent and daemon images tagged with the same
+// commit. Tags for all repositories are fetched concurrently;
+// components without a matching tag are recorded as missing. Bundle
+// tags whose metadata could not be read, and component repositories
+// that could not be listed, are returned as failures; the builds of
+// such a component are recorded as unknown.
+func CorrelateBundles(ctx context.Context, bundleRef BundleRef, opts ListOptions) ([]*Correlation, []TagFailure, error) {
+	componentRefs, err := ComponentRefs(bundleRef)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		wg            sync.WaitGroup
+		mu            sync.Mutex
+		bundles       []*BundleMetadata
+		failures      []TagFailure
+		bundlesErr    error
+		componentTags = make(map[string]map[string]bool, len(componentRefs))
+		repoFailures  []TagFailure
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		bundles, failures, bundlesErr = ListBundles(ctx, bundleRef, opts)
+	}()
+
+	for component, ref := range componentRefs {
+		wg.Add(1)
+		go func(component string, ref BundleRef) {
+			defer wg.Done()
+			tags, err := fetchTags(ctx, ref)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				repoFailures = append(repoFailures, TagFailure{Tag: ref.String(), Error: fmt.Sprintf("listing %s tags: %v", component, err)})
+				return
+			}
+			set := make(map[string]bool, len(tags))
+			for _, tag := range tags {
+				set[tag] = true
+			}
+			componentTags[component] = set
+		}(component, ref)
+	}
+
+	wg.Wait()
+
+	if bundlesErr != nil {
+		return nil, nil, bundlesErr
+	}
+	sort.Slice(repoFailures, func(i, j int) bool { return repoFailures[i].Tag < repoFailures[j].Tag })
+	failures = append(failures, repoFailures...)
+
+	correlations := make([]*Correlation, 0, len(bundles))
+	for _, b := range bundles {
+		c := &Correlation{
+			Tag:        b.Tag,
+			Commit:     tagCommit(b.Tag),
+			Bundle:     b,
+			Components: make(map[string]*ComponentBuild, len(componentRefs)),
+		}
+		for _, component := range CorrelatedComponents {
+			ref := componentRefs[component]
+			tags, listed := componentTags[component]
+			c.Components[component] = &ComponentBuild{
+				Repository: ref.String(),
+				Image:      ref.TaggedRef(b.Tag),
+				Missing:    listed && !tags[b.Tag],
+				Unknown:    !listed,
+			}
+		}
+		correlations = append(correlations, c)
+	}
+
+	if err := resolveComponentDigests(ctx, correlations); err != nil {
+		return nil, nil, err
+	}
+
+	for _, c := range correlations {
+		for _, component := range CorrelatedComponents {
+			switch build := c.Components[component]; {
+			case build.Missing:
+				c.Missing = append(c.Missing, component)
+			case build.Unknown:
+				c.Unknown = append(c.Unknown, component)
+			}
+		}
+	}
+
+	return correlations, failures, nil
+}
+
+// resolveComponentDigests resolves the digest of every component
+// build that exists, with at most maxConcurrency requests in flight.
+// A component whose digest cannot be resolved is reported as missing
+// with the error recorded.
+func resolveComponentDigests(ctx context.Context, correlations []*Correlation) error {
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, maxConcurrency)
+
+	for _, c := range correlations {
+		for _, build := range c.Components {
+			if build.Missing || build.Unknown {
+				continue
+			}
+
+			wg.Add(1)
+			go func(build *ComponentBuild) {
+				defer wg.Done()
+
+				select {
+				case semaphore <- struct{}{}:
+					defer func() { <-semaphore }()
+				case <-ctx.Done():
+					return
+				}
+
+				d, err := fetchDigest(ctx, build.Image)
+				if err != nil {
+					build.Missing = true
+					build.Error = err.Error()
+					return
+				}
+				build.Digest = d
+			}(build)
+		}
+	}
+
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("operation cancelled: %w", ctx.Err())
+	}
+	return nil
+}
+
+// fetchDigest resolves the manifest digest of a registry image
+// without downloading it.
+func fetchDigest(ctx context.Context, imageRef string) (digest.Digest, error) {
+	ref, err := registry.ParseReference(imageRef)
+	if err != nil {
+		return "", err
+	}
+
+	var d digest.Digest
+	err = registry.Retry(ctx, func() error {
+		d, err = docker.GetDigest(ctx, registry.SystemContext(ctx), ref)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("fetching digest for %s: %w", imageRef, err)
+	}
+	return d, nil
+}
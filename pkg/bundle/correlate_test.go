@@ -0,0 +1,123 @@
+package bundle
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/openshift/bpfman-catalog/pkg/registry"
+	"github.com/openshift/bpfman-catalog/pkg/registry/registrytest"
+)
+
+func TestComponentRefs(t *testing.T) {
+	refs, err := ComponentRefs(NewDefaultBundleRef())
+	if err != nil {
+		t.Fatalf("ComponentRefs: %v", err)
+	}
+	want := map[string]string{
+		ComponentOperator: "quay.io/redhat-user-workloads/ocp-bpfman-tenant/bpfman-operator-ystream",
+		ComponentAgent:    "quay.io/redhat-user-workloads/ocp-bpfman-tenant/bpfman-agent-ystream",
+		ComponentDaemon:   "quay.io/redhat-user-workloads/ocp-bpfman-tenant/bpfman-daemon-ystream",
+	}
+	for component, repo := range want {
+		if got := refs[component].String(); got != repo {
+			t.Errorf("%s repository = %q, want %q", component, got, repo)
+		}
+	}
+
+	if _, err := ComponentRefs(BundleRef{Registry: "quay.io", Tenant: "org", Repo: "catalog"}); err == nil {
+		t.Error("expected error for a repository that is not a bundle repository")
+	}
+}
+
+// TestCorrelateBundles flags a commit whose daemon build is missing.
+func TestCorrelateBundles(t *testing.T) {
+	srv := registrytest.NewServer(t)
+	complete := strings.Repeat("a", gitCommitTagLength)
+	partial := strings.Repeat("b", gitCommitTagLength)
+
+	srv.Push(t, "tenant/bpfman-operator-bundle-ystream", complete, registrytest.Image{
+		Labels: map[string]string{"build-date": "2025-01-01T00:00:00Z", "version": "0.6.0"},
+	})
+	srv.Push(t, "tenant/bpfman-operator-bundle-ystream", partial, registrytest.Image{
+		Labels: map[string]string{"build-date": "2025-02-01T00:00:00Z", "version": "0.6.1"},
+	})
+	for _, repo := range []string{"bpfman-operator-ystream", "bpfman-agent-ystream"} {
+		srv.Push(t, "tenant/"+repo, complete, registrytest.Image{})
+		srv.Push(t, "tenant/"+repo, partial, registrytest.Image{})
+	}
+	daemonDigest := srv.Push(t, "tenant/bpfman-daemon-ystream", complete, registrytest.Image{
+		Files: map[string]string{"bpfman": "daemon"},
+	})
+
+	opts := registry.DefaultOptions()
+	opts.TLSVerify = false
+	ctx := registry.WithOptions(context.Background(), opts)
+
+	bundleRef, err := ParseBundleRef(srv.Host() + "/tenant/bpfman-operator-bundle-ystream")
+	if err != nil {
+		t.Fatalf("ParseBundleRef: %v", err)
+	}
+
+	correlations, _, err := CorrelateBundles(ctx, bundleRef, ListOptions{})
+	if err != nil {
+		t.Fatalf("CorrelateBundles: %v", err)
+	}
+	if len(correlations) != 2 {
+		t.Fatalf("got %d correlations, want 2", len(correlations))
+	}
+
+	first, second := correlations[0], correlations[1]
+	if first.Tag != complete || !first.Complete() {
+		t.Errorf("first correlation = %s missing %v, want %s complete", first.Tag, first.Missing, complete)
+	}
+	if got := first.Components[ComponentDaemon].Digest; got != daemonDigest {
+		t.Errorf("daemon digest = %s, want %s", got, daemonDigest)
+	}
+	if second.Tag != partial || second.Complete() {
+		t.Errorf("second correlation = %s complete, want %s incomplete", second.Tag, partial)
+	}
+	if len(second.Missing) != 1 || second.Missing[0] != ComponentDaemon {
+		t.Errorf("missing = %v, want [%s]", second.Missing, ComponentDaemon)
+	}
+}
+
+// TestCorrelateBundlesUnlistedComponent reports a component
+// repository that cannot be listed as a failure and its builds as
+// unknown, and still correlates the other components.
+func TestCorrelateBundlesUnlistedComponent(t *testing.T) {
+	srv := registrytest.NewServer(t)
+	tag := strings.Repeat("a", gitCommitTagLength)
+	srv.Push(t, "tenant/bpfman-operator-bundle-ystream", tag, registrytest.Image{
+		Labels: map[string]string{"build-date": "2025-01-01T00:00:00Z", "version": "0.6.0"},
+	})
+	for _, repo := range []string{"bpfman-operator-ystream", "bpfman-daemon-ystream"} {
+		srv.Push(t, "tenant/"+repo, tag, registrytest.Image{})
+	}
+
+	opts := registry.DefaultOptions()
+	opts.TLSVerify = false
+	ctx := registry.WithOptions(context.Background(), opts)
+	bundleRef, err := ParseBundleRef(srv.Host() + "/tenant/bpfman-operator-bundle-ystream")
+	if err != nil {
+		t.Fatalf("ParseBundleRef: %v", err)
+	}
+
+	correlations, failures, err := CorrelateBundles(ctx, bundleRef, ListOptions{})
+	if err != nil {
+		t.Fatalf("CorrelateBundles: %v", err)
+	}
+	if len(correlations) != 1 {
+		t.Fatalf("got %d correlations, want 1", len(correlations))
+	}
+	c := correlations[0]
+	if c.Complete() || len(c.Missing) != 0 || len(c.Unknown) != 1 || c.Unknown[0] != ComponentAgent {
+		t.Errorf("correlation missing %v, unknown %v; want unknown [%s]", c.Missing, c.Unknown, ComponentAgent)
+	}
+	if c.Components[ComponentDaemon].Digest == "" {
+		t.Error("daemon build was not resolved")
+	}
+	if len(failures) != 1 || !strings.HasSuffix(failures[0].Tag, "/bpfman-agent-ystream") {
+		t.Errorf("failures = %+v, want the agent repository", failures)
+	}
+}
@@ -0,0 +1,68 @@
+package bundle
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// releaseDockerfilePath is the repository's release Dockerfile,
+// relative to this package.
+const releaseDockerfilePath = "../../Dockerfile"
+
+// TestReleaseDockerfile checks that the release Dockerfile is the
+// one generated from the shared template; `make dockerfile` rewrites
+// it.
+func TestReleaseDockerfile(t *testing.T) {
+	want := ReleaseDockerfile()
+	got, err := os.ReadFile(releaseDockerfilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("Dockerfile differs from pkg/bundle/templates/catalog.Dockerfile.tmpl; run make dockerfile")
+	}
+}
+
+// TestGenerateCatalogDockerfile builds the serve cache and sets the
+// configs label and default build arguments.
+func TestGenerateCatalogDockerfile(t *testing.T) {
+	got := GenerateCatalogDockerfile(DockerfileOptions{
+		Name:        "Dockerfile.v4.18",
+		BaseImage:   "registry.redhat.io/openshift4/ose-operator-registry-rhel9:v4.18",
+		CatalogFile: "catalog-v4.18.yaml",
+		Version:     "0.6.0",
+		Commit:      "abc123",
+	})
+	for _, want := range []string{
+		"podman build -f Dockerfile.v4.18",
+		"ARG BASE_IMAGE=registry.redhat.io/openshift4/ose-operator-registry-rhel9:v4.18\n",
+		"ARG COMMIT=abc123\n",
+		"ARG BUILDVERSION=0.6.0\n",
+		"ARG INDEX_FILE=catalog-v4.18.yaml\n",
+		"COPY $INDEX_FILE /configs/bpfman-operator/index.yaml\n",
+		`RUN ["/bin/opm", "serve", "/configs", "--cache-dir=/tmp/cache", "--cache-only"]`,
+		`CMD ["serve", "/configs", "--cache-dir=/tmp/cache"]`,
+		"LABEL operators.operatorframework.io.index.configs.v1=/configs\n",
+		`LABEL version="$BUILDVERSION"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Dockerfile does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+// TestCatalogImageLabels checks that the labels set on images built
+// without the Dockerfile are the ones the Dockerfile sets, named
+// after the package.
+func TestCatalogImageLabels(t *testing.T) {
+	dockerfile := GenerateCatalogDockerfile(DockerfileOptions{Package: "companion-operator"})
+	if strings.Contains(dockerfile, "bpfman-operator") || strings.Contains(dockerfile, "eBPF") {
+		t.Errorf("companion Dockerfile refers to bpfman-operator:\n%s", dockerfile)
+	}
+	for k, v := range CatalogImageLabels("companion-operator", "$BUILDVERSION", "$COMMIT") {
+		if want := "LABEL " + k + `="` + v + `"` + "\n"; !strings.Contains(dockerfile, want) {
+			t.Errorf("Dockerfile does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package bundle
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReproducibleBinary is the bpfman-catalog binary reproducible
+// Makefiles run, looked up on PATH rather than recorded as the
+// path of the binary that generated them.
+const ReproducibleBinary = "bpfman-catalog"
+
+// makeUser is the user in quay.io examples when none is known, left
+// for make to expand.
+const makeUser = "$(USER)"
+
+// Environment holds the values generated artefacts take from the run
+// that generated them rather than from their inputs.
+type Environment struct {
+	BinaryPath string    // bpfman-catalog binary the Makefile runs
+	Username   string    // User for quay.io examples
+	ImageUUID  string    // Repository name for ttl.sh examples
+	RandomTTL  string    // ttl.sh tag
+	Time       time.Time // Creation time of rebuilt images and manifests
+}
+
+// CurrentEnvironment returns the environment of this run: the path
+// of the running binary, the current user, a random ttl.sh name and
+// the current time.
+func CurrentEnvironment() Environment {
+	imageUUID, randomTTL := GenerateImageUUIDAndTTL()
+	username := os.Getenv("USER")
+	if username == "" {
+		username = makeUser
+	}
+	return Environment{
+		BinaryPath: getExecutablePath(),
+		Username:   username,
+		ImageUUID:  imageUUID,
+		RandomTTL:  randomTTL,
+		Time:       time.Now(),
+	}
+}
+
+// ReproducibleEnvironment returns an environment that depends only
+// on seed and t, so that the same inputs generate byte-identical
+// artefacts on any machine. The ttl.sh name is derived from seed, the
+// binary is looked up on PATH and the user is left to make.
+func ReproducibleEnvironment(seed int64, t time.Time) Environment {
+	rng := rand.New(rand.NewSource(seed))
+	return Environment{
+		BinaryPath: ReproducibleBinary,
+		Username:   makeUser,
+		ImageUUID:  fmt.Sprintf("%s-%s", seededUUID(rng), seededUUID(rng)),
+		RandomTTL:  generateRandomTTL(rng.Intn),
+		Time:       t.UTC(),
+	}
+}
+
+// seededUUID returns a version 4 UUID read from rng.
+func seededUUID(rng *rand.Rand) string {
+	// Reading from a *rand.Rand never fails.
+	id, _ := uuid.NewRandomFromReader(rng)
+	return id.String()
+}
+
+// MakefileData returns the Makefile template data for this
+// environment; see NewMakefileData.
+func (e Environment) MakefileData(bundleImage, packageName, operatorImageOverride string, upgrade *UpgradePlan, targets []OCPTarget) (MakefileData, error) {
+	data, err := NewMakefileData(bundleImage, packageName, e.BinaryPath, e.ImageUUID, e.RandomTTL, operatorImageOverride, upgrade, targets)
+	if err != nil {
+		return MakefileData{}, err
+	}
+	data.Username = e.Username
+	return data, nil
+}
+
+// WorkflowData returns the WORKFLOW.txt template data for this
+// environment; see NewWorkflowData.
+func (e Environment) WorkflowData(bundleCount int, catalogRendered bool, outputDir string) WorkflowData {
+	data := NewWorkflowData(bundleCount, catalogRendered, outputDir, e.ImageUUID, e.RandomTTL)
+	data.Username = e.Username
+	return data
+}
@@ -0,0 +1,72 @@
+package bundle
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReproducibleEnvironment checks that a reproducible environment
+// depends only on its seed and time, and that the Makefile and
+// WORKFLOW.txt generated from it are identical across runs.
+func TestReproducibleEnvironment(t *testing.T) {
+	t.Setenv("BPFMAN_CATALOG_QUAY_USER", "someone")
+	t.Setenv("USER", "someone")
+
+	epoch := time.Unix(1700000000, 0)
+	env := ReproducibleEnvironment(42, epoch)
+	if again := ReproducibleEnvironment(42, epoch); again != env {
+		t.Fatalf("same seed gave %+v and %+v", env, again)
+	}
+	if other := ReproducibleEnvironment(43, epoch); other.ImageUUID == env.ImageUUID {
+		t.Errorf("seeds 42 and 43 gave the same image name %s", env.ImageUUID)
+	}
+
+	uuid := `[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}`
+	if !regexp.MustCompile(`^` + uuid + `-` + uuid + `$`).MatchString(env.ImageUUID) {
+		t.Errorf("image name %q is not two UUIDs", env.ImageUUID)
+	}
+	if !regexp.MustCompile(`^(1[5-9]|2[0-9]|30)m$`).MatchString(env.RandomTTL) {
+		t.Errorf("TTL %q is not between 15m and 30m", env.RandomTTL)
+	}
+	if env.BinaryPath != ReproducibleBinary || env.Username != "$(USER)" {
+		t.Errorf("environment %+v depends on the machine", env)
+	}
+	if !env.Time.Equal(epoch) || env.Time.Location() != time.UTC {
+		t.Errorf("time = %v, want %v in UTC", env.Time, epoch)
+	}
+
+	templates, err := DefaultTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	generate := func() (string, string) {
+		e := ReproducibleEnvironment(42, epoch)
+		data, err := e.MakefileData("quay.io/tenant/bundle@sha256:abc", DefaultPackage, "", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		makefile, err := templates.Makefile(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		workflow, err := templates.Workflow(e.WorkflowData(0, true, "artefacts"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return makefile, workflow
+	}
+	makefile, workflow := generate()
+	if m, w := generate(); m != makefile || w != workflow {
+		t.Error("reproducible Makefile or WORKFLOW.txt differs between runs")
+	}
+	for name, content := range map[string]string{"Makefile": makefile, "WORKFLOW.txt": workflow} {
+		if !strings.Contains(content, env.ImageUUID+":"+env.RandomTTL) {
+			t.Errorf("%s does not use the seeded ttl.sh name", name)
+		}
+		if strings.Contains(content, "someone") {
+			t.Errorf("%s records the current user", name)
+		}
+	}
+}
@@ -4,163 +4,315 @@ import (
 	"bytes"
 	"context"
 	_ "embed"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 
+	"github.com/blang/semver/v4"
 	"github.com/google/uuid"
+	"github.com/opencontainers/go-digest"
+	"github.com/openshift/bpfman-catalog/pkg/imageref"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
 
 	"github.com/operator-framework/operator-registry/alpha/action"
 	"github.com/operator-framework/operator-registry/alpha/action/migrations"
 	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
 	"github.com/operator-framework/operator-registry/alpha/template/basic"
-	"github.com/operator-framework/operator-registry/pkg/containertools"
-	"github.com/operator-framework/operator-registry/pkg/image/execregistry"
 	"github.com/sirupsen/logrus"
 	"sigs.k8s.io/yaml"
 )
 
-//go:embed templates/Makefile.tmpl
-var makefileTemplate string
+//go:embed templates/catalog.Dockerfile.tmpl
+var catalogDockerfileSource string
 
-//go:embed templates/WORKFLOW.txt.tmpl
-var workflowTemplate string
+var catalogDockerfileTemplate = template.Must(template.New("Dockerfile").Parse(catalogDockerfileSource))
 
 // GenerateImageUUIDAndTTL generates a double UUID and random TTL for
 // ttl.sh examples.
 func GenerateImageUUIDAndTTL() (string, string) {
 	imageUUID := fmt.Sprintf("%s-%s", uuid.New().String(), uuid.New().String())
-	randomTTL := generateRandomTTL()
+	randomTTL := generateRandomTTL(rand.Intn)
 	return imageUUID, randomTTL
 }
 
 // generateRandomTTL generates a random TTL between 15m and 30m for
-// ttl.sh.
-func generateRandomTTL() string {
+// ttl.sh, using intn as rand.Intn.
+func generateRandomTTL(intn func(int) int) string {
 	minMinutes := 15
 	maxMinutes := 30
-	randomMinutes := rand.Intn(maxMinutes-minMinutes+1) + minMinutes
+	randomMinutes := intn(maxMinutes-minMinutes+1) + minMinutes
 	return fmt.Sprintf("%dm", randomMinutes)
 }
 
 // BundleInfo contains extracted bundle metadata.
 type BundleInfo struct {
-	Name    string
-	Package string
+	Name      string
+	Package   string
+	Version   semver.Version
+	Image     string        // Reference pinned to Digest, for use in a catalog
+	Reference string        // Reference as given
+	Digest    digest.Digest // Manifest digest the metadata was extracted from
+
+	// OperatorDeployment and OperatorContainer name the CSV install
+	// deployment and container running the operator, if the CSV
+	// tells; see operatorContainer.
+	OperatorDeployment string
+	OperatorContainer  string
 }
 
 // FBCTemplate represents a File-Based Catalog template.
 type FBCTemplate struct {
-	Schema  string `yaml:"schema"`
-	Entries []any  `yaml:"entries"`
+	Schema  string `yaml:"schema" json:"schema"`
+	Entries []any  `yaml:"entries" json:"entries"`
+}
+
+// replaceBundleImage points the olm.bundle entry named name at image
+// and reports whether there was one.
+func (t *FBCTemplate) replaceBundleImage(name, image string) bool {
+	for i, entry := range t.Entries {
+		if b, ok := entry.(BundleEntry); ok && b.Name == name {
+			b.Image = image
+			t.Entries[i] = b
+			return true
+		}
+	}
+	return false
 }
 
 // PackageEntry defines an OLM package.
 type PackageEntry struct {
-	Schema         string `yaml:"schema"`
-	Name           string `yaml:"name"`
-	DefaultChannel string `yaml:"defaultChannel"`
+	Schema         string `yaml:"schema" json:"schema"`
+	Name           string `yaml:"name" json:"name"`
+	DefaultChannel string `yaml:"defaultChannel" json:"defaultChannel"`
 }
 
 // ChannelEntry defines an OLM channel.
 type ChannelEntry struct {
-	Schema  string             `yaml:"schema"`
-	Package string             `yaml:"package"`
-	Name    string             `yaml:"name"`
-	Entries []ChannelEntryItem `yaml:"entries"`
+	Schema  string             `yaml:"schema" json:"schema"`
+	Package string             `yaml:"package" json:"package"`
+	Name    string             `yaml:"name" json:"name"`
+	Entries []ChannelEntryItem `yaml:"entries" json:"entries"`
 }
 
 // ChannelEntryItem represents an entry in a channel.
 type ChannelEntryItem struct {
-	Name     string `yaml:"name"`
-	Replaces string `yaml:"replaces,omitempty"`
+	Name      string   `yaml:"name" json:"name"`
+	Replaces  string   `yaml:"replaces,omitempty" json:"replaces,omitempty"`
+	Skips     []string `yaml:"skips,omitempty" json:"skips,omitempty"`
+	SkipRange string   `yaml:"skipRange,omitempty" json:"skipRange,omitempty"`
 }
 
 // BundleEntry defines an OLM bundle.
 type BundleEntry struct {
-	Schema string `yaml:"schema"`
-	Image  string `yaml:"image"`
-	Name   string `yaml:"name"`
+	Schema string `yaml:"schema" json:"schema"`
+	Image  string `yaml:"image" json:"image"`
+	Name   string `yaml:"name" json:"name"`
 }
 
-// GenerateFBCTemplate generates an FBC template for a bundle image.
-func GenerateFBCTemplate(bundleImage string, channel string) (*FBCTemplate, error) {
-	if bundleImage == "" {
-		return nil, fmt.Errorf("bundle image cannot be empty")
+// ChannelOptions lays out the channels of a generated FBC template.
+// Every channel carries the same upgrade chain; Skips and SkipRange
+// apply to its newest bundle.
+type ChannelOptions struct {
+	Channels       []string // Channels sharing the bundles (default: preview)
+	DefaultChannel string   // Package default channel (default: the first channel)
+	Skips          []string // Bundle names the newest bundle skips
+	SkipRange      string   // Version range the newest bundle skips
+}
+
+// normalise fills in defaults and checks that the options are
+// consistent. fallback is the channel used when none is given.
+func (o ChannelOptions) normalise(fallback string) (ChannelOptions, error) {
+	if len(o.Channels) == 0 && fallback != "" {
+		o.Channels = []string{fallback}
+	}
+	seen := make(map[string]bool, len(o.Channels))
+	for _, channel := range o.Channels {
+		if channel == "" {
+			return o, fmt.Errorf("channel name must not be empty")
+		}
+		if seen[channel] {
+			return o, fmt.Errorf("channel %s given more than once", channel)
+		}
+		seen[channel] = true
+	}
+	if o.DefaultChannel == "" && len(o.Channels) > 0 {
+		o.DefaultChannel = o.Channels[0]
+	}
+	if o.DefaultChannel != "" && len(o.Channels) > 0 && !seen[o.DefaultChannel] {
+		return o, fmt.Errorf("default channel %s is not one of the channels %v", o.DefaultChannel, o.Channels)
+	}
+	if o.SkipRange != "" {
+		if _, err := semver.ParseRange(o.SkipRange); err != nil {
+			return o, fmt.Errorf("invalid skip range %q: %w", o.SkipRange, err)
+		}
+	}
+	return o, nil
+}
+
+// GenerateFBCTemplate generates an FBC template for one or more
+// bundle images of the same package. The bundles are ordered by CSV
+// version and chained in each channel so that each replaces the one
+// before it.
+func GenerateFBCTemplate(ctx context.Context, bundleImages []string, opts ChannelOptions) (*FBCTemplate, error) {
+	infos, err := extractBundleInfos(ctx, bundleImages)
+	if err != nil {
+		return nil, err
 	}
+	return NewFBCTemplate(infos, opts)
+}
 
-	if channel == "" {
-		channel = "preview"
+// NewFBCTemplate returns an FBC template in which, in every channel,
+// each bundle replaces the one before it. The bundles must belong to
+// one package and be in version order, oldest first, as returned by
+// extractBundleInfos.
+func NewFBCTemplate(bundles []*BundleInfo, opts ChannelOptions) (*FBCTemplate, error) {
+	if len(bundles) == 0 {
+		return nil, fmt.Errorf("at least one bundle is required")
 	}
 
-	bundleInfo, err := extractBundleInfo(bundleImage)
+	opts, err := opts.normalise("preview")
 	if err != nil {
-		return nil, fmt.Errorf("extracting bundle info: %w", err)
-	}
-
-	bundleName := bundleInfo.Name
-	packageName := bundleInfo.Package
-
-	template := &FBCTemplate{
-		Schema: "olm.template.basic",
-		Entries: []any{
-			PackageEntry{
-				Schema:         "olm.package",
-				Name:           packageName,
-				DefaultChannel: channel,
-			},
-			ChannelEntry{
-				Schema:  "olm.channel",
-				Package: packageName,
-				Name:    channel,
-				Entries: []ChannelEntryItem{
-					{Name: bundleName},
-				},
-			},
-			BundleEntry{
-				Schema: "olm.bundle",
-				Image:  bundleImage,
-				Name:   bundleName,
-			},
+		return nil, err
+	}
+
+	if err := checkChain(bundles); err != nil {
+		return nil, err
+	}
+	ordered := bundles
+	packageName := ordered[0].Package
+
+	channelEntries := make([]ChannelEntryItem, 0, len(ordered))
+	bundleEntries := make([]any, 0, len(ordered))
+	for i, b := range ordered {
+		item := ChannelEntryItem{Name: b.Name}
+		if i > 0 {
+			item.Replaces = ordered[i-1].Name
+		}
+		if i == len(ordered)-1 {
+			item.Skips = opts.Skips
+			item.SkipRange = opts.SkipRange
+		}
+		channelEntries = append(channelEntries, item)
+		bundleEntries = append(bundleEntries, BundleEntry{
+			Schema: "olm.bundle",
+			Image:  b.Image,
+			Name:   b.Name,
+		})
+	}
+
+	entries := []any{
+		PackageEntry{
+			Schema:         "olm.package",
+			Name:           packageName,
+			DefaultChannel: opts.DefaultChannel,
 		},
 	}
+	for _, channel := range opts.Channels {
+		entries = append(entries, ChannelEntry{
+			Schema:  "olm.channel",
+			Package: packageName,
+			Name:    channel,
+			Entries: channelEntries,
+		})
+	}
+
+	return &FBCTemplate{
+		Schema:  "olm.template.basic",
+		Entries: append(entries, bundleEntries...),
+	}, nil
+}
+
+// orderBundles returns the bundles sorted by version, oldest first,
+// after checking that they can share a channel.
+func orderBundles(bundles []*BundleInfo) ([]*BundleInfo, error) {
+	ordered := append([]*BundleInfo(nil), bundles...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Version.LT(ordered[j].Version) })
+	if err := checkChain(ordered); err != nil {
+		return nil, err
+	}
+	return ordered, nil
+}
 
-	return template, nil
+// checkChain checks that the bundles belong to one package and are in
+// strictly increasing version order, so each can replace the one
+// before it.
+func checkChain(bundles []*BundleInfo) error {
+	for i, b := range bundles {
+		if b.Package != bundles[0].Package {
+			return fmt.Errorf("bundles belong to different packages: %s (%s) and %s (%s)",
+				bundles[0].Package, bundles[0].Image, b.Package, b.Image)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := bundles[i-1]
+		if b.Version.EQ(prev.Version) {
+			return fmt.Errorf("bundles %s and %s have the same version %s", prev.Image, b.Image, b.Version)
+		}
+		if b.Version.LT(prev.Version) {
+			return fmt.Errorf("bundles %s (%s) and %s (%s) are not in version order",
+				prev.Image, prev.Version, b.Image, b.Version)
+		}
+	}
+	return nil
 }
 
 // RenderCatalog uses the OPM library to render the FBC template into
-// a full catalog.
-func RenderCatalog(ctx context.Context, fbcTemplate *FBCTemplate) (string, error) {
+// a full catalog at the given migrate level.
+func RenderCatalog(ctx context.Context, fbcTemplate *FBCTemplate, migrateLevel string) (string, error) {
 	templateYAML, err := yaml.Marshal(fbcTemplate)
 	if err != nil {
 		return "", fmt.Errorf("marshaling FBC template: %w", err)
 	}
 
+	cfg, err := RenderTemplate(ctx, bytes.NewReader(templateYAML), migrateLevel)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := declcfg.WriteYAML(*cfg, &buf); err != nil {
+		return "", fmt.Errorf("writing catalog YAML: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// RenderTemplate uses the OPM library to render a basic catalog
+// template into a declarative config. Every bundle image referenced
+// by the template is pulled and rendered, then migrated to
+// migrateLevel.
+func RenderTemplate(ctx context.Context, reader io.Reader, migrateLevel string) (*declcfg.DeclarativeConfig, error) {
 	logrus.SetLevel(logrus.WarnLevel)
 
 	logger := logrus.NewEntry(logrus.New())
 	logger.Logger.SetLevel(logrus.WarnLevel)
 
-	registry, err := execregistry.NewRegistry(containertools.PodmanTool, logger)
+	reg, err := registry.New(ctx, logger)
 	if err != nil {
-		return "", fmt.Errorf("creating image registry: %w", err)
+		return nil, fmt.Errorf("creating image registry: %w", err)
 	}
+	defer reg.Destroy()
 
 	template := basic.Template{
 		RenderBundle: func(ctx context.Context, image string) (*declcfg.DeclarativeConfig, error) {
-			migs, err := migrations.NewMigrations("bundle-object-to-csv-metadata")
+			migs, err := migrations.NewMigrations(migrateLevel)
 			if err != nil {
 				return nil, fmt.Errorf("creating migrations: %w", err)
 			}
 
 			r := action.Render{
 				Refs:           []string{image},
-				Registry:       registry,
+				Registry:       reg,
 				AllowedRefMask: action.RefBundleImage,
 				Migrations:     migs,
 			}
@@ -168,53 +320,98 @@ func RenderCatalog(ctx context.Context, fbcTemplate *FBCTemplate) (string, error
 		},
 	}
 
-	reader := bytes.NewReader(templateYAML)
 	cfg, err := template.Render(ctx, reader)
 	if err != nil {
-		return "", fmt.Errorf("rendering template: %w", err)
+		return nil, fmt.Errorf("rendering template: %w", err)
 	}
 
-	var buf bytes.Buffer
-	if err := declcfg.WriteYAML(*cfg, &buf); err != nil {
-		return "", fmt.Errorf("writing catalog YAML: %w", err)
-	}
+	return cfg, nil
+}
 
-	return buf.String(), nil
+// DefaultPackage is the package catalogs are built for.
+const DefaultPackage = "bpfman-operator"
+
+// DockerfileOptions configures GenerateCatalogDockerfile. Version and
+// Commit are the defaults of the BUILDVERSION and COMMIT build
+// arguments, which set the version and upstream-vcs-ref labels.
+type DockerfileOptions struct {
+	Name        string // File name the Dockerfile is saved as
+	BaseImage   string // opm base image
+	CatalogFile string // Rendered catalog, relative to the build context
+	Package     string // Directory under /configs (default: DefaultPackage)
+	Version     string // Default version label
+	Commit      string // Default upstream-vcs-ref label
 }
 
 // GenerateCatalogDockerfile generates a Dockerfile for building a
-// catalog image.
-func GenerateCatalogDockerfile() string {
-	return `# Catalog Dockerfile for bpfman-operator
-# Generated by bpfman-catalog tool
-#
-# Build with:
-#   podman build -f Dockerfile -t bpfman-catalog:dev .
-#
-# Push to a registry:
-#   podman push bpfman-catalog:dev ttl.sh/bpfman-catalog:dev
-
-FROM registry.redhat.io/openshift4/ose-operator-registry-rhel9:v4.20 AS builder
-
-# Copy the rendered catalog
-COPY catalog.yaml /configs/catalog.yaml
-
-# Validate the catalog
-RUN opm validate /configs
-
-# Final stage - minimal image with just the catalog
-FROM registry.redhat.io/openshift4/ose-operator-registry-rhel9:v4.20
-
-# Copy validated catalog from builder
-COPY --from=builder /configs /configs
-
-# Label for OpenShift
-LABEL io.openshift.release.operator=true
-
-# Serve the catalog
-ENTRYPOINT ["/bin/opm"]
-CMD ["serve", "/configs"]
-`
+// catalog image on an opm base image. The opm serve cache is built
+// into the image, with the configs label set, from the same template
+// as the release Dockerfile.
+func GenerateCatalogDockerfile(opts DockerfileOptions) string {
+	if opts.Package == "" {
+		opts.Package = DefaultPackage
+	}
+	return executeDockerfileTemplate(opts, false)
+}
+
+// ReleaseDockerfile returns the repository's release Dockerfile,
+// whose base image, catalog file, version and commit are build
+// arguments without defaults.
+func ReleaseDockerfile() string {
+	return executeDockerfileTemplate(DockerfileOptions{Package: DefaultPackage}, true)
+}
+
+// catalogLabel is a descriptive label of a catalog image.
+type catalogLabel struct {
+	Key, Value string
+}
+
+// catalogLabels returns the descriptive labels of a catalog image
+// for packageName, in the order the Dockerfile sets them.
+func catalogLabels(packageName, version, commit string) []catalogLabel {
+	title := packageName + " Catalog"
+	description := packageName + " for OpenShift."
+	if packageName == DefaultPackage {
+		title = "eBPF Manager Operator Catalog"
+		description = "eBPF Manager operator for OpenShift."
+	}
+	return []catalogLabel{
+		{"com.redhat.component", packageName + "-catalog-container"},
+		{"name", packageName + "-catalog"},
+		{"io.k8s.display-name", title},
+		{"io.k8s.description", title},
+		{"summary", title},
+		{"maintainer", "support@redhat.com"},
+		{"io.openshift.tags", packageName + "-catalog"},
+		{"upstream-vcs-ref", commit},
+		{"upstream-vcs-type", "git"},
+		{"description", description},
+		{"version", version},
+	}
+}
+
+// CatalogImageLabels returns the descriptive labels the catalog
+// Dockerfile sets for packageName, for images built without it.
+func CatalogImageLabels(packageName, version, commit string) map[string]string {
+	labels := make(map[string]string)
+	for _, l := range catalogLabels(packageName, version, commit) {
+		labels[l.Key] = l.Value
+	}
+	return labels
+}
+
+func executeDockerfileTemplate(opts DockerfileOptions, release bool) string {
+	data := struct {
+		DockerfileOptions
+		Release bool
+		Labels  []catalogLabel
+	}{opts, release, catalogLabels(opts.Package, "$BUILDVERSION", "$COMMIT")}
+
+	var buf bytes.Buffer
+	if err := catalogDockerfileTemplate.Execute(&buf, data); err != nil {
+		return fmt.Sprintf("# Error executing Dockerfile template: %v\n", err)
+	}
+	return buf.String()
 }
 
 // extractDigestSuffix extracts the first 8 characters of a digest
@@ -231,49 +428,118 @@ func extractDigestSuffix(imageRef string) string {
 
 // extractBundleInfo extracts bundle name and package from bundle
 // metadata.
-func extractBundleInfo(bundleImage string) (*BundleInfo, error) {
+func extractBundleInfo(ctx context.Context, bundleImage string) (*BundleInfo, error) {
 	logrus.SetLevel(logrus.WarnLevel)
 
 	logger := logrus.NewEntry(logrus.New())
 	logger.Logger.SetLevel(logrus.WarnLevel)
 
-	registry, err := execregistry.NewRegistry(containertools.PodmanTool, logger)
+	reg, err := registry.New(ctx, logger)
 	if err != nil {
 		return nil, fmt.Errorf("creating image registry: %w", err)
 	}
+	defer reg.Destroy()
 
-	migs, err := migrations.NewMigrations("bundle-object-to-csv-metadata")
+	migs, err := migrations.NewMigrations(DefaultMigrateLevel)
 	if err != nil {
 		return nil, fmt.Errorf("creating migrations: %w", err)
 	}
 
+	// Resolve the digest once and render the bundle pinned to it, so
+	// the recorded digest is the one the metadata came from even if
+	// a tag moves meanwhile.
+	d, err := registry.Digest(ctx, bundleImage)
+	if err != nil {
+		return nil, fmt.Errorf("resolving digest: %w", err)
+	}
+	ref, err := imageref.Parse(bundleImage)
+	if err != nil {
+		return nil, err
+	}
+	ref.Digest = d
+	renderRef := ref.DigestRef()
+
 	r := action.Render{
-		Refs:           []string{bundleImage},
-		Registry:       registry,
+		Refs:           []string{renderRef},
+		Registry:       reg,
 		AllowedRefMask: action.RefBundleImage,
 		Migrations:     migs,
 	}
 
-	cfg, err := r.Run(context.Background())
+	cfg, err := r.Run(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("rendering bundle: %w", err)
 	}
 
 	for _, bundle := range cfg.Bundles {
-		if bundle.Image == bundleImage {
-			return &BundleInfo{
-				Name:    bundle.Name,
-				Package: bundle.Package,
-			}, nil
+		if bundle.Image == renderRef {
+			version, err := BundleVersion(bundle)
+			if err != nil {
+				return nil, fmt.Errorf("bundle %s: %w", bundleImage, err)
+			}
+			info := &BundleInfo{
+				Name:      bundle.Name,
+				Package:   bundle.Package,
+				Version:   version,
+				Image:     renderRef,
+				Reference: bundleImage,
+				Digest:    d,
+			}
+			// Only patching the operator needs the names, so a CSV
+			// that does not tell is not an error here.
+			info.OperatorDeployment, info.OperatorContainer, _ = operatorNames([]byte(bundle.CsvJSON))
+			return info, nil
 		}
 	}
 
 	return nil, fmt.Errorf("bundle not found in rendered config")
 }
 
+// extractBundleInfos extracts the metadata of each bundle image and
+// returns it in version order, oldest first. This is the one place
+// bundles are ordered; everything downstream relies on it.
+func extractBundleInfos(ctx context.Context, bundleImages []string) ([]*BundleInfo, error) {
+	if len(bundleImages) == 0 {
+		return nil, fmt.Errorf("bundle image cannot be empty")
+	}
+
+	infos := make([]*BundleInfo, 0, len(bundleImages))
+	for _, image := range bundleImages {
+		if image == "" {
+			return nil, fmt.Errorf("bundle image cannot be empty")
+		}
+		info, err := extractBundleInfo(ctx, image)
+		if err != nil {
+			return nil, fmt.Errorf("extracting bundle info from %s: %w", image, err)
+		}
+		infos = append(infos, info)
+	}
+	return orderBundles(infos)
+}
+
+// BundleVersion returns the version recorded in a rendered bundle's
+// olm.package property.
+func BundleVersion(bundle declcfg.Bundle) (semver.Version, error) {
+	for _, p := range bundle.Properties {
+		if p.Type != property.TypePackage {
+			continue
+		}
+		var pkg property.Package
+		if err := json.Unmarshal(p.Value, &pkg); err != nil {
+			return semver.Version{}, fmt.Errorf("parsing %s property: %w", property.TypePackage, err)
+		}
+		version, err := semver.Parse(pkg.Version)
+		if err != nil {
+			return semver.Version{}, fmt.Errorf("parsing version %q: %w", pkg.Version, err)
+		}
+		return version, nil
+	}
+	return semver.Version{}, fmt.Errorf("no %s property", property.TypePackage)
+}
+
 // RenderCatalogWithBinary uses an external opm binary to render the
 // FBC template into a full catalog.
-func RenderCatalogWithBinary(ctx context.Context, fbcTemplate *FBCTemplate, ompBinPath string) (string, error) {
+func RenderCatalogWithBinary(ctx context.Context, fbcTemplate *FBCTemplate, ompBinPath, migrateLevel string) (string, error) {
 	templateYAML, err := yaml.Marshal(fbcTemplate)
 	if err != nil {
 		return "", fmt.Errorf("marshaling FBC template: %w", err)
@@ -291,7 +557,7 @@ func RenderCatalogWithBinary(ctx context.Context, fbcTemplate *FBCTemplate, ompB
 	}
 
 	cmd := exec.CommandContext(ctx, ompBinPath, "alpha", "render-template", "basic",
-		"--migrate-level=bundle-object-to-csv-metadata",
+		"--migrate-level="+migrateLevel,
 		"-o", "yaml",
 		templateFile)
 	cmd.Dir = tempDir
@@ -307,88 +573,3 @@ func RenderCatalogWithBinary(ctx context.Context, fbcTemplate *FBCTemplate, ompB
 
 	return string(output), nil
 }
-
-// getUsernameOrDefault returns the username for quay.io examples.
-// Priority: BPFMAN_CATALOG_QUAY_USER > USER > "$(USER)".
-func getUsernameOrDefault() string {
-	if user := os.Getenv("BPFMAN_CATALOG_QUAY_USER"); user != "" {
-		return user
-	}
-	if user := os.Getenv("USER"); user != "" {
-		return user
-	}
-	return "$(USER)"
-}
-
-// GenerateMakefile generates a Makefile for building and deploying
-// the catalog.
-func GenerateMakefile(bundleImage, binaryPath, imageUUID, randomTTL, operatorImageOverride string) string {
-	digestSuffix := extractDigestSuffix(bundleImage)
-
-	localTag := "bpfman-catalog"
-	if digestSuffix != "" {
-		localTag = fmt.Sprintf("bpfman-catalog-sha-%s", digestSuffix)
-	}
-
-	tmpl, err := template.New("makefile").Parse(makefileTemplate)
-	if err != nil {
-		return fmt.Sprintf("# Error parsing Makefile template: %v\n# Bundle: %s\n", err, bundleImage)
-	}
-
-	data := struct {
-		BundleImage           string
-		LocalTag              string
-		BinaryPath            string
-		ImageUUID             string
-		RandomTTL             string
-		Username              string
-		OperatorImageOverride string
-	}{
-		BundleImage:           bundleImage,
-		LocalTag:              localTag,
-		BinaryPath:            binaryPath,
-		ImageUUID:             imageUUID,
-		RandomTTL:             randomTTL,
-		Username:              getUsernameOrDefault(),
-		OperatorImageOverride: operatorImageOverride,
-	}
-
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return fmt.Sprintf("# Error executing Makefile template: %v\n# Bundle: %s\n", err, bundleImage)
-	}
-
-	return buf.String()
-}
-
-// GenerateWorkflow generates a WORKFLOW.txt file with deployment
-// instructions.
-func GenerateWorkflow(bundleCount int, catalogRendered bool, outputDir, imageUUID, randomTTL string) string {
-	tmpl, err := template.New("workflow").Parse(workflowTemplate)
-	if err != nil {
-		return fmt.Sprintf("# Error parsing WORKFLOW template: %v\n", err)
-	}
-
-	data := struct {
-		BundleCount     int
-		CatalogRendered bool
-		ImageUUID       string
-		RandomTTL       string
-		OutputDir       string
-		Username        string
-	}{
-		BundleCount:     bundleCount,
-		CatalogRendered: catalogRendered,
-		ImageUUID:       imageUUID,
-		RandomTTL:       randomTTL,
-		OutputDir:       outputDir,
-		Username:        getUsernameOrDefault(),
-	}
-
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return fmt.Sprintf("# Error executing WORKFLOW template: %v\n", err)
-	}
-
-	return buf.String()
-}
@@ -0,0 +1,180 @@
+package bundle
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
+	"github.com/openshift/bpfman-catalog/pkg/registry/registrytest"
+)
+
+const testAnnotations = `annotations:
+  operators.operatorframework.io.bundle.mediatype.v1: registry+v1
+  operators.operatorframework.io.bundle.manifests.v1: manifests/
+  operators.operatorframework.io.bundle.metadata.v1: metadata/
+  operators.operatorframework.io.bundle.package.v1: bpfman-operator
+  operators.operatorframework.io.bundle.channels.v1: preview
+`
+
+func testBundle(version string) *BundleInfo {
+	return &BundleInfo{
+		Name:    "bpfman-operator.v" + version,
+		Package: "bpfman-operator",
+		Version: semver.MustParse(version),
+		Image:   "quay.io/tenant/bpfman-operator-bundle:v" + version,
+	}
+}
+
+// TestNewFBCTemplateUpgradeChain orders bundles by version, not by
+// argument order, and chains each to the one before.
+func TestNewFBCTemplateUpgradeChain(t *testing.T) {
+	ordered, err := orderBundles([]*BundleInfo{
+		testBundle("0.6.0-dev.10"),
+		testBundle("0.5.9"),
+		testBundle("0.6.0-dev.2"),
+	})
+	if err != nil {
+		t.Fatalf("orderBundles: %v", err)
+	}
+	template, err := NewFBCTemplate(ordered, ChannelOptions{})
+	if err != nil {
+		t.Fatalf("NewFBCTemplate: %v", err)
+	}
+
+	pkg := template.Entries[0].(PackageEntry)
+	if pkg.DefaultChannel != "preview" {
+		t.Errorf("default channel = %q, want preview", pkg.DefaultChannel)
+	}
+
+	channel := template.Entries[1].(ChannelEntry)
+	want := []ChannelEntryItem{
+		{Name: "bpfman-operator.v0.5.9"},
+		{Name: "bpfman-operator.v0.6.0-dev.2", Replaces: "bpfman-operator.v0.5.9"},
+		{Name: "bpfman-operator.v0.6.0-dev.10", Replaces: "bpfman-operator.v0.6.0-dev.2"},
+	}
+	if len(channel.Entries) != len(want) {
+		t.Fatalf("got %d channel entries, want %d", len(channel.Entries), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(channel.Entries[i], want[i]) {
+			t.Errorf("entry %d = %+v, want %+v", i, channel.Entries[i], want[i])
+		}
+	}
+
+	if got := len(template.Entries) - 2; got != 3 {
+		t.Errorf("got %d bundle entries, want 3", got)
+	}
+}
+
+func TestNewFBCTemplateRejectsInvalidChains(t *testing.T) {
+	other := testBundle("0.6.0")
+	other.Package = "other-operator"
+
+	for name, bundles := range map[string][]*BundleInfo{
+		"none":              nil,
+		"different package": {testBundle("0.5.0"), other},
+		"same version":      {testBundle("0.6.0"), testBundle("0.6.0")},
+		"out of order":      {testBundle("0.6.0"), testBundle("0.5.0")},
+	} {
+		if _, err := NewFBCTemplate(bundles, ChannelOptions{}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// TestNewFBCTemplateChannels shares the chain between channels and
+// marks the newest bundle with the skips.
+func TestNewFBCTemplateChannels(t *testing.T) {
+	template, err := NewFBCTemplate([]*BundleInfo{testBundle("0.5.9"), testBundle("0.6.0")}, ChannelOptions{
+		Channels:       []string{"candidate", "stable"},
+		DefaultChannel: "stable",
+		Skips:          []string{"bpfman-operator.v0.5.8"},
+		SkipRange:      ">=0.5.0 <0.6.0",
+	})
+	if err != nil {
+		t.Fatalf("NewFBCTemplate: %v", err)
+	}
+
+	if pkg := template.Entries[0].(PackageEntry); pkg.DefaultChannel != "stable" {
+		t.Errorf("default channel = %q, want stable", pkg.DefaultChannel)
+	}
+
+	want := []ChannelEntryItem{
+		{Name: "bpfman-operator.v0.5.9"},
+		{
+			Name:      "bpfman-operator.v0.6.0",
+			Replaces:  "bpfman-operator.v0.5.9",
+			Skips:     []string{"bpfman-operator.v0.5.8"},
+			SkipRange: ">=0.5.0 <0.6.0",
+		},
+	}
+	for i, name := range []string{"candidate", "stable"} {
+		channel := template.Entries[1+i].(ChannelEntry)
+		if channel.Name != name {
+			t.Errorf("channel %d = %s, want %s", i, channel.Name, name)
+		}
+		if !reflect.DeepEqual(channel.Entries, want) {
+			t.Errorf("channel %s entries = %+v, want %+v", name, channel.Entries, want)
+		}
+	}
+
+	if got := len(template.Entries) - 3; got != 2 {
+		t.Errorf("got %d bundle entries, want 2", got)
+	}
+}
+
+func TestNewFBCTemplateRejectsInvalidChannels(t *testing.T) {
+	bundles := []*BundleInfo{testBundle("0.6.0")}
+	for name, opts := range map[string]ChannelOptions{
+		"unknown default":    {Channels: []string{"stable"}, DefaultChannel: "candidate"},
+		"duplicate channel":  {Channels: []string{"stable", "stable"}},
+		"empty channel":      {Channels: []string{""}},
+		"invalid skip range": {SkipRange: "not a range"},
+	} {
+		if _, err := NewFBCTemplate(bundles, opts); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// TestExtractBundleInfoRecordsDigest renders a bundle by tag and
+// records the digest its metadata was read at, pinning the image to
+// it.
+func TestExtractBundleInfoRecordsDigest(t *testing.T) {
+	server := registrytest.NewServer(t)
+	server.Push(t, "tenant/bpfman-operator-bundle", "v0.6.0", registrytest.Image{
+		Files: map[string]string{
+			"manifests/bpfman-operator.clusterserviceversion.yaml": testCSV,
+			"metadata/annotations.yaml":                            testAnnotations,
+		},
+		Labels: map[string]string{
+			"operators.operatorframework.io.bundle.mediatype.v1": "registry+v1",
+			"operators.operatorframework.io.bundle.manifests.v1": "manifests/",
+			"operators.operatorframework.io.bundle.metadata.v1":  "metadata/",
+			"operators.operatorframework.io.bundle.package.v1":   "bpfman-operator",
+		},
+	})
+
+	opts := registry.DefaultOptions()
+	opts.TLSVerify = false
+	ctx := registry.WithOptions(context.Background(), opts)
+
+	ref := server.Host() + "/tenant/bpfman-operator-bundle:v0.6.0"
+	info, err := extractBundleInfo(ctx, ref)
+	if err != nil {
+		t.Fatalf("extractBundleInfo: %v", err)
+	}
+	want, err := registry.Digest(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned := server.Host() + "/tenant/bpfman-operator-bundle@" + want.String()
+	if info.Image != pinned || info.Reference != ref || info.Digest != want || info.Name != "bpfman-operator.v0.6.0" {
+		t.Errorf("info = %+v, want image %s given as %s", info, pinned, ref)
+	}
+	if info.OperatorDeployment != "bpfman-operator" || info.OperatorContainer != "bpfman-operator" {
+		t.Errorf("operator = %s/%s, want bpfman-operator/bpfman-operator", info.OperatorDeployment, info.OperatorContainer)
+	}
+}
@@ -0,0 +1,161 @@
+package bundle
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/bpfman-catalog/pkg/pullrequest"
+)
+
+// ListOptions selects which bundles are listed. The zero value lists
+// every build tag with no limit.
+type ListOptions struct {
+	Limit        int            // Maximum number of bundles to return (0 for all)
+	Since        time.Time      // Only bundles built at or after this time
+	Until        time.Time      // Only bundles built at or before this time
+	Version      string         // Version label, exact or shell glob (e.g. 0.6.*)
+	PROnly       bool           // Only on-pr-<commit> tags
+	ExcludePR    bool           // Exclude on-pr-<commit> tags
+	CommitPrefix string         // Only tags whose git commit starts with this prefix
+	TagPattern   *regexp.Regexp // Additional tags to accept alongside build tags
+	IndexDir     string         // Directory of metadata indexes ("" to always inspect every tag)
+	Refresh      bool           // Discard the index and inspect every tag again
+}
+
+// Validate reports conflicting options.
+func (o ListOptions) Validate() error {
+	if o.PROnly && o.ExcludePR {
+		return fmt.Errorf("pr-only and exclude-pr cannot be used together")
+	}
+	if !o.Since.IsZero() && !o.Until.IsZero() && o.Until.Before(o.Since) {
+		return fmt.Errorf("until (%s) is before since (%s)", o.Until.Format(time.RFC3339), o.Since.Format(time.RFC3339))
+	}
+	if o.Version != "" {
+		if _, err := path.Match(o.Version, ""); err != nil {
+			return fmt.Errorf("invalid version pattern %q: %w", o.Version, err)
+		}
+	}
+	return nil
+}
+
+// tagCommit returns the git commit a build tag was built from, or ""
+// if the tag does not carry one.
+func tagCommit(tag string) string {
+	if isGitCommitTag(tag) {
+		return tag
+	}
+	commit, _ := pullrequest.CommitFromTag(tag)
+	return commit
+}
+
+// matchesTag reports whether a tag passes the tag-level filters. Tags
+// are accepted if they are git commit or on-pr- build tags, or match
+// TagPattern.
+func (o ListOptions) matchesTag(tag string) bool {
+	isBuild := isGitCommitTag(tag) || isPRTag(tag)
+	if !isBuild && (o.TagPattern == nil || !o.TagPattern.MatchString(tag)) {
+		return false
+	}
+	if o.PROnly && !isPRTag(tag) {
+		return false
+	}
+	if o.ExcludePR && isPRTag(tag) {
+		return false
+	}
+	if o.CommitPrefix != "" && !strings.HasPrefix(tagCommit(tag), strings.ToLower(o.CommitPrefix)) {
+		return false
+	}
+	return true
+}
+
+// hasTagFilters reports whether any tag-level filter is set.
+func (o ListOptions) hasTagFilters() bool {
+	return o.PROnly || o.ExcludePR || o.CommitPrefix != "" || o.TagPattern != nil
+}
+
+// filterTags applies the tag-level filters. Filtering by tag happens
+// before any image metadata is fetched.
+func (o ListOptions) filterTags(tags []string) []string {
+	var matched []string
+	for _, tag := range tags {
+		if o.matchesTag(tag) {
+			matched = append(matched, tag)
+		}
+	}
+	return matched
+}
+
+// matchesMetadata reports whether a bundle passes the filters that
+// need its image metadata.
+func (o ListOptions) matchesMetadata(b *BundleMetadata) bool {
+	if o.Version != "" {
+		if ok, _ := path.Match(o.Version, b.Version); !ok {
+			return false
+		}
+	}
+	if o.Since.IsZero() && o.Until.IsZero() {
+		return true
+	}
+
+	built, err := parseBuildDate(b.BuildDate)
+	if err != nil {
+		if b.Created.IsZero() {
+			return false
+		}
+		built = b.Created
+	}
+	if !o.Since.IsZero() && built.Before(o.Since) {
+		return false
+	}
+	if !o.Until.IsZero() && built.After(o.Until) {
+		return false
+	}
+	return true
+}
+
+// selectBundles applies the metadata filters, sorts by build date and
+// keeps the newest Limit bundles, oldest first.
+func (o ListOptions) selectBundles(all []*BundleMetadata) []*BundleMetadata {
+	var bundles []*BundleMetadata
+	for _, b := range all {
+		if o.matchesMetadata(b) {
+			bundles = append(bundles, b)
+		}
+	}
+
+	sortByBuildDate(bundles)
+
+	limit := o.Limit
+	if limit <= 0 || limit > len(bundles) {
+		limit = len(bundles)
+	}
+
+	// Take the last N (newest) bundles, preserving oldest-first order
+	return bundles[len(bundles)-limit:]
+}
+
+// ParseTimeBound parses a --since or --until value. It accepts the
+// build-date formats (RFC 3339 or YYYY-MM-DD) or an age relative to
+// now such as 36h or 7d.
+func ParseTimeBound(s string, now time.Time) (time.Time, error) {
+	if t, err := parseBuildDate(s); err == nil {
+		return t, nil
+	}
+
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q (expected RFC 3339, YYYY-MM-DD, or an age such as 36h or 7d)", s)
+}
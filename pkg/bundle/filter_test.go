@@ -0,0 +1,92 @@
+package bundle
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListOptionsMatchesTag(t *testing.T) {
+	commit := "3f2a9c1" + strings.Repeat("0", gitCommitTagLength-7)
+	prTag := "on-pr-" + commit
+
+	tests := []struct {
+		name string
+		opts ListOptions
+		tag  string
+		want bool
+	}{
+		{"commit tag", ListOptions{}, commit, true},
+		{"pr tag", ListOptions{}, prTag, true},
+		{"other tag", ListOptions{}, "latest", false},
+		{"tag pattern", ListOptions{TagPattern: regexp.MustCompile(`^v\d+\.\d+\.\d+$`)}, "v0.6.0", true},
+		{"pr only rejects commit", ListOptions{PROnly: true}, commit, false},
+		{"pr only accepts pr", ListOptions{PROnly: true}, prTag, true},
+		{"exclude pr", ListOptions{ExcludePR: true}, prTag, false},
+		{"commit prefix", ListOptions{CommitPrefix: "3F2A"}, prTag, true},
+		{"commit prefix mismatch", ListOptions{CommitPrefix: "abc"}, commit, false},
+		{"commit prefix on pattern tag", ListOptions{CommitPrefix: "3f2a", TagPattern: regexp.MustCompile(`^v`)}, "v0.6.0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.matchesTag(tt.tag); got != tt.want {
+				t.Errorf("matchesTag(%q) = %v, want %v", tt.tag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListOptionsMatchesMetadata(t *testing.T) {
+	b := &BundleMetadata{Version: "0.6.1", BuildDate: "2025-03-10T12:00:00Z"}
+
+	tests := []struct {
+		name string
+		opts ListOptions
+		want bool
+	}{
+		{"no filters", ListOptions{}, true},
+		{"version glob", ListOptions{Version: "0.6.*"}, true},
+		{"version mismatch", ListOptions{Version: "0.5.*"}, false},
+		{"since before", ListOptions{Since: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)}, true},
+		{"since after", ListOptions{Since: time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC)}, false},
+		{"until before", ListOptions{Until: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.matchesMetadata(b); got != tt.want {
+				t.Errorf("matchesMetadata() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeBound(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "2025-03-01", want: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{in: "2025-03-01T06:00:00Z", want: time.Date(2025, 3, 1, 6, 0, 0, 0, time.UTC)},
+		{in: "7d", want: now.AddDate(0, 0, -7)},
+		{in: "36h", want: now.Add(-36 * time.Hour)},
+		{in: "yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseTimeBound(tt.in, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTimeBound(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("ParseTimeBound(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,79 +4,255 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
 
 	"sigs.k8s.io/yaml"
 )
 
 // Artefacts contains generated files for building a catalog from a bundle.
 type Artefacts struct {
-	FBCTemplate string // FBC template YAML
-	CatalogYAML string // Rendered catalog (if opm is available)
-	Dockerfile  string // Dockerfile for building catalog image
-	Makefile    string // Makefile for building and deploying catalog
+	FBCTemplate string         // FBC template YAML
+	CatalogYAML string         // Rendered catalog (if opm is available)
+	Dockerfile  string         // Dockerfile for building catalog image
+	Makefile    string         // Makefile for building and deploying catalog
+	Upgrade     *UpgradePlan   // Upgrade under test, when built on a released template
+	Rebuilt     *RebuildResult // Newest bundle rebuilt with image overrides, if any
+	Bundles     []*BundleInfo  // Bundles given, in version order, with the digests they were read at
+
+	// Variants holds a catalog and Dockerfile per OpenShift
+	// version when building for several; the default Dockerfile and
+	// catalog are those of the first.
+	Variants []CatalogVariant
+}
+
+// CatalogVariant is the catalog and Dockerfile for one OpenShift
+// version, saved as Target.CatalogFile() and Target.Dockerfile().
+type CatalogVariant struct {
+	Target      OCPTarget
+	CatalogYAML string
+	Dockerfile  string
+}
+
+// GeneratorOptions configures a Generator.
+type GeneratorOptions struct {
+	Package               string                          // Package the bundles must belong to (default: that of the bundles)
+	Channels              ChannelOptions                  // Channel layout (default: preview, or the template's default channel)
+	OpmBinPath            string                          // Optional path to external opm binary
+	OperatorImageOverride string                          // Optional operator image patched in after install
+	Rebuild               ImageOverrides                  // Images baked into a rebuild of the newest bundle
+	RebuildDestination    string                          // Where the rebuilt bundle is pushed
+	UpgradeFrom           string                          // Optional basic template whose channel head the bundles upgrade
+	Targets               []OCPTarget                     // OpenShift versions to build for (default: DefaultOCPVersion)
+	BuildVersion          string                          // Version label of the catalog image (default: the newest bundle's version)
+	Commit                string                          // Commit label of the catalog image
+	Namespace             func(packageName string) string // Namespace a package is installed in (default: the package name)
+	Templates             *Templates                      // Artefact templates (default: DefaultTemplates)
+	Environment           *Environment                    // Run-dependent values (default: CurrentEnvironment)
 }
 
 // Generator handles bundle to catalog conversion.
 type Generator struct {
-	bundleImage           string
-	channel               string
-	ompBinPath            string // Optional path to external opm binary
-	operatorImageOverride string // Optional operator image override
+	bundleImages []string
+	opts         GeneratorOptions
 }
 
-// NewGenerator creates a new bundle generator.
-func NewGenerator(bundleImage, channel, operatorImageOverride string) *Generator {
-	if channel == "" {
-		channel = "preview"
-	}
-	return &Generator{
-		bundleImage:           bundleImage,
-		channel:               channel,
-		operatorImageOverride: operatorImageOverride,
-	}
+// NewGenerator creates a new bundle generator for one or more bundle
+// images of the same package.
+func NewGenerator(bundleImages []string, channel, operatorImageOverride string) *Generator {
+	return NewGeneratorWithOptions(bundleImages, GeneratorOptions{
+		Channels:              singleChannel(channel),
+		OperatorImageOverride: operatorImageOverride,
+	})
 }
 
 // NewGeneratorWithOmp creates a new bundle generator with external opm binary.
-func NewGeneratorWithOmp(bundleImage, channel, ompBinPath, operatorImageOverride string) *Generator {
+func NewGeneratorWithOmp(bundleImages []string, channel, ompBinPath, operatorImageOverride string) *Generator {
+	return NewGeneratorWithOptions(bundleImages, GeneratorOptions{
+		Channels:              singleChannel(channel),
+		OpmBinPath:            ompBinPath,
+		OperatorImageOverride: operatorImageOverride,
+	})
+}
+
+// singleChannel returns the channel options for one channel, or the
+// defaults if channel is empty.
+func singleChannel(channel string) ChannelOptions {
 	if channel == "" {
-		channel = "preview"
+		return ChannelOptions{}
 	}
+	return ChannelOptions{Channels: []string{channel}}
+}
+
+// NewGeneratorWithOptions creates a new bundle generator.
+func NewGeneratorWithOptions(bundleImages []string, opts GeneratorOptions) *Generator {
 	return &Generator{
-		bundleImage:           bundleImage,
-		channel:               channel,
-		ompBinPath:            ompBinPath,
-		operatorImageOverride: operatorImageOverride,
+		bundleImages: bundleImages,
+		opts:         opts,
 	}
 }
 
-// Generate creates all artefacts needed to build a catalog from a
-// bundle.
+// Generate creates all artefacts needed to build a catalog from the
+// bundles. With several bundles the channel is an upgrade chain
+// ending at the newest, which the Makefile is named after. With
+// UpgradeFrom, the chain continues from the head of the template's
+// channel instead. With Rebuild, the newest bundle is first rebuilt
+// with the overridden images and pushed to RebuildDestination, and
+// the catalog uses the rebuilt bundle.
 func (g *Generator) Generate(ctx context.Context) (*Artefacts, error) {
-	fbcTemplate, err := GenerateFBCTemplate(g.bundleImage, g.channel)
+	ordered, err := extractBundleInfos(ctx, g.bundleImages)
 	if err != nil {
-		return nil, fmt.Errorf("generating FBC template: %w", err)
+		return nil, err
+	}
+
+	if p := ordered[0].Package; g.opts.Package != "" && p != g.opts.Package {
+		return nil, fmt.Errorf("bundles belong to package %s, not %s", p, g.opts.Package)
+	}
+
+	env := g.opts.Environment
+	if env == nil {
+		current := CurrentEnvironment()
+		env = &current
+	}
+
+	if o := g.opts.Rebuild; (o.Daemon != "" || o.Agent != "") && ordered[0].Package != DefaultPackage {
+		return nil, fmt.Errorf("daemon and agent image overrides apply only to %s, not %s", DefaultPackage, ordered[0].Package)
 	}
 
+	// Keep the bundles as given; a rebuild replaces the newest.
+	given := slices.Clone(ordered)
+
+	// Build the catalog template before rebuilding, so that invalid
+	// inputs fail before anything is pushed.
+	var (
+		fbcTemplate *FBCTemplate
+		upgrade     *UpgradePlan
+	)
+	if g.opts.UpgradeFrom != "" {
+		base, err := LoadFBCTemplate(g.opts.UpgradeFrom)
+		if err != nil {
+			return nil, err
+		}
+		fbcTemplate, upgrade, err = AppendUpgradeCandidates(base, ordered, g.opts.Channels)
+		if err != nil {
+			return nil, fmt.Errorf("adding candidates to %s: %w", g.opts.UpgradeFrom, err)
+		}
+	} else {
+		fbcTemplate, err = NewFBCTemplate(ordered, g.opts.Channels)
+		if err != nil {
+			return nil, fmt.Errorf("generating FBC template: %w", err)
+		}
+	}
+
+	var rebuilt *RebuildResult
+	if !g.opts.Rebuild.IsZero() {
+		// Image is pinned, so the rebuild starts from the bundle
+		// the metadata was read from, not whatever its tag points
+		// at now.
+		rebuiltInfo := *ordered[len(ordered)-1]
+		rebuilt, err = RebuildBundle(ctx, RebuildOptions{
+			Bundle:      rebuiltInfo.Image,
+			Destination: g.opts.RebuildDestination,
+			Images:      g.opts.Rebuild,
+			Created:     env.Time,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("rebuilding bundle: %w", err)
+		}
+		rebuiltInfo.Image = rebuilt.Image
+		ordered[len(ordered)-1] = &rebuiltInfo
+		if !fbcTemplate.replaceBundleImage(rebuiltInfo.Name, rebuiltInfo.Image) {
+			return nil, fmt.Errorf("template has no bundle %s to replace with the rebuild", rebuiltInfo.Name)
+		}
+	}
+	newest := ordered[len(ordered)-1]
+	head := newest.Image
+
 	fbcYAML, err := yaml.Marshal(fbcTemplate)
 	if err != nil {
 		return nil, fmt.Errorf("marshaling FBC template: %w", err)
 	}
 
-	execPath := getExecutablePath()
-	imageUUID, randomTTL := GenerateImageUUIDAndTTL()
+	targets := g.opts.Targets
+	if len(targets) == 0 {
+		if targets, err = ResolveOCPTargets(nil, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	dockerfile := DockerfileOptions{
+		Name:        "Dockerfile",
+		BaseImage:   targets[0].BaseImage,
+		CatalogFile: "catalog.yaml",
+		Package:     newest.Package,
+		Version:     g.opts.BuildVersion,
+		Commit:      g.opts.Commit,
+	}
+	if dockerfile.Version == "" {
+		dockerfile.Version = newest.Version.String()
+	}
+
+	templates := g.opts.Templates
+	if templates == nil {
+		if templates, err = DefaultTemplates(); err != nil {
+			return nil, err
+		}
+	}
+	makefileData, err := env.MakefileData(head, newest.Package, g.opts.OperatorImageOverride, upgrade, targets)
+	if err != nil {
+		return nil, err
+	}
+	if g.opts.Namespace != nil {
+		makefileData.Namespace = g.opts.Namespace(newest.Package)
+	}
+	if g.opts.OperatorImageOverride != "" {
+		if newest.OperatorDeployment == "" {
+			return nil, fmt.Errorf("bundle %s: cannot find the operator deployment and container to patch in its CSV", newest.Reference)
+		}
+		makefileData.OperatorDeployment = newest.OperatorDeployment
+		makefileData.OperatorContainer = newest.OperatorContainer
+	}
+	makefile, err := templates.Makefile(makefileData)
+	if err != nil {
+		return nil, fmt.Errorf("generating Makefile: %w", err)
+	}
 
 	artefacts := &Artefacts{
 		FBCTemplate: string(fbcYAML),
-		Dockerfile:  GenerateCatalogDockerfile(),
-		Makefile:    GenerateMakefile(g.bundleImage, execPath, imageUUID, randomTTL, g.operatorImageOverride),
+		Dockerfile:  GenerateCatalogDockerfile(dockerfile),
+		Makefile:    makefile,
+		Upgrade:     upgrade,
+		Rebuilt:     rebuilt,
+		Bundles:     given,
 	}
 
-	catalogYAML, err := g.renderCatalog(ctx, fbcTemplate)
-	if err != nil {
-		return artefacts, fmt.Errorf("rendering catalog: %w", err)
+	// Versions sharing a migrate level share a rendering, which
+	// pulls every bundle.
+	rendered := make(map[string]string)
+	for _, target := range targets {
+		if _, ok := rendered[target.MigrateLevel]; ok {
+			continue
+		}
+		catalogYAML, err := g.renderCatalog(ctx, fbcTemplate, target.MigrateLevel)
+		if err != nil {
+			return artefacts, fmt.Errorf("rendering catalog for OpenShift %s: %w", target.Version, err)
+		}
+		rendered[target.MigrateLevel] = catalogYAML
 	}
 
-	artefacts.CatalogYAML = catalogYAML
+	artefacts.CatalogYAML = rendered[targets[0].MigrateLevel]
+	if len(targets) > 1 {
+		for _, target := range targets {
+			variant := dockerfile
+			variant.Name = target.Dockerfile()
+			variant.BaseImage = target.BaseImage
+			variant.CatalogFile = target.CatalogFile()
+			artefacts.Variants = append(artefacts.Variants, CatalogVariant{
+				Target:      target,
+				CatalogYAML: rendered[target.MigrateLevel],
+				Dockerfile:  GenerateCatalogDockerfile(variant),
+			})
+		}
+	}
 	return artefacts, nil
 }
 
@@ -88,9 +264,9 @@ func getExecutablePath() string {
 	return execPath
 }
 
-func (g *Generator) renderCatalog(ctx context.Context, fbcTemplate *FBCTemplate) (string, error) {
-	if g.ompBinPath != "" {
-		return RenderCatalogWithBinary(ctx, fbcTemplate, g.ompBinPath)
+func (g *Generator) renderCatalog(ctx context.Context, fbcTemplate *FBCTemplate, migrateLevel string) (string, error) {
+	if g.opts.OpmBinPath != "" {
+		return RenderCatalogWithBinary(ctx, fbcTemplate, g.opts.OpmBinPath, migrateLevel)
 	}
-	return RenderCatalog(ctx, fbcTemplate)
+	return RenderCatalog(ctx, fbcTemplate, migrateLevel)
 }
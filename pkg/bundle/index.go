@@ -0,0 +1,91 @@
+package bundle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/openshift/bpfman-catalog/pkg/cachedir"
+)
+
+// indexVersion is bumped when the index format changes; indexes
+// written by other versions are discarded.
+const indexVersion = 1
+
+// Index records the metadata of every bundle tag seen in a
+// repository, keyed by tag. Each entry carries the manifest digest it
+// was read from, so a tag only needs inspecting again if it is new or
+// now points at a different manifest.
+type Index struct {
+	Version    int                        `json:"version"`
+	Repository string                     `json:"repository"`
+	Tags       map[string]*BundleMetadata `json:"tags"`
+
+	path string
+}
+
+// indexPath returns the index file for a repository.
+func indexPath(dir string, bundleRef BundleRef) string {
+	sum := sha256.Sum256([]byte(bundleRef.String()))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// LoadIndex reads the index for a repository from dir. A missing,
+// unreadable or outdated index is treated as empty, since it only
+// caches what can be fetched from the registry again.
+func LoadIndex(dir string, bundleRef BundleRef) *Index {
+	idx := &Index{
+		Version:    indexVersion,
+		Repository: bundleRef.String(),
+		Tags:       make(map[string]*BundleMetadata),
+		path:       indexPath(dir, bundleRef),
+	}
+
+	data, err := os.ReadFile(idx.path)
+	if err != nil {
+		return idx
+	}
+
+	var stored Index
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return idx
+	}
+	if stored.Version != indexVersion || stored.Repository != idx.Repository {
+		return idx
+	}
+	for tag, metadata := range stored.Tags {
+		if metadata != nil && metadata.Digest != "" {
+			idx.Tags[tag] = metadata
+		}
+	}
+	return idx
+}
+
+// Prune removes entries for tags that are no longer in the
+// repository.
+func (idx *Index) Prune(tags []string) {
+	present := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		present[tag] = true
+	}
+	for tag := range idx.Tags {
+		if !present[tag] {
+			delete(idx.Tags, tag)
+		}
+	}
+}
+
+// Save writes the index, replacing the previous file atomically.
+func (idx *Index) Save() error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling index: %w", err)
+	}
+	if err := cachedir.WriteFile(idx.path, data); err != nil {
+		return fmt.Errorf("saving index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package bundle
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/openshift/bpfman-catalog/pkg/registry"
+	"github.com/openshift/bpfman-catalog/pkg/registry/registrytest"
+)
+
+// configFetches counts the image config blobs fetched, which is the
+// per-tag cost the index avoids.
+func configFetches(srv *registrytest.Server) int {
+	n := 0
+	for _, req := range srv.Requests() {
+		if strings.HasPrefix(req, "GET ") && strings.Contains(req, "/blobs/") {
+			n++
+		}
+	}
+	return n
+}
+
+// TestListBundlesIndex inspects only new tags and tags whose digest
+// changed once the index has been populated.
+func TestListBundlesIndex(t *testing.T) {
+	srv := registrytest.NewServer(t)
+	const repo = "tenant/bpfman-operator-bundle-ystream"
+	first := strings.Repeat("a", gitCommitTagLength)
+	second := strings.Repeat("b", gitCommitTagLength)
+	third := strings.Repeat("c", gitCommitTagLength)
+
+	srv.Push(t, repo, first, registrytest.Image{
+		Labels: map[string]string{"build-date": "2025-01-01T00:00:00Z", "version": "0.6.0"},
+	})
+	srv.Push(t, repo, second, registrytest.Image{
+		Labels: map[string]string{"build-date": "2025-02-01T00:00:00Z", "version": "0.6.1"},
+	})
+
+	regOpts := registry.DefaultOptions()
+	regOpts.TLSVerify = false
+	ctx := registry.WithOptions(context.Background(), regOpts)
+
+	bundleRef, err := ParseBundleRef(srv.Host() + "/" + repo)
+	if err != nil {
+		t.Fatalf("ParseBundleRef: %v", err)
+	}
+	opts := ListOptions{IndexDir: t.TempDir()}
+
+	list := func(opts ListOptions) []*BundleMetadata {
+		t.Helper()
+		srv.ResetRequests()
+		bundles, _, err := ListBundles(ctx, bundleRef, opts)
+		if err != nil {
+			t.Fatalf("ListBundles: %v", err)
+		}
+		return bundles
+	}
+
+	if bundles := list(opts); len(bundles) != 2 {
+		t.Fatalf("got %d bundles, want 2", len(bundles))
+	}
+	if n := configFetches(srv); n != 2 {
+		t.Errorf("first run fetched %d configs, want 2", n)
+	}
+
+	if bundles := list(opts); len(bundles) != 2 {
+		t.Fatalf("got %d bundles, want 2", len(bundles))
+	}
+	if n := configFetches(srv); n != 0 {
+		t.Errorf("indexed run fetched %d configs, want 0", n)
+	}
+
+	srv.Push(t, repo, third, registrytest.Image{
+		Labels: map[string]string{"build-date": "2025-03-01T00:00:00Z", "version": "0.6.2"},
+	})
+	if bundles := list(opts); len(bundles) != 3 || bundles[2].Tag != third {
+		t.Fatalf("new tag not listed last: %+v", bundles)
+	}
+	if n := configFetches(srv); n != 1 {
+		t.Errorf("run after new tag fetched %d configs, want 1", n)
+	}
+
+	srv.Push(t, repo, second, registrytest.Image{
+		Labels: map[string]string{"build-date": "2025-02-01T00:00:00Z", "version": "0.6.1-rebuild"},
+	})
+	bundles := list(opts)
+	if n := configFetches(srv); n != 1 {
+		t.Errorf("run after retag fetched %d configs, want 1", n)
+	}
+	if bundles[1].Tag != second || bundles[1].Version != "0.6.1-rebuild" {
+		t.Errorf("retagged bundle = %+v, want version 0.6.1-rebuild", bundles[1])
+	}
+
+	opts.Refresh = true
+	list(opts)
+	if n := configFetches(srv); n != 3 {
+		t.Errorf("refresh fetched %d configs, want 3", n)
+	}
+}
@@ -2,25 +2,33 @@ package bundle
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/image"
 	"github.com/containers/image/v5/manifest"
-	"github.com/containers/image/v5/types"
 	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/openshift/bpfman-catalog/pkg/imageref"
+	"github.com/openshift/bpfman-catalog/pkg/pullrequest"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
+	"github.com/sirupsen/logrus"
 )
 
 const (
-	defaultRegistry    = "quay.io/redhat-user-workloads"
-	defaultTenant      = "ocp-bpfman-tenant"
+	defaultRegistry    = "quay.io"
+	defaultTenant      = "redhat-user-workloads/ocp-bpfman-tenant"
 	defaultBundleRepo  = "bpfman-operator-bundle-ystream"
 	maxConcurrency     = 10
-	gitCommitTagLength = 40
+	gitCommitTagLength = pullrequest.CommitLength
 )
 
 // BundleMetadata contains metadata about a bundle image.
@@ -31,20 +39,45 @@ type BundleMetadata struct {
 	BuildDate string        `json:"build_date"`
 	Version   string        `json:"version"`
 	Created   time.Time     `json:"created"`
+
+	// PullRequest is set for on-pr-<commit> builds once resolved.
+	PullRequest *pullrequest.PullRequest `json:"pull_request,omitempty"`
 }
 
-// BundleRef represents a bundle image reference.
+// BundleRef represents a bundle image reference. Local references
+// (OCI layouts, archives and directories) set Transport and Path
+// instead of the registry components.
 type BundleRef struct {
-	Registry string
-	Tenant   string
-	Repo     string
+	Transport string
+	Path      string
+	Registry  string // e.g., quay.io or localhost:5000
+	Tenant    string // Repository path up to the bundle repository
+	Repo      string // e.g., bpfman-operator-bundle-ystream
 }
 
 // String returns the full image reference.
 func (r BundleRef) String() string {
+	if r.Transport != "" {
+		return fmt.Sprintf("%s:%s", r.Transport, r.Path)
+	}
 	return fmt.Sprintf("%s/%s/%s", r.Registry, r.Tenant, r.Repo)
 }
 
+// IsLocal reports whether the reference is to a local OCI layout,
+// archive or directory.
+func (r BundleRef) IsLocal() bool {
+	return r.Transport != ""
+}
+
+// TaggedRef returns the image reference for a tag. Local references
+// other than OCI layouts hold a single image and ignore the tag.
+func (r BundleRef) TaggedRef(tag string) string {
+	if r.IsLocal() && (tag == "" || r.Transport != registry.TransportOCI) {
+		return r.String()
+	}
+	return fmt.Sprintf("%s:%s", r.String(), tag)
+}
+
 // NewDefaultBundleRef creates a bundle reference with default values.
 func NewDefaultBundleRef() BundleRef {
 	return BundleRef{
@@ -54,55 +87,43 @@ func NewDefaultBundleRef() BundleRef {
 	}
 }
 
-// isHexString checks if a string contains only lowercase hexadecimal characters.
-func isHexString(s string) bool {
-	for _, c := range s {
-		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
-			return false
-		}
-	}
-	return true
-}
-
 // isGitCommitTag checks if a tag is a 40-character git commit SHA.
 func isGitCommitTag(tag string) bool {
-	return len(tag) == gitCommitTagLength && isHexString(tag)
+	return pullrequest.IsCommit(tag)
 }
 
-// isPRTag checks if a tag is a PR build tag (on-pr-<commit-sha>).
+// isPRTag checks if a tag is a PR build tag (on-pr-<commit-sha>); see
+// pullrequest.CommitFromTag.
 func isPRTag(tag string) bool {
-	const prPrefix = "on-pr-"
-	if !strings.HasPrefix(tag, prPrefix) {
-		return false
-	}
-	commitPart := strings.TrimPrefix(tag, prPrefix)
-	return len(commitPart) == gitCommitTagLength && isHexString(commitPart)
+	_, ok := pullrequest.CommitFromTag(tag)
+	return ok
 }
 
-// filterBuildTags filters tags to include git commit SHAs and PR build tags.
-func filterBuildTags(tags []string) []string {
-	var buildTags []string
-	for _, tag := range tags {
-		if isGitCommitTag(tag) || isPRTag(tag) {
-			buildTags = append(buildTags, tag)
-		}
+// fetchTags fetches all tags for a bundle repository. An OCI layout
+// lists the image names recorded in its index; other local references
+// hold a single untagged image.
+func fetchTags(ctx context.Context, bundleRef BundleRef) ([]string, error) {
+	switch {
+	case bundleRef.Transport == registry.TransportOCI:
+		return readOCILayoutTags(bundleRef.Path)
+	case bundleRef.IsLocal():
+		return []string{""}, nil
 	}
-	return buildTags
-}
 
-// fetchTags fetches all tags for a bundle repository.
-func fetchTags(ctx context.Context, bundleRef BundleRef) ([]string, error) {
 	ref, err := docker.ParseReference(fmt.Sprintf("//%s", bundleRef.String()))
 	if err != nil {
 		return nil, fmt.Errorf("parsing reference %s: %w", bundleRef, err)
 	}
 
-	sys := &types.SystemContext{
-		OSChoice:           "linux",
-		ArchitectureChoice: "amd64",
-	}
+	sys := registry.SystemContext(ctx)
+	sys.OSChoice = "linux"
+	sys.ArchitectureChoice = "amd64"
 
-	tags, err := docker.GetRepositoryTags(ctx, sys, ref)
+	var tags []string
+	err = registry.Retry(ctx, func() error {
+		tags, err = docker.GetRepositoryTags(ctx, sys, ref)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("fetching tags for %s: %w", bundleRef, err)
 	}
@@ -110,26 +131,50 @@ func fetchTags(ctx context.Context, bundleRef BundleRef) ([]string, error) {
 	return tags, nil
 }
 
+// readOCILayoutTags returns the image names recorded in the index of
+// an OCI layout.
+func readOCILayoutTags(dir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, imgspecv1.ImageIndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("reading OCI layout index: %w", err)
+	}
+
+	var index imgspecv1.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("parsing OCI layout index: %w", err)
+	}
+
+	var tags []string
+	for _, m := range index.Manifests {
+		if name := m.Annotations[imgspecv1.AnnotationRefName]; name != "" {
+			tags = append(tags, name)
+		}
+	}
+	return tags, nil
+}
+
 // fetchBundleMetadata fetches metadata for a specific bundle tag.
 func fetchBundleMetadata(ctx context.Context, bundleRef BundleRef, tag string) (*BundleMetadata, error) {
-	taggedRef := fmt.Sprintf("%s:%s", bundleRef.String(), tag)
-	ref, err := docker.ParseReference(fmt.Sprintf("//%s", taggedRef))
+	taggedRef := bundleRef.TaggedRef(tag)
+	ref, err := registry.ParseReference(taggedRef)
 	if err != nil {
-		return nil, fmt.Errorf("parsing reference %s: %w", taggedRef, err)
+		return nil, err
 	}
 
-	sys := &types.SystemContext{
-		OSChoice:           "linux",
-		ArchitectureChoice: "amd64",
-	}
+	sys := registry.SystemContext(ctx)
+	sys.OSChoice = "linux"
+	sys.ArchitectureChoice = "amd64"
 
-	img, err := ref.NewImage(ctx, sys)
+	src, err := ref.NewImageSource(ctx, sys)
 	if err != nil {
-		return nil, fmt.Errorf("creating image for %s: %w", taggedRef, err)
+		return nil, fmt.Errorf("creating image source for %s: %w", taggedRef, err)
 	}
-	defer img.Close()
+	defer src.Close()
 
-	manifestBlob, _, err := img.Manifest(ctx)
+	// Record the digest the tag points at, which is the manifest
+	// list for multi-arch images, so it can be compared with the
+	// digest the registry reports for the tag.
+	manifestBlob, _, err := src.GetManifest(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("fetching manifest for %s: %w", taggedRef, err)
 	}
@@ -139,6 +184,11 @@ func fetchBundleMetadata(ctx context.Context, bundleRef BundleRef, tag string) (
 		return nil, fmt.Errorf("computing digest for %s: %w", taggedRef, err)
 	}
 
+	img, err := image.FromUnparsedImage(ctx, sys, image.UnparsedInstance(src, nil))
+	if err != nil {
+		return nil, fmt.Errorf("creating image for %s: %w", taggedRef, err)
+	}
+
 	inspect, err := img.Inspect(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("inspecting image %s: %w", taggedRef, err)
@@ -166,13 +216,35 @@ func fetchBundleMetadata(ctx context.Context, bundleRef BundleRef, tag string) (
 	return metadata, nil
 }
 
-// fetchAllBundleMetadata fetches metadata for all tags concurrently.
-func fetchAllBundleMetadata(ctx context.Context, bundleRef BundleRef, tags []string) ([]*BundleMetadata, error) {
-	var wg sync.WaitGroup
-	results := make(chan struct {
+// TagFailure records a tag whose metadata could not be read, or a
+// repository whose tags could not be listed, so it can be reported
+// rather than silently left out of a listing.
+type TagFailure struct {
+	Tag   string `json:"tag"`
+	Error string `json:"error"`
+}
+
+// failuresError summarises failures as a single error.
+func failuresError(failures []TagFailure) error {
+	errs := make([]error, 0, len(failures))
+	for _, f := range failures {
+		errs = append(errs, fmt.Errorf("tag %s: %s", f.Tag, f.Error))
+	}
+	return errors.Join(errs...)
+}
+
+// fetchAllBundleMetadata fetches metadata for all tags concurrently,
+// retrying transient failures under the registry retry policy. Tags
+// that still fail are returned as failures, sorted by tag.
+func fetchAllBundleMetadata(ctx context.Context, bundleRef BundleRef, tags []string) ([]*BundleMetadata, []TagFailure, error) {
+	type result struct {
+		tag      string
 		metadata *BundleMetadata
 		err      error
-	}, len(tags))
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan result, len(tags))
 	semaphore := make(chan struct{}, maxConcurrency)
 
 	for _, tag := range tags {
@@ -180,32 +252,21 @@ func fetchAllBundleMetadata(ctx context.Context, bundleRef BundleRef, tags []str
 		go func(tag string) {
 			defer wg.Done()
 
-			select {
-			case <-ctx.Done():
-				results <- struct {
-					metadata *BundleMetadata
-					err      error
-				}{nil, fmt.Errorf("tag %s: %w", tag, ctx.Err())}
-				return
-			default:
-			}
-
 			select {
 			case semaphore <- struct{}{}:
 				defer func() { <-semaphore }()
 			case <-ctx.Done():
-				results <- struct {
-					metadata *BundleMetadata
-					err      error
-				}{nil, fmt.Errorf("tag %s: %w", tag, ctx.Err())}
+				results <- result{tag: tag, err: ctx.Err()}
 				return
 			}
 
-			metadata, err := fetchBundleMetadata(ctx, bundleRef, tag)
-			results <- struct {
-				metadata *BundleMetadata
-				err      error
-			}{metadata, err}
+			var metadata *BundleMetadata
+			err := registry.Retry(ctx, func() error {
+				var err error
+				metadata, err = fetchBundleMetadata(ctx, bundleRef, tag)
+				return err
+			})
+			results <- result{tag: tag, metadata: metadata, err: err}
 		}(tag)
 	}
 
@@ -215,27 +276,22 @@ func fetchAllBundleMetadata(ctx context.Context, bundleRef BundleRef, tags []str
 	}()
 
 	var bundles []*BundleMetadata
-	var errs []error
+	var failures []TagFailure
 
-	for result := range results {
-		if result.err != nil {
-			if !errors.Is(result.err, context.Canceled) {
-				errs = append(errs, result.err)
-			}
-		} else if result.metadata != nil {
-			bundles = append(bundles, result.metadata)
+	for r := range results {
+		if r.err != nil {
+			failures = append(failures, TagFailure{Tag: r.tag, Error: r.err.Error()})
+		} else if r.metadata != nil {
+			bundles = append(bundles, r.metadata)
 		}
 	}
 
 	if ctx.Err() != nil {
-		return nil, fmt.Errorf("operation cancelled: %w", ctx.Err())
-	}
-
-	if len(bundles) == 0 && len(errs) > 0 {
-		return nil, fmt.Errorf("failed to fetch any bundles: %v", errors.Join(errs...))
+		return nil, nil, fmt.Errorf("operation cancelled: %w", ctx.Err())
 	}
 
-	return bundles, nil
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Tag < failures[j].Tag })
+	return bundles, failures, nil
 }
 
 // parseBuildDate attempts to parse a build date string into a time.Time.
@@ -277,69 +333,238 @@ func sortByBuildDate(bundles []*BundleMetadata) {
 }
 
 // ListLatestBundles lists the latest N bundle builds from a
-// repository.
+// repository, leaving out tags whose metadata could not be read.
 func ListLatestBundles(ctx context.Context, bundleRef BundleRef, limit int) ([]*BundleMetadata, error) {
+	bundles, _, err := ListBundles(ctx, bundleRef, ListOptions{Limit: limit})
+	return bundles, err
+}
+
+// ListBundles lists the bundle builds in a repository that pass the
+// filters in opts, oldest first, keeping the newest opts.Limit. Tags
+// whose metadata could not be read are returned as failures.
+// Registry repositories are filtered to build tags; every image in a
+// local OCI layout is considered unless a tag filter is set, when the
+// layout is filtered like a repository. Tag filters are refused for
+// other local references. When opts.IndexDir is
+// set, metadata read from the registry is recorded there and only new
+// tags, or selected tags whose digest changed, are inspected again.
+func ListBundles(ctx context.Context, bundleRef BundleRef, opts ListOptions) ([]*BundleMetadata, []TagFailure, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, nil, err
+	}
+
 	tags, err := fetchTags(ctx, bundleRef)
 	if err != nil {
-		return nil, fmt.Errorf("fetching tags: %w", err)
+		return nil, nil, fmt.Errorf("fetching tags: %w", err)
 	}
 
-	buildTags := filterBuildTags(tags)
+	buildTags := tags
+	switch {
+	case !bundleRef.IsLocal():
+		buildTags = opts.filterTags(tags)
+	case opts.hasTagFilters():
+		// Other local references hold a single untagged image.
+		if bundleRef.Transport != registry.TransportOCI {
+			return nil, nil, fmt.Errorf("tag filters cannot be applied to %s, which holds a single untagged image", bundleRef)
+		}
+		buildTags = opts.filterTags(tags)
+	}
 	if len(buildTags) == 0 {
-		return nil, fmt.Errorf("no build tags found among %d tags", len(tags))
+		return nil, nil, fmt.Errorf("%w among %d tags", ErrNoBuildTags, len(tags))
 	}
 
-	bundles, err := fetchAllBundleMetadata(ctx, bundleRef, buildTags)
-	if err != nil {
-		return nil, fmt.Errorf("fetching metadata: %w", err)
+	if opts.IndexDir == "" || bundleRef.IsLocal() {
+		all, failures, err := fetchAllBundleMetadata(ctx, bundleRef, buildTags)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fetching metadata: %w", err)
+		}
+		if len(all) == 0 {
+			return nil, failures, noBundlesError(failures)
+		}
+		return opts.selectBundles(all), failures, nil
 	}
 
-	if len(bundles) == 0 {
-		return nil, errors.New("no bundles with metadata found")
+	idx := LoadIndex(opts.IndexDir, bundleRef)
+	if opts.Refresh {
+		idx.Tags = make(map[string]*BundleMetadata)
 	}
+	idx.Prune(tags)
 
-	sortByBuildDate(bundles)
+	bundles, failures, err := listIndexedBundles(ctx, bundleRef, buildTags, idx, opts)
 
-	if limit > len(bundles) {
-		limit = len(bundles)
+	if err := idx.Save(); err != nil {
+		logrus.WithError(err).Warnf("not caching bundle metadata for %s", bundleRef)
 	}
 
-	// Take the last N (newest) bundles, preserving oldest-first order
-	return bundles[len(bundles)-limit:], nil
+	return bundles, failures, err
 }
 
-// ParseBundleRef parses a bundle image reference string into
-// components.
-func ParseBundleRef(imageRef string) (BundleRef, error) {
-	imageRef = strings.TrimPrefix(imageRef, "docker://")
+// ErrNoBuildTags is returned when a repository has no tags that name
+// bundle builds.
+var ErrNoBuildTags = errors.New("no build tags found")
 
-	if idx := strings.LastIndex(imageRef, ":"); idx != -1 && !strings.Contains(imageRef[idx:], "/") {
-		imageRef = imageRef[:idx]
+// noBundlesError reports that no tag yielded metadata.
+func noBundlesError(failures []TagFailure) error {
+	if len(failures) == 0 {
+		return errors.New("no bundles with metadata found")
+	}
+	return fmt.Errorf("failed to fetch any bundles: %w", failuresError(failures))
+}
+
+// listIndexedBundles selects bundles using the metadata recorded in
+// idx, inspecting tags the index has not seen. The digests of the
+// selected bundles are then checked against the registry; any that
+// moved are inspected again and the selection repeated.
+func listIndexedBundles(ctx context.Context, bundleRef BundleRef, tags []string, idx *Index, opts ListOptions) ([]*BundleMetadata, []TagFailure, error) {
+	var unseen []string
+	for _, tag := range tags {
+		if _, ok := idx.Tags[tag]; !ok {
+			unseen = append(unseen, tag)
+		}
 	}
-	if idx := strings.LastIndex(imageRef, "@"); idx != -1 {
-		imageRef = imageRef[:idx]
+	failures, err := refreshIndex(ctx, bundleRef, unseen, idx)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	parts := strings.Split(imageRef, "/")
-	if len(parts) < 3 {
-		return BundleRef{}, fmt.Errorf("invalid image reference format: %s (expected registry/tenant/repo)", imageRef)
+	for {
+		var all []*BundleMetadata
+		for _, tag := range tags {
+			if metadata, ok := idx.Tags[tag]; ok {
+				all = append(all, metadata)
+			}
+		}
+		if len(all) == 0 {
+			return nil, failures, noBundlesError(failures)
+		}
+
+		selected := opts.selectBundles(all)
+		changed, err := changedDigests(ctx, bundleRef, selected, unseen)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(changed) == 0 {
+			sort.Slice(failures, func(i, j int) bool { return failures[i].Tag < failures[j].Tag })
+			return selected, failures, nil
+		}
+		changedFailures, err := refreshIndex(ctx, bundleRef, changed, idx)
+		if err != nil {
+			return nil, nil, err
+		}
+		failures = append(failures, changedFailures...)
+		// Tags just inspected are current and need no further check.
+		unseen = append(unseen, changed...)
 	}
+}
 
-	if len(parts) == 4 && parts[0] == "quay.io" && parts[1] == "redhat-user-workloads" {
-		return BundleRef{
-			Registry: fmt.Sprintf("%s/%s", parts[0], parts[1]),
-			Tenant:   parts[2],
-			Repo:     parts[3],
-		}, nil
+// refreshIndex inspects tags and records their metadata in idx. Tags
+// that cannot be inspected are dropped from the index and returned as
+// failures.
+func refreshIndex(ctx context.Context, bundleRef BundleRef, tags []string, idx *Index) ([]TagFailure, error) {
+	if len(tags) == 0 {
+		return nil, nil
 	}
 
-	if len(parts) == 3 {
-		return BundleRef{
-			Registry: parts[0],
-			Tenant:   parts[1],
-			Repo:     parts[2],
-		}, nil
+	fetched, failures, err := fetchAllBundleMetadata(ctx, bundleRef, tags)
+	if err != nil {
+		return nil, fmt.Errorf("fetching metadata: %w", err)
+	}
+	for _, tag := range tags {
+		delete(idx.Tags, tag)
+	}
+	for _, metadata := range fetched {
+		idx.Tags[metadata.Tag] = metadata
 	}
+	return failures, nil
+}
+
+// changedDigests returns the tags of bundles whose manifest digest in
+// the registry no longer matches the recorded one, skipping tags in
+// current.
+func changedDigests(ctx context.Context, bundleRef BundleRef, bundles []*BundleMetadata, current []string) ([]string, error) {
+	skip := make(map[string]bool, len(current))
+	for _, tag := range current {
+		skip[tag] = true
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		changed   []string
+		semaphore = make(chan struct{}, maxConcurrency)
+	)
+
+	for _, b := range bundles {
+		if skip[b.Tag] {
+			continue
+		}
 
-	return BundleRef{}, fmt.Errorf("unsupported image reference format: %s", imageRef)
+		wg.Add(1)
+		go func(b *BundleMetadata) {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				return
+			}
+
+			d, err := fetchDigest(ctx, bundleRef.TaggedRef(b.Tag))
+			if err == nil && d == b.Digest {
+				return
+			}
+			mu.Lock()
+			changed = append(changed, b.Tag)
+			mu.Unlock()
+		}(b)
+	}
+
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("operation cancelled: %w", ctx.Err())
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// DigestRef returns the digest reference of a bundle in the
+// repository. Local references cannot be addressed by digest, so the
+// tagged reference is returned for them.
+func (r BundleRef) DigestRef(b *BundleMetadata) string {
+	if r.IsLocal() || b.Digest == "" {
+		return b.Image
+	}
+	return fmt.Sprintf("%s@%s", r.String(), b.Digest)
+}
+
+// ParseBundleRef parses a bundle image reference string into
+// components; see imageref.Parse. Any tag or digest is dropped, as
+// the whole repository is listed.
+func ParseBundleRef(imageRef string) (BundleRef, error) {
+	parsed, err := imageref.Parse(imageRef)
+	if err != nil {
+		return BundleRef{}, err
+	}
+	if parsed.IsLocal() {
+		path := parsed.Path
+		if parsed.Transport == registry.TransportOCI {
+			// Drop any image name; the whole layout is listed.
+			path, _, _ = strings.Cut(path, ":")
+		}
+		if path == "" {
+			return BundleRef{}, fmt.Errorf("invalid %s reference, missing path: %s", parsed.Transport, imageRef)
+		}
+		return BundleRef{Transport: parsed.Transport, Path: path}, nil
+	}
+
+	if parsed.Namespace() == "" {
+		return BundleRef{}, fmt.Errorf("invalid image reference format: %s (expected registry/tenant/repo)", imageRef)
+	}
+	return BundleRef{
+		Registry: parsed.Registry,
+		Tenant:   parsed.Namespace(),
+		Repo:     parsed.Base(),
+	}, nil
 }
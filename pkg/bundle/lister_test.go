@@ -0,0 +1,144 @@
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/bpfman-catalog/pkg/registry"
+	"github.com/openshift/bpfman-catalog/pkg/registry/registrytest"
+)
+
+// TestListLatestBundlesOCILayout lists bundles from a checked-out OCI
+// layout, as copied into a disconnected environment.
+func TestListLatestBundlesOCILayout(t *testing.T) {
+	layoutDir := t.TempDir()
+	older := strings.Repeat("a", gitCommitTagLength)
+	newer := strings.Repeat("b", gitCommitTagLength)
+	registrytest.WriteOCILayout(t, layoutDir, older, registrytest.Image{
+		Labels: map[string]string{"build-date": "2025-01-01T00:00:00Z", "version": "0.5.9"},
+	})
+	registrytest.WriteOCILayout(t, layoutDir, newer, registrytest.Image{
+		Labels: map[string]string{"build-date": "2025-02-01T00:00:00Z", "version": "0.6.0"},
+	})
+
+	bundleRef, err := ParseBundleRef("oci:" + layoutDir)
+	if err != nil {
+		t.Fatalf("ParseBundleRef: %v", err)
+	}
+
+	bundles, err := ListLatestBundles(context.Background(), bundleRef, 5)
+	if err != nil {
+		t.Fatalf("ListLatestBundles: %v", err)
+	}
+	if len(bundles) != 2 {
+		t.Fatalf("got %d bundles, want 2", len(bundles))
+	}
+	if bundles[1].Tag != newer || bundles[1].Version != "0.6.0" {
+		t.Errorf("newest bundle = %+v, want tag %s version 0.6.0", bundles[1], newer)
+	}
+	if want := "oci:" + layoutDir + ":" + newer; bundles[1].Image != want {
+		t.Errorf("image = %q, want %q", bundles[1].Image, want)
+	}
+}
+
+// TestListBundlesFiltersLocalTags applies tag filters to a local OCI
+// layout and refuses them for a single-image archive.
+func TestListBundlesFiltersLocalTags(t *testing.T) {
+	layoutDir := t.TempDir()
+	commit := strings.Repeat("a", gitCommitTagLength)
+	pr := "on-pr-" + strings.Repeat("b", gitCommitTagLength)
+	for _, tag := range []string{commit, pr, "bundle"} {
+		registrytest.WriteOCILayout(t, layoutDir, tag, registrytest.Image{
+			Labels: map[string]string{"build-date": "2025-01-01T00:00:00Z"},
+		})
+	}
+	bundleRef, err := ParseBundleRef("oci:" + layoutDir)
+	if err != nil {
+		t.Fatalf("ParseBundleRef: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts ListOptions
+		want []string
+	}{
+		{name: "no filters", want: []string{commit, "bundle", pr}},
+		{name: "pr only", opts: ListOptions{PROnly: true}, want: []string{pr}},
+		{name: "exclude pr", opts: ListOptions{ExcludePR: true}, want: []string{commit}},
+		{name: "commit", opts: ListOptions{CommitPrefix: "bbb"}, want: []string{pr}},
+		{name: "tag regex", opts: ListOptions{TagPattern: regexp.MustCompile("^bundle$")}, want: []string{commit, "bundle", pr}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundles, _, err := ListBundles(context.Background(), bundleRef, tt.opts)
+			if err != nil {
+				t.Fatalf("ListBundles: %v", err)
+			}
+			var tags []string
+			for _, b := range bundles {
+				tags = append(tags, b.Tag)
+			}
+			sort.Strings(tags)
+			if !reflect.DeepEqual(tags, tt.want) {
+				t.Errorf("tags = %v, want %v", tags, tt.want)
+			}
+		})
+	}
+
+	archiveRef := BundleRef{Transport: registry.TransportOCIArchive, Path: "bundle.tar"}
+	if _, _, err := ListBundles(context.Background(), archiveRef, ListOptions{PROnly: true}); err == nil {
+		t.Error("expected an error for tag filters on an archive")
+	}
+}
+
+// TestListBundlesRetriesTransientFailures lists bundles through rate
+// limiting and server errors, and reports a tag that keeps failing.
+func TestListBundlesRetriesTransientFailures(t *testing.T) {
+	srv := registrytest.NewServer(t)
+	const repo = "tenant/bpfman-operator-bundle-ystream"
+	healthy := strings.Repeat("a", gitCommitTagLength)
+	limited := strings.Repeat("b", gitCommitTagLength)
+	broken := strings.Repeat("c", gitCommitTagLength)
+
+	for i, tag := range []string{healthy, limited, broken} {
+		srv.Push(t, repo, tag, registrytest.Image{
+			Labels: map[string]string{"build-date": fmt.Sprintf("2025-01-0%dT00:00:00Z", i+1)},
+		})
+	}
+	srv.InjectFailures("/tags/list", http.StatusServiceUnavailable, 2)
+	// More 429s than containers/image retries internally, so the
+	// shared retry policy has to recover.
+	srv.InjectFailures("/manifests/"+limited, http.StatusTooManyRequests, 6)
+	srv.InjectFailures("/manifests/"+broken, http.StatusInternalServerError, -1)
+
+	regOpts := registry.DefaultOptions()
+	regOpts.TLSVerify = false
+	regOpts.Retry = registry.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	ctx := registry.WithOptions(context.Background(), regOpts)
+
+	bundleRef, err := ParseBundleRef(srv.Host() + "/" + repo)
+	if err != nil {
+		t.Fatalf("ParseBundleRef: %v", err)
+	}
+
+	bundles, failures, err := ListBundles(ctx, bundleRef, ListOptions{})
+	if err != nil {
+		t.Fatalf("ListBundles: %v", err)
+	}
+	if len(bundles) != 2 || bundles[0].Tag != healthy || bundles[1].Tag != limited {
+		t.Errorf("bundles = %+v, want %s and %s", bundles, healthy, limited)
+	}
+	if len(failures) != 1 || failures[0].Tag != broken {
+		t.Fatalf("failures = %+v, want only %s", failures, broken)
+	}
+	if !strings.Contains(failures[0].Error, "after 3 attempts") {
+		t.Errorf("failure error = %q, want it to report 3 attempts", failures[0].Error)
+	}
+}
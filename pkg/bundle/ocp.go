@@ -0,0 +1,103 @@
+package bundle
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/operator-framework/operator-registry/alpha/action/migrations"
+)
+
+// DefaultOCPVersion is the OpenShift version catalogs are built for
+// when none is given.
+const DefaultOCPVersion = "4.20"
+
+// Migrate levels applied when rendering catalogs. OpenShift 4.17
+// and later expect bundle metadata as olm.csv.metadata; earlier
+// releases need the olm.bundle.object form opm renders by default.
+const (
+	MigrateLevelNone        = migrations.NoMigrations
+	MigrateLevelCSVMetadata = "bundle-object-to-csv-metadata"
+)
+
+// DefaultMigrateLevel is the migrate level for DefaultOCPVersion.
+const DefaultMigrateLevel = MigrateLevelCSVMetadata
+
+// OCPTarget is an OpenShift version a catalog image is built for.
+type OCPTarget struct {
+	Version      string // OpenShift minor version, e.g. 4.20
+	BaseImage    string // opm image the catalog image is built on
+	MigrateLevel string // opm migrate level for rendering the catalog
+}
+
+// Name returns the suffix of files and Makefile targets for the
+// target, e.g. v4.20.
+func (t OCPTarget) Name() string {
+	return "v" + t.Version
+}
+
+// Dockerfile returns the name of the target's Dockerfile when a
+// catalog is built for several versions.
+func (t OCPTarget) Dockerfile() string {
+	return "Dockerfile." + t.Name()
+}
+
+// CatalogFile returns the name of the target's rendered catalog when
+// a catalog is built for several versions.
+func (t OCPTarget) CatalogFile() string {
+	return "catalog-" + t.Name() + ".yaml"
+}
+
+// ResolveOCPTargets maps OpenShift versions, such as 4.16 or v4.16,
+// to build targets, in the order given. No versions means
+// DefaultOCPVersion. A non-empty baseImage replaces the mapped opm
+// image of every target.
+func ResolveOCPTargets(versions []string, baseImage string) ([]OCPTarget, error) {
+	if len(versions) == 0 {
+		versions = []string{DefaultOCPVersion}
+	}
+
+	var targets []OCPTarget
+	seen := make(map[string]bool, len(versions))
+	for _, v := range versions {
+		target, err := resolveOCPTarget(v)
+		if err != nil {
+			return nil, err
+		}
+		if seen[target.Version] {
+			return nil, fmt.Errorf("OpenShift version %s given more than once", target.Version)
+		}
+		seen[target.Version] = true
+		if baseImage != "" {
+			target.BaseImage = baseImage
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// resolveOCPTarget maps one OpenShift 4.x version to its opm image
+// and migrate level.
+func resolveOCPTarget(version string) (OCPTarget, error) {
+	v := strings.TrimPrefix(version, "v")
+	major, minorStr, ok := strings.Cut(v, ".")
+	minor, err := strconv.Atoi(minorStr)
+	if !ok || major != "4" || err != nil || minor < 0 {
+		return OCPTarget{}, fmt.Errorf("invalid OpenShift version %q: want 4.<minor>, e.g. %s", version, DefaultOCPVersion)
+	}
+	v = fmt.Sprintf("4.%d", minor)
+
+	target := OCPTarget{
+		Version:      v,
+		BaseImage:    "registry.redhat.io/openshift4/ose-operator-registry-rhel9:v" + v,
+		MigrateLevel: MigrateLevelCSVMetadata,
+	}
+	// The RHEL 9 opm image is published from 4.15 on.
+	if minor < 15 {
+		target.BaseImage = "registry.redhat.io/openshift4/ose-operator-registry:v" + v
+	}
+	if minor < 17 {
+		target.MigrateLevel = MigrateLevelNone
+	}
+	return target, nil
+}
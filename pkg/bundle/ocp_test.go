@@ -0,0 +1,90 @@
+package bundle
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveOCPTargets(t *testing.T) {
+	tests := []struct {
+		name      string
+		versions  []string
+		baseImage string
+		want      []OCPTarget
+		wantErr   bool
+	}{
+		{
+			name: "default",
+			want: []OCPTarget{{
+				Version:      DefaultOCPVersion,
+				BaseImage:    "registry.redhat.io/openshift4/ose-operator-registry-rhel9:v" + DefaultOCPVersion,
+				MigrateLevel: MigrateLevelCSVMetadata,
+			}},
+		},
+		{
+			name:     "matrix",
+			versions: []string{"v4.14", "4.16", "4.17"},
+			want: []OCPTarget{
+				{Version: "4.14", BaseImage: "registry.redhat.io/openshift4/ose-operator-registry:v4.14", MigrateLevel: MigrateLevelNone},
+				{Version: "4.16", BaseImage: "registry.redhat.io/openshift4/ose-operator-registry-rhel9:v4.16", MigrateLevel: MigrateLevelNone},
+				{Version: "4.17", BaseImage: "registry.redhat.io/openshift4/ose-operator-registry-rhel9:v4.17", MigrateLevel: MigrateLevelCSVMetadata},
+			},
+		},
+		{
+			name:      "base image override",
+			versions:  []string{"4.16", "4.18"},
+			baseImage: "quay.io/operator-framework/opm:v1.60.0",
+			want: []OCPTarget{
+				{Version: "4.16", BaseImage: "quay.io/operator-framework/opm:v1.60.0", MigrateLevel: MigrateLevelNone},
+				{Version: "4.18", BaseImage: "quay.io/operator-framework/opm:v1.60.0", MigrateLevel: MigrateLevelCSVMetadata},
+			},
+		},
+		{name: "not 4.x", versions: []string{"5.1"}, wantErr: true},
+		{name: "no minor", versions: []string{"4"}, wantErr: true},
+		{name: "patch version", versions: []string{"4.16.3"}, wantErr: true},
+		{name: "duplicate", versions: []string{"4.16", "v4.16"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveOCPTargets(tt.versions, tt.baseImage)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveOCPTargets: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateMakefileOCPTargets(t *testing.T) {
+	targets, err := ResolveOCPTargets([]string{"4.16", "4.20"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	makefile := generateMakefile(t, "quay.io/tenant/bundle:v0.6.0", "bpfman-catalog", "uuid", "1h", "", nil, targets)
+	for _, s := range []string{
+		"build-catalog-image-v4.16:",
+		"-f Dockerfile.v4.16 -t $(IMAGE_v4.16)",
+		"deploy-catalog-v4.20:",
+		"build-catalog-images: build-catalog-image-v4.16 build-catalog-image-v4.20",
+		"build-catalog-image catalog-v4.16.yaml --package $(PACKAGE) --base-image registry.redhat.io/openshift4/ose-operator-registry-rhel9:v4.16 --destination $(IMAGE_v4.16) --digest-file $(DIGEST_FILE_v4.16)",
+		"DIGEST_FILE_v4.20 := .catalog-digest-v4.20",
+		"deploy-catalog IMAGE=$(IMAGE_v4.20) DIGEST_FILE=$(DIGEST_FILE_v4.20)",
+	} {
+		if !strings.Contains(makefile, s) {
+			t.Errorf("Makefile does not contain %q", s)
+		}
+	}
+
+	if makefile := generateMakefile(t, "quay.io/tenant/bundle:v0.6.0", "bpfman-catalog", "uuid", "1h", "", nil, targets[:1]); strings.Contains(makefile, "build-catalog-images") {
+		t.Error("Makefile for one version has per-version targets")
+	}
+}
@@ -0,0 +1,61 @@
+package bundle
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/openshift/bpfman-catalog/pkg/pullrequest"
+)
+
+// ResolvePullRequests records the pull request each on-pr-<commit>
+// bundle was built for, using the resolver carried by ctx. Lookups
+// run concurrently. Bundles whose pull request is unknown or cannot
+// be looked up are left unchanged; the lookup errors are returned.
+// Nothing is resolved if ctx carries no resolver.
+func ResolvePullRequests(ctx context.Context, bundles []*BundleMetadata) []error {
+	resolver := pullrequest.ResolverFromContext(ctx)
+	if resolver == nil {
+		return nil
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		errs      []error
+		semaphore = make(chan struct{}, maxConcurrency)
+	)
+	for _, b := range bundles {
+		if _, ok := pullrequest.CommitFromTag(b.Tag); !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(b *BundleMetadata) {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				return
+			}
+
+			pr, err := resolver.ResolveTag(ctx, "", b.Tag)
+			switch {
+			case errors.Is(err, pullrequest.ErrNotFound):
+			case err != nil:
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			default:
+				b.PullRequest = pr
+			}
+		}(b)
+	}
+	wg.Wait()
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errs
+}
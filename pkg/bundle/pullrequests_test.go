@@ -0,0 +1,71 @@
+package bundle
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/openshift/bpfman-catalog/pkg/pullrequest"
+)
+
+// lookupFunc adapts a function to pullrequest.Provider, counting
+// lookups.
+type lookupFunc struct {
+	mu    sync.Mutex
+	calls int
+	fn    func(commit string) (*pullrequest.PullRequest, error)
+}
+
+func (l *lookupFunc) Lookup(_ context.Context, _, commit string) (*pullrequest.PullRequest, error) {
+	l.mu.Lock()
+	l.calls++
+	l.mu.Unlock()
+	return l.fn(commit)
+}
+
+// TestResolvePullRequests records known pull requests, skips unknown
+// ones quietly and reports a failed lookup once, without retrying.
+func TestResolvePullRequests(t *testing.T) {
+	known := strings.Repeat("a", gitCommitTagLength)
+	unknown := strings.Repeat("b", gitCommitTagLength)
+	failing := strings.Repeat("c", gitCommitTagLength)
+	provider := &lookupFunc{fn: func(commit string) (*pullrequest.PullRequest, error) {
+		switch commit {
+		case known:
+			return &pullrequest.PullRequest{Number: 42, State: pullrequest.StateMerged}, nil
+		case failing:
+			return nil, errors.New("connection refused")
+		}
+		return nil, pullrequest.ErrNotFound
+	}}
+	ctx := pullrequest.WithResolver(context.Background(), &pullrequest.Resolver{Provider: provider, Repository: pullrequest.DefaultRepository})
+
+	bundles := []*BundleMetadata{
+		{Tag: "on-pr-" + known},
+		{Tag: "on-pr-" + unknown},
+		{Tag: "on-pr-" + failing},
+		{Tag: known},
+	}
+	errs := ResolvePullRequests(ctx, bundles)
+
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "connection refused") {
+		t.Errorf("errors = %v, want the failed lookup only", errs)
+	}
+	if provider.calls != 3 {
+		t.Errorf("made %d lookups, want one per on-pr- tag", provider.calls)
+	}
+	if pr := bundles[0].PullRequest; pr == nil || pr.Number != 42 {
+		t.Errorf("pull request = %+v, want #42", pr)
+	}
+	for _, b := range bundles[1:] {
+		if b.PullRequest != nil {
+			t.Errorf("tag %s: pull request = %+v, want none", b.Tag, b.PullRequest)
+		}
+	}
+
+	if errs := ResolvePullRequests(context.Background(), bundles); errs != nil {
+		t.Errorf("errors without a resolver = %v", errs)
+	}
+}
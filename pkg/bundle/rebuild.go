@@ -0,0 +1,430 @@
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/openshift/bpfman-catalog/pkg/imageref"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
+	"github.com/operator-framework/operator-registry/pkg/image"
+	"gopkg.in/yaml.v3"
+)
+
+// Names of the ConfigMap and entries that carry the daemon and agent
+// images of a DefaultPackage bundle.
+const (
+	ConfigMapName  = "bpfman-config"
+	DaemonImageKey = "bpfman.image"
+	AgentImageKey  = "bpfman.agent.image"
+)
+
+// packageLabel is the bundle image label naming its package.
+const packageLabel = "operators.operatorframework.io.bundle.package.v1"
+
+// ImageOverrides are the images baked into a rebuilt bundle. Empty
+// fields leave the bundle's image unchanged. Daemon and Agent apply
+// only to DefaultPackage bundles.
+type ImageOverrides struct {
+	Operator string // Operator container in the CSV, and its relatedImages entry
+	Daemon   string // bpfman.image in bpfman-config
+	Agent    string // bpfman.agent.image in bpfman-config
+}
+
+// IsZero reports whether no image is overridden.
+func (o ImageOverrides) IsZero() bool {
+	return o == ImageOverrides{}
+}
+
+// RebuildOptions configures RebuildBundle.
+type RebuildOptions struct {
+	Bundle      string         // Bundle image to rebuild
+	Destination string         // Registry reference, or oci:<dir>:<tag> and other local transports
+	Images      ImageOverrides // Images to bake into the bundle
+	Created     time.Time      // Creation time recorded in the image (default: now)
+}
+
+// ImageChange records an image reference replaced in a bundle.
+type ImageChange struct {
+	File string `json:"file"`
+	Path string `json:"path"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// RebuildResult describes a rebuilt and pushed bundle image.
+type RebuildResult struct {
+	Bundle      string        `json:"bundle"`
+	Destination string        `json:"destination"`
+	Digest      digest.Digest `json:"digest"`
+	Image       string        `json:"image"` // Destination pinned by digest, for use in a catalog
+	Changes     []ImageChange `json:"changes"`
+}
+
+// RebuildBundle unpacks a bundle image, rewrites the operator image
+// in the CSV deployments and relatedImages and, for DefaultPackage,
+// the daemon and agent images in bpfman-config, and pushes the result as a single-layer
+// image with the original labels, so that OLM installs the
+// overridden images rather than having them patched in afterwards.
+func RebuildBundle(ctx context.Context, opts RebuildOptions) (*RebuildResult, error) {
+	if opts.Images.IsZero() {
+		return nil, fmt.Errorf("no image overrides given for %s", opts.Bundle)
+	}
+	if opts.Created.IsZero() {
+		opts.Created = time.Now()
+	}
+	opts.Created = opts.Created.UTC()
+
+	if _, err := registry.ParseReference(opts.Destination); err != nil {
+		return nil, err
+	}
+
+	reg, err := registry.NewImageRegistry(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer reg.Destroy()
+
+	workDir, err := os.MkdirTemp("", "bpfman-catalog-rebuild-")
+	if err != nil {
+		return nil, fmt.Errorf("creating build directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	ref := image.SimpleReference(opts.Bundle)
+	if err := reg.Pull(ctx, ref); err != nil {
+		return nil, err
+	}
+	unpackDir := filepath.Join(workDir, "bundle")
+	if err := reg.Unpack(ctx, ref, unpackDir); err != nil {
+		return nil, err
+	}
+	source, err := reg.Config(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if p := source.Config.Labels[packageLabel]; p != DefaultPackage && (opts.Images.Daemon != "" || opts.Images.Agent != "") {
+		return nil, fmt.Errorf("bundle %s is package %q: daemon and agent image overrides apply only to %s", opts.Bundle, p, DefaultPackage)
+	}
+
+	files, err := readTree(unpackDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading bundle %s: %w", opts.Bundle, err)
+	}
+	changes, err := overrideImages(files, opts.Images)
+	if err != nil {
+		return nil, fmt.Errorf("rewriting bundle %s: %w", opts.Bundle, err)
+	}
+
+	// The rebuilt bundle is for the same platform as the original.
+	l, err := registry.ScratchLayout(filepath.Join(workDir, "layout"), source.Platform)
+	if err != nil {
+		return nil, err
+	}
+	layer, diffID, err := registry.TarGzLayer(files, opts.Created)
+	if err != nil {
+		return nil, err
+	}
+	err = l.AppendLayer(layer, diffID, func(config *imgspecv1.Image) {
+		config.Config.Labels = source.Config.Labels
+		config.Created = &opts.Created
+		config.History = append(config.History, imgspecv1.History{
+			Created:   &opts.Created,
+			CreatedBy: "bpfman-catalog rebuild-bundle",
+			Comment:   "rebuilt from " + opts.Bundle,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := l.Push(ctx, opts.Destination)
+	if err != nil {
+		return nil, err
+	}
+	return &RebuildResult{
+		Bundle:      opts.Bundle,
+		Destination: opts.Destination,
+		Digest:      d,
+		Image:       pinnedReference(opts.Destination, d),
+		Changes:     changes,
+	}, nil
+}
+
+// pinnedReference returns a registry destination with its tag
+// replaced by digest d. Local transport references are returned
+// unchanged, as they cannot carry a digest.
+func pinnedReference(destination string, d digest.Digest) string {
+	ref, err := imageref.Parse(destination)
+	if err != nil || ref.IsLocal() {
+		return destination
+	}
+	ref.Digest = d
+	return ref.DigestRef()
+}
+
+// readTree returns the regular files under dir keyed by their
+// slash-separated path relative to dir.
+func readTree(dir string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	return files, err
+}
+
+// overrideImages rewrites the bundle manifests in files and returns
+// the images replaced. Every override must find the image it
+// replaces. Only the image values are rewritten; the rest of each
+// file, including comments and key order, is left as it was.
+func overrideImages(files map[string][]byte, images ImageOverrides) ([]ImageChange, error) {
+	var names []string
+	for name := range files {
+		if strings.HasPrefix(name, "manifests/") && (strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var (
+		changes             []ImageChange
+		edits               = make(map[string][]scalarEdit)
+		csvFile, configFile string
+		csv, config         *yaml.Node
+	)
+	for _, name := range names {
+		var doc yaml.Node
+		if err := yaml.Unmarshal(files[name], &doc); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", name, err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		obj := doc.Content[0]
+		switch kind := nestedString(obj, "kind"); {
+		case kind == "ClusterServiceVersion":
+			csvFile, csv = name, obj
+		case kind == "ConfigMap" && nestedString(obj, "metadata", "name") == ConfigMapName:
+			configFile, config = name, obj
+		}
+	}
+
+	replace := func(file, path string, node *yaml.Node, to string) {
+		changes = append(changes, ImageChange{File: file, Path: path, From: node.Value, To: to})
+		edits[file] = append(edits[file], scalarEdit{node: node, value: to})
+	}
+
+	// Images replaced anywhere are also replaced in relatedImages.
+	replaced := make(map[string]string)
+
+	if images.Operator != "" {
+		if csv == nil {
+			return nil, fmt.Errorf("no ClusterServiceVersion in bundle manifests")
+		}
+		deployment, container, err := operatorContainer(csv)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", csvFile, err)
+		}
+		image := scalar(child(container, "image"))
+		if image == nil {
+			return nil, fmt.Errorf("%s: operator container %s has no image", csvFile, nestedString(container, "name"))
+		}
+		old := image.Value
+		replaced[old] = images.Operator
+		replace(csvFile, fmt.Sprintf("spec.install.spec.deployments[%s].containers[%s].image", deployment, nestedString(container, "name")), image, images.Operator)
+		if annotation := scalar(nested(csv, "metadata", "annotations", "containerImage")); annotation != nil && annotation.Value == old {
+			replace(csvFile, "metadata.annotations.containerImage", annotation, images.Operator)
+		}
+	}
+
+	for _, o := range []struct{ key, image string }{
+		{DaemonImageKey, images.Daemon},
+		{AgentImageKey, images.Agent},
+	} {
+		if o.image == "" {
+			continue
+		}
+		if config == nil {
+			return nil, fmt.Errorf("no %s ConfigMap in bundle manifests", ConfigMapName)
+		}
+		entry := scalar(nested(config, "data", o.key))
+		if entry == nil {
+			return nil, fmt.Errorf("%s has no %s entry", configFile, o.key)
+		}
+		replaced[entry.Value] = o.image
+		replace(configFile, "data."+o.key, entry, o.image)
+	}
+
+	if related := nested(csv, "spec", "relatedImages"); related != nil && related.Kind == yaml.SequenceNode {
+		for i, entry := range related.Content {
+			image := scalar(child(entry, "image"))
+			if image == nil {
+				continue
+			}
+			if to, ok := replaced[image.Value]; ok {
+				replace(csvFile, fmt.Sprintf("spec.relatedImages[%d].image", i), image, to)
+			}
+		}
+	}
+
+	for file, fileEdits := range edits {
+		data, err := patchScalars(files[file], fileEdits)
+		if err != nil {
+			return nil, fmt.Errorf("rewriting %s: %w", file, err)
+		}
+		files[file] = data
+	}
+	return changes, nil
+}
+
+// scalarEdit replaces the value of a scalar node parsed from a file.
+type scalarEdit struct {
+	node  *yaml.Node
+	value string
+}
+
+// patchScalars returns data with each edited scalar replaced where it
+// appears in the text, keeping its quoting style.
+func patchScalars(data []byte, edits []scalarEdit) ([]byte, error) {
+	lines := strings.SplitAfter(string(data), "\n")
+	for _, e := range edits {
+		var quote string
+		switch e.node.Style {
+		case 0:
+		case yaml.DoubleQuotedStyle:
+			quote = `"`
+		case yaml.SingleQuotedStyle:
+			quote = "'"
+		default:
+			return nil, fmt.Errorf("line %d: unsupported style for %q", e.node.Line, e.node.Value)
+		}
+		old := quote + e.node.Value + quote
+		if e.node.Line < 1 || e.node.Line > len(lines) {
+			return nil, fmt.Errorf("line %d: out of range", e.node.Line)
+		}
+		line := lines[e.node.Line-1]
+		// Columns count characters, so the value starts at or after
+		// the column's byte offset.
+		start := min(e.node.Column-1, len(line))
+		i := strings.Index(line[start:], old)
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: %q not found", e.node.Line, e.node.Value)
+		}
+		i += start
+		lines[e.node.Line-1] = line[:i] + quote + e.value + quote + line[i+len(old):]
+	}
+	return []byte(strings.Join(lines, "")), nil
+}
+
+// operatorContainer returns the name of the CSV install deployment
+// that runs the operator and its operator container. The operator
+// container is the one running the CSV's containerImage annotation;
+// without a match, it is the container named after its deployment,
+// or the only container of the only deployment.
+func operatorContainer(csv *yaml.Node) (string, *yaml.Node, error) {
+	deployments := nested(csv, "spec", "install", "spec", "deployments")
+	if deployments == nil || deployments.Kind != yaml.SequenceNode || len(deployments.Content) == 0 {
+		return "", nil, fmt.Errorf("no deployments in spec.install.spec")
+	}
+	containers := func(d *yaml.Node) []*yaml.Node {
+		c := nested(d, "spec", "template", "spec", "containers")
+		if c == nil || c.Kind != yaml.SequenceNode {
+			return nil
+		}
+		return c.Content
+	}
+
+	if image := nestedString(csv, "metadata", "annotations", "containerImage"); image != "" {
+		for _, d := range deployments.Content {
+			for _, c := range containers(d) {
+				if nestedString(c, "image") == image {
+					return nestedString(d, "name"), c, nil
+				}
+			}
+		}
+	}
+	for _, d := range deployments.Content {
+		name := nestedString(d, "name")
+		for _, c := range containers(d) {
+			if nestedString(c, "name") == name {
+				return name, c, nil
+			}
+		}
+	}
+	if d := deployments.Content[0]; len(deployments.Content) == 1 && len(containers(d)) == 1 {
+		return nestedString(d, "name"), containers(d)[0], nil
+	}
+	return "", nil, fmt.Errorf("cannot tell which deployment container runs the operator: no container runs the containerImage annotation or is named after its deployment")
+}
+
+// operatorNames returns the names of the operator deployment and
+// container in a CSV given as YAML or JSON; see operatorContainer.
+func operatorNames(csv []byte) (string, string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(csv, &doc); err != nil {
+		return "", "", fmt.Errorf("parsing CSV: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return "", "", fmt.Errorf("empty CSV")
+	}
+	deployment, container, err := operatorContainer(doc.Content[0])
+	if err != nil {
+		return "", "", err
+	}
+	return deployment, nestedString(container, "name"), nil
+}
+
+// child returns the value of key in a mapping node, or nil.
+func child(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// nested returns the node at the given path of mapping keys, or nil.
+func nested(n *yaml.Node, keys ...string) *yaml.Node {
+	for _, k := range keys {
+		n = child(n, k)
+	}
+	return n
+}
+
+// scalar returns n if it is a scalar node, or nil.
+func scalar(n *yaml.Node) *yaml.Node {
+	if n == nil || n.Kind != yaml.ScalarNode {
+		return nil
+	}
+	return n
+}
+
+func nestedString(n *yaml.Node, keys ...string) string {
+	if s := scalar(nested(n, keys...)); s != nil {
+		return s.Value
+	}
+	return ""
+}
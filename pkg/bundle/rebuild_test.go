@@ -0,0 +1,283 @@
+package bundle
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
+	"github.com/openshift/bpfman-catalog/pkg/registry/registrytest"
+	"github.com/operator-framework/operator-registry/pkg/image"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	testOperatorImage = "quay.io/bpfman/bpfman-operator@sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	testDaemonImage   = "quay.io/bpfman/bpfman@sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	testAgentImage    = "quay.io/bpfman/bpfman-agent@sha256:3333333333333333333333333333333333333333333333333333333333333333"
+)
+
+const testCSV = `apiVersion: operators.coreos.com/v1alpha1
+kind: ClusterServiceVersion
+metadata:
+  name: bpfman-operator.v0.6.0
+  annotations:
+    containerImage: ` + testOperatorImage + `
+spec:
+  version: 0.6.0
+  install:
+    strategy: deployment
+    spec:
+      deployments:
+        - name: bpfman-operator
+          spec:
+            template:
+              spec:
+                containers:
+                  - name: kube-rbac-proxy
+                    image: quay.io/brancz/kube-rbac-proxy:v0.18.0
+                  - name: bpfman-operator
+                    image: ` + testOperatorImage + `
+  relatedImages:
+    - name: bpfman-operator
+      image: ` + testOperatorImage + `
+    - name: bpfman-agent
+      image: ` + testAgentImage + `
+`
+
+const testConfigMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: bpfman-config
+data:
+  bpfman.image: ` + testDaemonImage + `
+  bpfman.agent.image: ` + testAgentImage + `
+  bpfman.log.level: info
+`
+
+// TestRebuildBundle rebuilds a bundle from an OCI layout with all
+// three images overridden, pushes it to a registry and checks the
+// rewritten manifests and preserved labels.
+func TestRebuildBundle(t *testing.T) {
+	layoutDir := t.TempDir()
+	labels := map[string]string{
+		"operators.operatorframework.io.bundle.package.v1":   "bpfman-operator",
+		"operators.operatorframework.io.bundle.manifests.v1": "manifests/",
+	}
+	registrytest.WriteOCILayout(t, layoutDir, "bundle", registrytest.Image{
+		Files: map[string]string{
+			"manifests/bpfman-operator.clusterserviceversion.yaml": testCSV,
+			"manifests/bpfman-config_v1_configmap.yaml":            testConfigMap,
+			"metadata/annotations.yaml":                            "annotations:\n  operators.operatorframework.io.bundle.package.v1: bpfman-operator\n",
+		},
+		Labels: labels,
+	})
+
+	server := registrytest.NewServer(t)
+	opts := registry.DefaultOptions()
+	opts.TLSVerify = false
+	ctx := registry.WithOptions(context.Background(), opts)
+
+	overrides := ImageOverrides{
+		Operator: "quay.io/dev/bpfman-operator:test",
+		Daemon:   "quay.io/dev/bpfman:test",
+		Agent:    "quay.io/dev/bpfman-agent:test",
+	}
+	result, err := RebuildBundle(ctx, RebuildOptions{
+		Bundle:      "oci:" + layoutDir + ":bundle",
+		Destination: server.Host() + "/dev/bpfman-operator-bundle:test",
+		Images:      overrides,
+	})
+	if err != nil {
+		t.Fatalf("RebuildBundle: %v", err)
+	}
+	if want := server.Host() + "/dev/bpfman-operator-bundle@" + result.Digest.String(); result.Image != want {
+		t.Errorf("image = %q, want %q", result.Image, want)
+	}
+	// Operator container, containerImage annotation, both config
+	// entries, and the operator and agent relatedImages.
+	if len(result.Changes) != 6 {
+		t.Errorf("got %d changes, want 6: %+v", len(result.Changes), result.Changes)
+	}
+
+	reg, err := registry.NewImageRegistry(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reg.Destroy()
+	ref := image.SimpleReference(result.Image)
+	if err := reg.Pull(ctx, ref); err != nil {
+		t.Fatalf("pulling rebuilt bundle: %v", err)
+	}
+	dir := t.TempDir()
+	if err := reg.Unpack(ctx, ref, dir); err != nil {
+		t.Fatal(err)
+	}
+	gotLabels, err := reg.Labels(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range labels {
+		if gotLabels[k] != v {
+			t.Errorf("label %s = %q, want %q", k, gotLabels[k], v)
+		}
+	}
+
+	csvData := readFile(t, filepath.Join(dir, "manifests", "bpfman-operator.clusterserviceversion.yaml"))
+	if strings.Contains(csvData, testOperatorImage) || strings.Contains(csvData, testAgentImage) {
+		t.Errorf("CSV still references an overridden image:\n%s", csvData)
+	}
+	if !strings.Contains(csvData, "quay.io/brancz/kube-rbac-proxy:v0.18.0") {
+		t.Errorf("CSV lost the sidecar image:\n%s", csvData)
+	}
+
+	var config struct {
+		Data map[string]string `json:"data"`
+	}
+	if err := yaml.Unmarshal([]byte(readFile(t, filepath.Join(dir, "manifests", "bpfman-config_v1_configmap.yaml"))), &config); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{
+		DaemonImageKey:     overrides.Daemon,
+		AgentImageKey:      overrides.Agent,
+		"bpfman.log.level": "info",
+	} {
+		if config.Data[k] != want {
+			t.Errorf("config %s = %q, want %q", k, config.Data[k], want)
+		}
+	}
+
+	if got := readFile(t, filepath.Join(dir, "metadata", "annotations.yaml")); !strings.Contains(got, "bpfman-operator") {
+		t.Errorf("annotations.yaml = %q", got)
+	}
+}
+
+// TestRebuildBundleFromRegistry pulls the bundle through transient
+// registry failures and keeps its platform.
+func TestRebuildBundleFromRegistry(t *testing.T) {
+	server := registrytest.NewServer(t)
+	server.Push(t, "tenant/bpfman-operator-bundle", "v0.6.0", registrytest.Image{
+		Files: map[string]string{
+			"manifests/bpfman-config_v1_configmap.yaml": testConfigMap,
+		},
+		Labels:   map[string]string{packageLabel: DefaultPackage},
+		Platform: imgspecv1.Platform{OS: "linux", Architecture: "arm64"},
+	})
+	server.InjectFailures("/manifests/", http.StatusBadGateway, 2)
+
+	opts := registry.DefaultOptions()
+	opts.TLSVerify = false
+	opts.Retry = registry.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	ctx := registry.WithOptions(context.Background(), opts)
+
+	result, err := RebuildBundle(ctx, RebuildOptions{
+		Bundle:      server.Host() + "/tenant/bpfman-operator-bundle:v0.6.0",
+		Destination: server.Host() + "/dev/bpfman-operator-bundle:test",
+		Images:      ImageOverrides{Daemon: "quay.io/dev/bpfman:test"},
+	})
+	if err != nil {
+		t.Fatalf("RebuildBundle: %v", err)
+	}
+
+	reg, err := registry.NewImageRegistry(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reg.Destroy()
+	ref := image.SimpleReference(result.Image)
+	if err := reg.Pull(ctx, ref); err != nil {
+		t.Fatalf("pulling rebuilt bundle: %v", err)
+	}
+	config, err := reg.Config(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.OS != "linux" || config.Architecture != "arm64" {
+		t.Errorf("platform = %s/%s, want linux/arm64", config.OS, config.Architecture)
+	}
+}
+
+// TestOverrideImagesKeepsFormatting rewrites only the image values,
+// keeping comments, key order and quoting.
+func TestOverrideImagesKeepsFormatting(t *testing.T) {
+	config := `# Configuration for the bpfman daemon.
+kind: ConfigMap
+apiVersion: v1
+metadata:
+  name: bpfman-config
+data:
+  bpfman.log.level: info # verbose enough
+  bpfman.image: "` + testDaemonImage + `"
+  bpfman.agent.image: '` + testAgentImage + `'
+`
+	files := map[string][]byte{"manifests/bpfman-config_v1_configmap.yaml": []byte(config)}
+	if _, err := overrideImages(files, ImageOverrides{Daemon: "quay.io/dev/bpfman:test", Agent: "quay.io/dev/bpfman-agent:test"}); err != nil {
+		t.Fatalf("overrideImages: %v", err)
+	}
+
+	want := strings.NewReplacer(testDaemonImage, "quay.io/dev/bpfman:test", testAgentImage, "quay.io/dev/bpfman-agent:test").Replace(config)
+	if got := string(files["manifests/bpfman-config_v1_configmap.yaml"]); got != want {
+		t.Errorf("rewritten ConfigMap =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRebuildBundleMissingConfigMap(t *testing.T) {
+	files := map[string][]byte{
+		"manifests/bpfman-operator.clusterserviceversion.yaml": []byte(testCSV),
+	}
+	_, err := overrideImages(files, ImageOverrides{Daemon: "quay.io/dev/bpfman:test"})
+	if err == nil || !strings.Contains(err.Error(), ConfigMapName) {
+		t.Errorf("error = %v, want a missing %s error", err, ConfigMapName)
+	}
+}
+
+// TestOverrideImagesCompanionOperator finds the operator container
+// of another package's CSV from its containerImage annotation.
+func TestOverrideImagesCompanionOperator(t *testing.T) {
+	csv := strings.NewReplacer(
+		"bpfman-operator.v0.6.0", "companion-operator.v1.0.0",
+		"- name: bpfman-operator\n          spec", "- name: companion-controller-manager\n          spec",
+		"- name: bpfman-operator\n                    image", "- name: manager\n                    image",
+	).Replace(testCSV)
+	files := map[string][]byte{"manifests/companion-operator.clusterserviceversion.yaml": []byte(csv)}
+	changes, err := overrideImages(files, ImageOverrides{Operator: "quay.io/dev/companion-operator:test"})
+	if err != nil {
+		t.Fatalf("overrideImages: %v", err)
+	}
+	if want := "spec.install.spec.deployments[companion-controller-manager].containers[manager].image"; len(changes) == 0 || changes[0].Path != want {
+		t.Errorf("changes = %+v, want the first at %s", changes, want)
+	}
+}
+
+// TestRebuildBundleRejectsDaemonOverrideForOtherPackage refuses to
+// look for bpfman-config in another package's bundle.
+func TestRebuildBundleRejectsDaemonOverrideForOtherPackage(t *testing.T) {
+	layoutDir := t.TempDir()
+	registrytest.WriteOCILayout(t, layoutDir, "bundle", registrytest.Image{
+		Files:  map[string]string{"manifests/companion-operator.clusterserviceversion.yaml": testCSV},
+		Labels: map[string]string{packageLabel: "companion-operator"},
+	})
+
+	_, err := RebuildBundle(context.Background(), RebuildOptions{
+		Bundle:      "oci:" + layoutDir + ":bundle",
+		Destination: "oci:" + t.TempDir() + ":rebuilt",
+		Images:      ImageOverrides{Daemon: "quay.io/dev/bpfman:test"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "apply only to "+DefaultPackage) {
+		t.Errorf("error = %v, want daemon override rejected", err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
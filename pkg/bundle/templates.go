@@ -0,0 +1,334 @@
+package bundle
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Names of the artefact templates, both embedded and as looked up in
+// a templates directory.
+const (
+	MakefileTemplate = "Makefile.tmpl"
+	WorkflowTemplate = "WORKFLOW.txt.tmpl"
+)
+
+// TemplateNames lists the artefact templates that can be overridden.
+var TemplateNames = []string{MakefileTemplate, WorkflowTemplate}
+
+//go:embed templates/Makefile.tmpl templates/WORKFLOW.txt.tmpl
+var embeddedTemplates embed.FS
+
+// MakefileData is the data Makefile.tmpl is executed with.
+type MakefileData struct {
+	BundleImage           string       // Newest bundle image, or "from-yaml"
+	Package               string       // Package installed by subscribe
+	Namespace             string       // Namespace the package is installed in (default: the package name)
+	RemoveBpfman          bool         // Whether undeploy removes bpfman's cluster resources, for DefaultPackage
+	LocalTag              string       // Default image repository name
+	BinaryPath            string       // Path of the bpfman-catalog binary
+	ImageUUID             string       // Random repository name for ttl.sh examples
+	RandomTTL             string       // Random ttl.sh tag
+	Username              string       // User for quay.io examples
+	OperatorImageOverride string       // Operator image patched in after install, if any
+	OperatorDeployment    string       // CSV deployment patched with OperatorImageOverride
+	OperatorContainer     string       // Container of OperatorDeployment running the operator
+	Upgrade               *UpgradePlan // Upgrade under test, if any
+	Targets               []OCPTarget  // OpenShift versions, when building for several
+	BaseImage             string       // opm base image of the default Dockerfile
+}
+
+// WorkflowData is the data WORKFLOW.txt.tmpl is executed with.
+type WorkflowData struct {
+	BundleCount     int            // Number of bundles when several were given, otherwise 0
+	CatalogRendered bool           // Whether catalog.yaml was rendered
+	ImageUUID       string         // Random repository name for ttl.sh examples
+	RandomTTL       string         // Random ttl.sh tag
+	OutputDir       string         // Directory the artefacts were written to
+	Username        string         // User for quay.io examples
+	Upgrade         *UpgradePlan   // Upgrade under test, if any
+	Targets         []OCPTarget    // OpenShift versions, when building for several
+	Rebuilt         *RebuildResult // Bundle rebuilt with image overrides, if any
+}
+
+// Templates holds the parsed artefact templates.
+type Templates struct {
+	makefile *template.Template
+	workflow *template.Template
+}
+
+// defaultTemplates parses the embedded templates on first use.
+var defaultTemplates = sync.OnceValues(func() (*Templates, error) {
+	return LoadTemplates("")
+})
+
+// DefaultTemplates returns the embedded artefact templates.
+func DefaultTemplates() (*Templates, error) {
+	return defaultTemplates()
+}
+
+// LoadTemplates loads the artefact templates from dir, using the
+// embedded template for any that dir does not contain. An empty dir
+// loads only the embedded templates. Each template is parsed and
+// executed against sample data, so that unknown fields and syntax
+// errors are reported with the file they are in before anything is
+// generated.
+func LoadTemplates(dir string) (*Templates, error) {
+	if dir != "" {
+		if err := checkTemplateDir(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	parsed := make(map[string]*template.Template, len(TemplateNames))
+	paths := make(map[string]string, len(TemplateNames))
+	for _, name := range TemplateNames {
+		source, path, err := readTemplate(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := template.New(name).Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("parsing template %s: %w", path, err)
+		}
+		parsed[name] = tmpl
+		paths[name] = path
+	}
+
+	t := &Templates{makefile: parsed[MakefileTemplate], workflow: parsed[WorkflowTemplate]}
+	if err := t.validate(paths); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// checkTemplateDir rejects a templates directory that does not exist
+// or holds template files with unknown names, which would otherwise
+// be silently ignored.
+func checkTemplateDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("reading templates directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".tmpl") {
+			continue
+		}
+		known := false
+		for _, n := range TemplateNames {
+			known = known || n == name
+		}
+		if !known {
+			return fmt.Errorf("unknown template %s in %s (expected %s)", name, dir, strings.Join(TemplateNames, " or "))
+		}
+	}
+	return nil
+}
+
+// readTemplate returns the source of template name from dir, or the
+// embedded one, and the path it was read from.
+func readTemplate(dir, name string) (string, string, error) {
+	if dir != "" {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return string(data), path, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", "", fmt.Errorf("reading template: %w", err)
+		}
+	}
+	path := "templates/" + name
+	data, err := embeddedTemplates.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("reading embedded template %s: %w", name, err)
+	}
+	return string(data), "embedded " + path, nil
+}
+
+// validate executes each template with sample data that takes every
+// optional branch of the embedded templates. paths names the file
+// each template was read from.
+func (t *Templates) validate(paths map[string]string) error {
+	targets, err := ResolveOCPTargets([]string{"4.18", DefaultOCPVersion}, "")
+	if err != nil {
+		return err
+	}
+	makefile, err := NewMakefileData("quay.io/tenant/bundle@sha256:0123456789abcdef", DefaultPackage, "bpfman-catalog", "uuid", "1h",
+		"quay.io/tenant/operator:dev", &UpgradePlan{
+			Package:     DefaultPackage,
+			Channel:     "stable",
+			StartingCSV: "bpfman-operator.v0.5.9",
+			TargetCSV:   "bpfman-operator.v0.6.0",
+		}, targets)
+	if err != nil {
+		return err
+	}
+	if err := execute(t.makefile, io.Discard, makefile); err != nil {
+		return fmt.Errorf("checking %s: %w", paths[MakefileTemplate], err)
+	}
+	workflow := NewWorkflowData(2, true, "artefacts", "uuid", "1h")
+	workflow.Upgrade = makefile.Upgrade
+	workflow.Targets = targets
+	workflow.Rebuilt = &RebuildResult{
+		Bundle: "quay.io/tenant/bundle:v0.6.0",
+		Image:  "quay.io/dev/bundle@sha256:0123456789abcdef",
+		Changes: []ImageChange{{
+			File: "manifests/bpfman-config_v1_configmap.yaml",
+			Path: "data." + DaemonImageKey,
+			From: "quay.io/bpfman/bpfman:v0.6.0",
+			To:   "quay.io/dev/bpfman:test",
+		}},
+	}
+	if err := execute(t.workflow, io.Discard, workflow); err != nil {
+		return fmt.Errorf("checking %s: %w", paths[WorkflowTemplate], err)
+	}
+	return nil
+}
+
+// DumpTemplates writes the embedded templates into dir as a starting
+// point for a templates directory, and returns the files written.
+// Existing files are only replaced if overwrite is set.
+func DumpTemplates(dir string, overwrite bool) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating templates directory: %w", err)
+	}
+
+	names := append([]string(nil), TemplateNames...)
+	sort.Strings(names)
+	var written []string
+	for _, name := range names {
+		data, err := embeddedTemplates.ReadFile("templates/" + name)
+		if err != nil {
+			return written, fmt.Errorf("reading embedded template %s: %w", name, err)
+		}
+		path := filepath.Join(dir, name)
+		if !overwrite {
+			if _, err := os.Stat(path); err == nil {
+				return written, fmt.Errorf("%s already exists", path)
+			}
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return written, fmt.Errorf("writing %s: %w", path, err)
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// NewMakefileData returns the Makefile template data for a catalog
+// of packageName built from bundleImage. When upgrade is not nil, the embedded
+// template adds targets that install the released version and
+// approve the upgrade to the candidate. With several OpenShift
+// targets, each gets its own build, push and deploy targets; with
+// none, the default OpenShift version is used. The quay.io examples
+// use $(USER) until the caller sets Username.
+func NewMakefileData(bundleImage, packageName, binaryPath, imageUUID, randomTTL, operatorImageOverride string, upgrade *UpgradePlan, targets []OCPTarget) (MakefileData, error) {
+	localTag := "bpfman-catalog"
+	if digestSuffix := extractDigestSuffix(bundleImage); digestSuffix != "" {
+		localTag = fmt.Sprintf("bpfman-catalog-sha-%s", digestSuffix)
+	}
+
+	if packageName == "" {
+		packageName = DefaultPackage
+	}
+	data := MakefileData{
+		BundleImage:           bundleImage,
+		Package:               packageName,
+		Namespace:             packageName,
+		RemoveBpfman:          packageName == DefaultPackage,
+		LocalTag:              localTag,
+		BinaryPath:            binaryPath,
+		ImageUUID:             imageUUID,
+		RandomTTL:             randomTTL,
+		Username:              makeUser,
+		OperatorImageOverride: operatorImageOverride,
+		Upgrade:               upgrade,
+	}
+	if len(targets) == 0 {
+		var err error
+		if targets, err = ResolveOCPTargets(nil, ""); err != nil {
+			return MakefileData{}, err
+		}
+	}
+	data.BaseImage = targets[0].BaseImage
+	// Per-version targets are only needed beside the default
+	// Dockerfile when building for several versions.
+	if len(targets) > 1 {
+		data.Targets = targets
+	}
+	return data, nil
+}
+
+// NewWorkflowData returns the WORKFLOW.txt template data. The
+// upgrade plan, OpenShift targets and rebuilt bundle are set by the
+// caller when there are any, as is Username for the quay.io examples
+// (default: $(USER)).
+func NewWorkflowData(bundleCount int, catalogRendered bool, outputDir, imageUUID, randomTTL string) WorkflowData {
+	return WorkflowData{
+		BundleCount:     bundleCount,
+		CatalogRendered: catalogRendered,
+		ImageUUID:       imageUUID,
+		RandomTTL:       randomTTL,
+		OutputDir:       outputDir,
+		Username:        makeUser,
+	}
+}
+
+// Makefile executes the Makefile template.
+func (t *Templates) Makefile(data MakefileData) (string, error) {
+	var buf bytes.Buffer
+	if err := execute(t.makefile, &buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Workflow executes the WORKFLOW.txt template.
+func (t *Templates) Workflow(data WorkflowData) (string, error) {
+	var buf bytes.Buffer
+	if err := execute(t.workflow, &buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func execute(tmpl *template.Template, w io.Writer, data any) error {
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("executing template: %w", err)
+	}
+	return nil
+}
+
+// GenerateMakefile generates a Makefile from the embedded template;
+// see NewMakefileData.
+func GenerateMakefile(bundleImage, packageName, binaryPath, imageUUID, randomTTL, operatorImageOverride string, upgrade *UpgradePlan, targets []OCPTarget) (string, error) {
+	data, err := NewMakefileData(bundleImage, packageName, binaryPath, imageUUID, randomTTL, operatorImageOverride, upgrade, targets)
+	if err != nil {
+		return "", err
+	}
+	templates, err := DefaultTemplates()
+	if err != nil {
+		return "", err
+	}
+	return templates.Makefile(data)
+}
+
+// GenerateWorkflow generates a WORKFLOW.txt file with deployment
+// instructions from the embedded template.
+func GenerateWorkflow(bundleCount int, catalogRendered bool, outputDir, imageUUID, randomTTL string) (string, error) {
+	templates, err := DefaultTemplates()
+	if err != nil {
+		return "", err
+	}
+	return templates.Workflow(NewWorkflowData(bundleCount, catalogRendered, outputDir, imageUUID, randomTTL))
+}
@@ -0,0 +1,185 @@
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// generateMakefile generates a Makefile from the embedded template,
+// failing the test on error.
+func generateMakefile(t *testing.T, bundleImage, binaryPath, imageUUID, randomTTL, operatorImageOverride string, upgrade *UpgradePlan, targets []OCPTarget) string {
+	t.Helper()
+	makefile, err := GenerateMakefile(bundleImage, DefaultPackage, binaryPath, imageUUID, randomTTL, operatorImageOverride, upgrade, targets)
+	if err != nil {
+		t.Fatalf("GenerateMakefile: %v", err)
+	}
+	return makefile
+}
+
+// TestLoadTemplatesOverride uses a user Makefile template and falls
+// back to the embedded WORKFLOW.txt template.
+func TestLoadTemplatesOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, MakefileTemplate), []byte("deploy:\n\toc apply -f {{.BundleImage}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+	data, err := NewMakefileData("quay.io/tenant/bundle:v0.6.0", DefaultPackage, "bpfman-catalog", "uuid", "1h", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	makefile, err := templates.Makefile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if makefile != "deploy:\n\toc apply -f quay.io/tenant/bundle:v0.6.0\n" {
+		t.Errorf("Makefile = %q", makefile)
+	}
+
+	workflow, err := templates.Workflow(NewWorkflowData(0, true, "artefacts", "uuid", "1h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(workflow, "make -C artefacts all") {
+		t.Errorf("workflow is not from the embedded template:\n%s", workflow)
+	}
+}
+
+// TestWorkflowSections lists the upgrade test, OpenShift versions and
+// rebuilt bundle only when there are any.
+func TestWorkflowSections(t *testing.T) {
+	templates, err := DefaultTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets, err := ResolveOCPTargets([]string{"4.16", "4.20"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := NewWorkflowData(0, true, "artefacts", "uuid", "1h")
+	plain, err := templates.Workflow(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data.Upgrade = &UpgradePlan{Package: DefaultPackage, Channel: "stable", StartingCSV: "bpfman-operator.v0.5.9", TargetCSV: "bpfman-operator.v0.6.0"}
+	data.Targets = targets
+	data.Rebuilt = &RebuildResult{
+		Bundle:  "quay.io/tenant/bundle:v0.6.0",
+		Image:   "quay.io/dev/bundle@sha256:abc",
+		Changes: []ImageChange{{File: "manifests/config.yaml", Path: "data.bpfman.image", From: "quay.io/bpfman/bpfman:v0.6.0", To: "quay.io/dev/bpfman:test"}},
+	}
+	full, err := templates.Workflow(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"bpfman-operator.v0.5.9 -> bpfman-operator.v0.6.0",
+		"make -C artefacts upgrade-test",
+		"  4.16   Dockerfile.v4.16 (",
+		"make -C artefacts build-catalog-images push-catalog-images",
+		"Rebuilt bundle quay.io/dev/bundle@sha256:abc",
+		"    -> quay.io/dev/bpfman:test",
+	} {
+		if !strings.Contains(full, want) {
+			t.Errorf("workflow does not contain %q:\n%s", want, full)
+		}
+		if strings.Contains(plain, want) {
+			t.Errorf("workflow without them contains %q", want)
+		}
+	}
+}
+
+func TestLoadTemplatesErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, file, content, want string
+	}{
+		{"syntax", MakefileTemplate, "{{if .Upgrade}}", MakefileTemplate + ":1: unexpected EOF"},
+		{"unknown field", WorkflowTemplate, "{{.Bundles}}", "can't evaluate field Bundles"},
+		{"unknown name", "Makefile.tpml.tmpl", "", "unknown template Makefile.tpml.tmpl"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, tc.file), []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadTemplates(dir)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error = %v, want one containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+// TestDumpTemplates writes templates that load unchanged, and does
+// not overwrite them unless asked to.
+func TestDumpTemplates(t *testing.T) {
+	dir := t.TempDir()
+	written, err := DumpTemplates(dir, false)
+	if err != nil {
+		t.Fatalf("DumpTemplates: %v", err)
+	}
+	if len(written) != len(TemplateNames) {
+		t.Errorf("wrote %v", written)
+	}
+	if _, err := LoadTemplates(dir); err != nil {
+		t.Errorf("loading dumped templates: %v", err)
+	}
+	if _, err := DumpTemplates(dir, false); err == nil {
+		t.Error("expected an error dumping over existing templates")
+	}
+	if _, err := DumpTemplates(dir, true); err != nil {
+		t.Errorf("DumpTemplates with overwrite: %v", err)
+	}
+}
+
+// TestGenerateMakefilePackage checks that a companion operator's
+// Makefile installs into its own namespace, patches the deployment
+// and container its CSV names, and leaves out the bpfman cleanup.
+func TestGenerateMakefilePackage(t *testing.T) {
+	data, err := NewMakefileData("quay.io/tenant/companion-bundle:v1.0.0", "companion-operator", "bpfman-catalog", "uuid", "1h",
+		"quay.io/tenant/companion-operator:dev", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.OperatorDeployment, data.OperatorContainer = "companion-controller-manager", "manager"
+	templates, err := DefaultTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	makefile, err := templates.Makefile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"PACKAGE ?= companion-operator",
+		"NAMESPACE ?= companion-operator",
+		"--package $(PACKAGE) --namespace $(NAMESPACE)",
+		"OPERATOR_DEPLOYMENT ?= companion-controller-manager",
+		"OPERATOR_CONTAINER ?= manager",
+		"kubectl set image deployment/$(OPERATOR_DEPLOYMENT) -n $(NAMESPACE) $(OPERATOR_CONTAINER)=quay.io/tenant/companion-operator:dev",
+	} {
+		if !strings.Contains(makefile, want) {
+			t.Errorf("Makefile does not contain %q", want)
+		}
+	}
+	if strings.Contains(makefile, "bpfman.io") || strings.Contains(makefile, " -n bpfman ") {
+		t.Errorf("companion Makefile refers to bpfman resources:\n%s", makefile)
+	}
+
+	makefile, err = GenerateMakefile("quay.io/tenant/bpfman-operator-bundle:v0.6.0", DefaultPackage, "bpfman-catalog", "uuid", "1h", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(makefile, "kubectl delete crd bpfapplications.bpfman.io") {
+		t.Errorf("%s Makefile does not remove the bpfman CRDs", DefaultPackage)
+	}
+}
@@ -0,0 +1,228 @@
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"sigs.k8s.io/yaml"
+)
+
+// UpgradePlan describes an upgrade test: install StartingCSV, the
+// released head of Channel, then upgrade to TargetCSV, the newest
+// candidate.
+type UpgradePlan struct {
+	Package     string
+	Channel     string
+	StartingCSV string
+	TargetCSV   string
+}
+
+// LoadFBCTemplate reads a basic catalog template. Entries are kept as
+// generic maps so that fields this tool does not model, such as
+// package icons, are written back unchanged.
+func LoadFBCTemplate(path string) (*FBCTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading template: %w", err)
+	}
+
+	var stored struct {
+		Schema  string           `json:"schema"`
+		Entries []map[string]any `json:"entries"`
+	}
+	if err := yaml.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("parsing template %s: %w", path, err)
+	}
+	if stored.Schema != "olm.template.basic" {
+		return nil, fmt.Errorf("template %s has schema %q, want olm.template.basic", path, stored.Schema)
+	}
+
+	template := &FBCTemplate{Schema: stored.Schema}
+	for _, entry := range stored.Entries {
+		template.Entries = append(template.Entries, entry)
+	}
+	return template, nil
+}
+
+// AppendUpgradeCandidates returns a copy of base in which the
+// candidate bundles follow the current head of each channel in opts
+// (the package's default channel if none): the first candidate
+// replaces the head and each later one replaces the one before it.
+// The candidates must be in version order, oldest first, as returned
+// by extractBundleInfos.
+// The package's default channel is only changed when opts names one.
+// The plan tests the upgrade in the first channel.
+func AppendUpgradeCandidates(base *FBCTemplate, candidates []*BundleInfo, opts ChannelOptions) (*FBCTemplate, *UpgradePlan, error) {
+	if len(candidates) == 0 {
+		return nil, nil, fmt.Errorf("at least one candidate bundle is required")
+	}
+	if err := checkChain(candidates); err != nil {
+		return nil, nil, err
+	}
+	ordered := candidates
+	packageName := ordered[0].Package
+
+	var (
+		packageIndex   = -1
+		defaultChannel string
+		channelIndex   = make(map[string]int)
+		bundleNames    = make(map[string]bool)
+	)
+	for i, entry := range base.Entries {
+		m, ok := entry.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch m["schema"] {
+		case "olm.package":
+			if m["name"] == packageName {
+				packageIndex = i
+				defaultChannel, _ = m["defaultChannel"].(string)
+			}
+		case "olm.channel":
+			if name, ok := m["name"].(string); ok && m["package"] == packageName {
+				channelIndex[name] = i
+			}
+		case "olm.bundle":
+			if name, ok := m["name"].(string); ok {
+				bundleNames[name] = true
+			}
+		}
+	}
+	if packageIndex < 0 {
+		return nil, nil, fmt.Errorf("template has no package %s", packageName)
+	}
+
+	newDefault := opts.DefaultChannel
+	opts.DefaultChannel = ""
+	opts, err := opts.normalise(defaultChannel)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(opts.Channels) == 0 {
+		return nil, nil, fmt.Errorf("template package %s has no default channel", packageName)
+	}
+	for _, channel := range append([]string{newDefault}, opts.Channels...) {
+		if _, ok := channelIndex[channel]; channel != "" && !ok {
+			return nil, nil, fmt.Errorf("template has no channel %s in package %s", channel, packageName)
+		}
+	}
+
+	for _, b := range ordered {
+		if bundleNames[b.Name] {
+			return nil, nil, fmt.Errorf("candidate %s (%s) is already in the template", b.Name, b.Image)
+		}
+	}
+
+	template := &FBCTemplate{Schema: base.Schema}
+	template.Entries = append(template.Entries, base.Entries...)
+	if newDefault != "" {
+		template.Entries[packageIndex] = withField(base.Entries[packageIndex].(map[string]any), "defaultChannel", newDefault)
+	}
+
+	var plan *UpgradePlan
+	for _, channel := range opts.Channels {
+		i := channelIndex[channel]
+		channelEntry := base.Entries[i].(map[string]any)
+		items, _ := channelEntry["entries"].([]any)
+		ch, err := templateChannel(channelEntry)
+		if err != nil {
+			return nil, nil, fmt.Errorf("channel %s: %w", channel, err)
+		}
+		head, err := ChannelHead(ch)
+		if err != nil {
+			return nil, nil, fmt.Errorf("channel %s: %w", channel, err)
+		}
+
+		newItems := append([]any(nil), items...)
+		previous := head
+		for j, b := range ordered {
+			item := map[string]any{"name": b.Name, "replaces": previous}
+			if j == len(ordered)-1 {
+				if len(opts.Skips) > 0 {
+					item["skips"] = opts.Skips
+				}
+				if opts.SkipRange != "" {
+					item["skipRange"] = opts.SkipRange
+				}
+			}
+			newItems = append(newItems, item)
+			previous = b.Name
+		}
+		template.Entries[i] = withField(channelEntry, "entries", newItems)
+
+		if plan == nil {
+			plan = &UpgradePlan{
+				Package:     packageName,
+				Channel:     channel,
+				StartingCSV: head,
+				TargetCSV:   previous,
+			}
+		}
+	}
+
+	for _, b := range ordered {
+		template.Entries = append(template.Entries, BundleEntry{
+			Schema: "olm.bundle",
+			Image:  b.Image,
+			Name:   b.Name,
+		})
+	}
+
+	return template, plan, nil
+}
+
+// withField returns a copy of m with key set to value.
+func withField(m map[string]any, key string, value any) map[string]any {
+	copied := make(map[string]any, len(m)+1)
+	for k, v := range m {
+		copied[k] = v
+	}
+	copied[key] = value
+	return copied
+}
+
+// ChannelHead returns the one entry of ch that no other entry
+// replaces or skips.
+func ChannelHead(ch declcfg.Channel) (string, error) {
+	superseded := make(map[string]bool)
+	for _, entry := range ch.Entries {
+		if entry.Replaces != "" {
+			superseded[entry.Replaces] = true
+		}
+		for _, skip := range entry.Skips {
+			superseded[skip] = true
+		}
+	}
+
+	var heads []string
+	for _, entry := range ch.Entries {
+		if !superseded[entry.Name] {
+			heads = append(heads, entry.Name)
+		}
+	}
+	switch len(heads) {
+	case 0:
+		return "", fmt.Errorf("no channel head")
+	case 1:
+		return heads[0], nil
+	default:
+		return "", fmt.Errorf("several channel heads: %v", heads)
+	}
+}
+
+// templateChannel converts a channel entry of a basic template, as
+// LoadFBCTemplate keeps it, to a declcfg.Channel.
+func templateChannel(entry map[string]any) (declcfg.Channel, error) {
+	var ch declcfg.Channel
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return ch, err
+	}
+	if err := json.Unmarshal(data, &ch); err != nil {
+		return ch, fmt.Errorf("parsing channel: %w", err)
+	}
+	return ch, nil
+}
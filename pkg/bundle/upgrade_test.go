@@ -0,0 +1,142 @@
+package bundle
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"sigs.k8s.io/yaml"
+)
+
+const releasedTemplate = "../../templates/released.yaml"
+
+// TestAppendUpgradeCandidates chains candidates after the released
+// head of the default channel and keeps the released entries intact.
+func TestAppendUpgradeCandidates(t *testing.T) {
+	base, err := LoadFBCTemplate(releasedTemplate)
+	if err != nil {
+		t.Fatalf("LoadFBCTemplate: %v", err)
+	}
+
+	template, plan, err := AppendUpgradeCandidates(base, []*BundleInfo{
+		testBundle("0.6.0-dev.1"),
+		testBundle("0.6.0-dev.2"),
+	}, ChannelOptions{})
+	if err != nil {
+		t.Fatalf("AppendUpgradeCandidates: %v", err)
+	}
+
+	want := UpgradePlan{
+		Package:     "bpfman-operator",
+		Channel:     "stable",
+		StartingCSV: "bpfman-operator.v0.5.10",
+		TargetCSV:   "bpfman-operator.v0.6.0-dev.2",
+	}
+	if *plan != want {
+		t.Errorf("plan = %+v, want %+v", *plan, want)
+	}
+	if got := len(template.Entries) - len(base.Entries); got != 2 {
+		t.Errorf("got %d new entries, want 2", got)
+	}
+
+	var channel map[string]any
+	for _, entry := range template.Entries {
+		if m, ok := entry.(map[string]any); ok && m["schema"] == "olm.channel" {
+			channel = m
+		}
+	}
+	items := channel["entries"].([]any)
+	wantTail := []map[string]any{
+		{"name": "bpfman-operator.v0.6.0-dev.1", "replaces": "bpfman-operator.v0.5.10"},
+		{"name": "bpfman-operator.v0.6.0-dev.2", "replaces": "bpfman-operator.v0.6.0-dev.1"},
+	}
+	tail := items[len(items)-len(wantTail):]
+	for i, want := range wantTail {
+		got := tail[i].(map[string]any)
+		if got["name"] != want["name"] || got["replaces"] != want["replaces"] {
+			t.Errorf("channel entry %d = %v, want %v", i, got, want)
+		}
+	}
+
+	// Fields the template does not model, such as the icon, survive.
+	data, err := yaml.Marshal(template)
+	if err != nil {
+		t.Fatalf("marshalling template: %v", err)
+	}
+	if !strings.Contains(string(data), "base64data:") {
+		t.Error("package icon was dropped")
+	}
+
+	// The base template is not modified.
+	_, again, err := AppendUpgradeCandidates(base, []*BundleInfo{testBundle("0.6.0")}, ChannelOptions{Channels: []string{"stable"}})
+	if err != nil {
+		t.Fatalf("appending to the base template again: %v", err)
+	}
+	if again.StartingCSV != "bpfman-operator.v0.5.10" {
+		t.Errorf("base template head = %s after an append, want bpfman-operator.v0.5.10", again.StartingCSV)
+	}
+}
+
+func TestAppendUpgradeCandidatesRejects(t *testing.T) {
+	base, err := LoadFBCTemplate(releasedTemplate)
+	if err != nil {
+		t.Fatalf("LoadFBCTemplate: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		candidates []*BundleInfo
+		opts       ChannelOptions
+		wantErr    string
+	}{
+		{"released bundle", []*BundleInfo{testBundle("0.5.9")}, ChannelOptions{}, "already in the template"},
+		{"unknown channel", []*BundleInfo{testBundle("0.6.0")}, ChannelOptions{Channels: []string{"fast"}}, "no channel fast"},
+		{"unknown default channel", []*BundleInfo{testBundle("0.6.0")}, ChannelOptions{DefaultChannel: "fast"}, "no channel fast"},
+		{"no candidates", nil, ChannelOptions{}, "at least one candidate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := AppendUpgradeCandidates(base, tt.candidates, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChannelHead(t *testing.T) {
+	ch := declcfg.Channel{Entries: []declcfg.ChannelEntry{
+		{Name: "a"},
+		{Name: "b", Replaces: "a"},
+		{Name: "c", Replaces: "b", Skips: []string{"x"}},
+		{Name: "x"},
+	}}
+	head, err := ChannelHead(ch)
+	if err != nil || head != "c" {
+		t.Errorf("ChannelHead = %q, %v; want c", head, err)
+	}
+
+	ch.Entries = append(ch.Entries, declcfg.ChannelEntry{Name: "d"})
+	if _, err := ChannelHead(ch); err == nil {
+		t.Error("expected an error for a channel with two heads")
+	}
+}
+
+func TestGenerateMakefileUpgradeTargets(t *testing.T) {
+	plan := &UpgradePlan{
+		Package:     "bpfman-operator",
+		Channel:     "stable",
+		StartingCSV: "bpfman-operator.v0.5.10",
+		TargetCSV:   "bpfman-operator.v0.6.0",
+	}
+	makefile := generateMakefile(t, "quay.io/tenant/bundle:v0.6.0", "bpfman-catalog", "uuid", "1h", "", plan, nil)
+	for _, s := range []string{"upgrade-test:", "subscribe-released:", "approve-upgrade:", "bpfman-operator.v0.5.10", `grep -F '"$(1)"'`} {
+		if !strings.Contains(makefile, s) {
+			t.Errorf("Makefile does not contain %q", s)
+		}
+	}
+
+	if makefile := generateMakefile(t, "quay.io/tenant/bundle:v0.6.0", "bpfman-catalog", "uuid", "1h", "", nil, nil); strings.Contains(makefile, "upgrade-test:") {
+		t.Error("Makefile without an upgrade plan has upgrade targets")
+	}
+}
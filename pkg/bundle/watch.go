@@ -0,0 +1,232 @@
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/openshift/bpfman-catalog/pkg/cachedir"
+)
+
+// watchStateVersion is bumped when the watch state format changes;
+// state written by other versions is discarded.
+const watchStateVersion = 1
+
+// Event reports a bundle build not seen by earlier polls.
+type Event struct {
+	Repository string          `json:"repository"`
+	Image      string          `json:"image"` // Pinned by digest where the repository allows
+	Observed   time.Time       `json:"observed"`
+	Bundle     *BundleMetadata `json:"bundle"`
+}
+
+// ID identifies the build an event reports, so that receivers can
+// discard duplicates.
+func (e Event) ID() string {
+	return e.Repository + ":" + e.Bundle.Tag + "@" + e.Bundle.Digest.String()
+}
+
+// Sink receives the events of a watch.
+type Sink interface {
+	Emit(ctx context.Context, event Event) error
+}
+
+// JSONLines writes each event as a line of JSON.
+type JSONLines struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+var _ Sink = &JSONLines{}
+
+// NewJSONLines returns a sink writing to w.
+func NewJSONLines(w io.Writer) *JSONLines {
+	return &JSONLines{w: w}
+}
+
+// Emit writes the event.
+func (j *JSONLines) Emit(_ context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshalling event: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.w.Write(append(data, '\n'))
+	return err
+}
+
+// WatchOptions configures a Watcher.
+type WatchOptions struct {
+	Repositories []BundleRef // Repositories to poll
+	List         ListOptions // Which bundles of each repository to consider
+	StatePath    string      // File recording reported bundles ("" keeps state in memory)
+	EmitExisting bool        // Report bundles already present when a repository is first polled
+	Sinks        []Sink      // Receivers of each event, in order
+	Now          func() time.Time
+}
+
+// watchState records, per repository, the digest each reported tag
+// pointed at.
+type watchState struct {
+	Version      int                                 `json:"version"`
+	Repositories map[string]map[string]digest.Digest `json:"repositories"`
+}
+
+// Watcher polls bundle repositories and reports each new bundle build
+// once. A build is new when its tag has not been reported before or
+// now points at a different manifest. Reported builds are recorded in
+// a state file so that a restarted watch does not report them again.
+type Watcher struct {
+	opts  WatchOptions
+	state watchState
+}
+
+// NewWatcher returns a watcher, loading any state recorded by an
+// earlier watch.
+func NewWatcher(opts WatchOptions) (*Watcher, error) {
+	if len(opts.Repositories) == 0 {
+		return nil, errors.New("no repositories to watch")
+	}
+	if err := opts.List.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	w := &Watcher{
+		opts:  opts,
+		state: watchState{Version: watchStateVersion, Repositories: make(map[string]map[string]digest.Digest)},
+	}
+	if opts.StatePath == "" {
+		return w, nil
+	}
+
+	data, err := os.ReadFile(opts.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading watch state: %w", err)
+	}
+	var stored watchState
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("parsing watch state %s: %w", opts.StatePath, err)
+	}
+	if stored.Version == watchStateVersion && stored.Repositories != nil {
+		w.state = stored
+	}
+	return w, nil
+}
+
+// Poll lists each repository once and emits an event for every new
+// bundle build, oldest first. The first poll of a repository with no
+// recorded state only records its current builds, unless EmitExisting
+// is set. A build is recorded once every sink has accepted its event;
+// if a sink fails, Poll stops and the build is reported again by the
+// next poll. Repositories that could not be listed, and tags whose
+// metadata could not be read, are returned as failures (named by full
+// reference) and picked up by a later poll.
+func (w *Watcher) Poll(ctx context.Context) ([]Event, []TagFailure, error) {
+	var (
+		emitted  []Event
+		failures []TagFailure
+	)
+	for _, repo := range w.opts.Repositories {
+		bundles, tagFailures, err := ListBundles(ctx, repo, w.opts.List)
+		for _, f := range tagFailures {
+			failures = append(failures, TagFailure{Tag: repo.TaggedRef(f.Tag), Error: f.Error})
+		}
+		// A repository with no builds yet is polled as empty, so
+		// that its first build is reported rather than recorded as
+		// already present.
+		if errors.Is(err, ErrNoBuildTags) {
+			bundles, err = nil, nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return emitted, failures, ctx.Err()
+			}
+			failures = append(failures, TagFailure{Tag: repo.String(), Error: err.Error()})
+			continue
+		}
+
+		events, err := w.pollRepository(ctx, repo, bundles)
+		emitted = append(emitted, events...)
+		if err != nil {
+			return emitted, failures, err
+		}
+	}
+	return emitted, failures, nil
+}
+
+// pollRepository emits events for the listed bundles of repo that
+// have not been reported.
+func (w *Watcher) pollRepository(ctx context.Context, repo BundleRef, bundles []*BundleMetadata) ([]Event, error) {
+	key := repo.String()
+	seen, known := w.state.Repositories[key]
+	if !known {
+		seen = make(map[string]digest.Digest)
+		w.state.Repositories[key] = seen
+	}
+
+	var fresh []*BundleMetadata
+	for _, b := range bundles {
+		if seen[b.Tag] != b.Digest {
+			fresh = append(fresh, b)
+		}
+	}
+	sort.SliceStable(fresh, func(i, j int) bool { return fresh[i].BuildDate < fresh[j].BuildDate })
+
+	if !known && !w.opts.EmitExisting {
+		for _, b := range fresh {
+			seen[b.Tag] = b.Digest
+		}
+		return nil, w.save()
+	}
+
+	var emitted []Event
+	for _, b := range fresh {
+		event := Event{
+			Repository: key,
+			Image:      repo.DigestRef(b),
+			Observed:   w.opts.Now().UTC(),
+			Bundle:     b,
+		}
+		for _, sink := range w.opts.Sinks {
+			if err := sink.Emit(ctx, event); err != nil {
+				if saveErr := w.save(); saveErr != nil {
+					return emitted, errors.Join(err, saveErr)
+				}
+				return emitted, fmt.Errorf("emitting %s: %w", event.Image, err)
+			}
+		}
+		seen[b.Tag] = b.Digest
+		emitted = append(emitted, event)
+	}
+	return emitted, w.save()
+}
+
+// save writes the state, replacing the previous file atomically.
+func (w *Watcher) save() error {
+	if w.opts.StatePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(w.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling watch state: %w", err)
+	}
+	if err := cachedir.WriteFile(w.opts.StatePath, data); err != nil {
+		return fmt.Errorf("saving watch state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
+	"github.com/openshift/bpfman-catalog/pkg/registry/registrytest"
+)
+
+// eventReceiver records the CloudEvents posted to it, failing the
+// first failures requests.
+type eventReceiver struct {
+	mu       sync.Mutex
+	failures int
+	events   []map[string]any
+}
+
+func (r *eventReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if ct := req.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/cloudevents+json") {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	var event map[string]any
+	if err := json.NewDecoder(req.Body).Decode(&event); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.events = append(r.events, event)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (r *eventReceiver) ids() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ids []string
+	for _, e := range r.events {
+		ids = append(ids, e["id"].(string))
+	}
+	return ids
+}
+
+// TestWatcher records existing builds on the first poll, reports each
+// later build once to both sinks, and does not report it again after a
+// restart. A build whose event is rejected is reported by the next
+// poll.
+func TestWatcher(t *testing.T) {
+	srv := registrytest.NewServer(t)
+	receiver := &eventReceiver{}
+	endpoint := httptest.NewServer(receiver)
+	t.Cleanup(endpoint.Close)
+
+	opts := registry.DefaultOptions()
+	opts.TLSVerify = false
+	opts.Retry.MaxAttempts = 1
+	ctx := registry.WithOptions(context.Background(), opts)
+
+	repo := "tenant/bpfman-operator-bundle-ystream"
+	bundleRef, err := ParseBundleRef(srv.Host() + "/" + repo)
+	if err != nil {
+		t.Fatalf("ParseBundleRef: %v", err)
+	}
+	push := func(commit, buildDate string) string {
+		tag := strings.Repeat(commit, gitCommitTagLength)
+		srv.Push(t, repo, tag, registrytest.Image{
+			Labels: map[string]string{"build-date": buildDate, "version": "0.6.0"},
+		})
+		return tag
+	}
+
+	statePath := filepath.Join(t.TempDir(), "watch-state.json")
+	var lines bytes.Buffer
+	newWatcher := func() *Watcher {
+		w, err := NewWatcher(WatchOptions{
+			Repositories: []BundleRef{bundleRef},
+			StatePath:    statePath,
+			Sinks:        []Sink{NewCloudEvents(endpoint.URL, DefaultEventSource), NewJSONLines(&lines)},
+		})
+		if err != nil {
+			t.Fatalf("NewWatcher: %v", err)
+		}
+		return w
+	}
+	poll := func(w *Watcher) []Event {
+		t.Helper()
+		events, failures, err := w.Poll(ctx)
+		if err != nil {
+			t.Fatalf("Poll: %v", err)
+		}
+		if len(failures) != 0 {
+			t.Fatalf("unexpected failures: %v", failures)
+		}
+		return events
+	}
+
+	push("a", "2025-01-01T00:00:00Z")
+	w := newWatcher()
+	if events := poll(w); len(events) != 0 {
+		t.Fatalf("first poll reported %d existing builds, want 0", len(events))
+	}
+
+	second := push("b", "2025-02-01T00:00:00Z")
+	third := push("c", "2025-03-01T00:00:00Z")
+	events := poll(w)
+	if len(events) != 2 || events[0].Bundle.Tag != second || events[1].Bundle.Tag != third {
+		t.Fatalf("second poll reported %v, want %s then %s", events, second, third)
+	}
+	if got := receiver.ids(); len(got) != 2 || got[0] != events[0].ID() {
+		t.Errorf("receiver got %v, want the IDs of %v", got, events)
+	}
+	if got := strings.Count(lines.String(), "\n"); got != 2 {
+		t.Errorf("wrote %d JSON lines, want 2", got)
+	}
+	if events := poll(w); len(events) != 0 {
+		t.Errorf("repeated poll reported %d builds, want 0", len(events))
+	}
+
+	// A rejected event is neither written nor recorded.
+	fourth := push("d", "2025-04-01T00:00:00Z")
+	receiver.mu.Lock()
+	receiver.failures = 1
+	receiver.mu.Unlock()
+	if _, _, err := w.Poll(ctx); err == nil {
+		t.Fatal("expected error when the receiver rejects an event")
+	}
+	if got := strings.Count(lines.String(), "\n"); got != 2 {
+		t.Errorf("wrote %d JSON lines after a rejected event, want 2", got)
+	}
+
+	// A restarted watch reports only the build not yet delivered.
+	events = poll(newWatcher())
+	if len(events) != 1 || events[0].Bundle.Tag != fourth {
+		t.Fatalf("poll after restart reported %v, want %s", events, fourth)
+	}
+	if got := len(receiver.ids()); got != 3 {
+		t.Errorf("receiver got %d events, want 3", got)
+	}
+}
+
+func TestCloudEventsPayload(t *testing.T) {
+	receiver := &eventReceiver{}
+	endpoint := httptest.NewServer(receiver)
+	t.Cleanup(endpoint.Close)
+
+	event := Event{
+		Repository: "quay.io/tenant/bpfman-operator-bundle-ystream",
+		Image:      "quay.io/tenant/bpfman-operator-bundle-ystream@sha256:" + strings.Repeat("0", 64),
+		Observed:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Bundle:     &BundleMetadata{Tag: "latest", Digest: digest.Digest("sha256:" + strings.Repeat("0", 64))},
+	}
+	if err := NewCloudEvents(endpoint.URL, "test").Emit(context.Background(), event); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+
+	got := receiver.events[0]
+	for attr, want := range map[string]string{
+		"specversion": "1.0",
+		"id":          event.ID(),
+		"source":      "test",
+		"type":        EventTypeBundleBuilt,
+		"subject":     event.Image,
+		"time":        "2025-01-01T00:00:00Z",
+	} {
+		if got[attr] != want {
+			t.Errorf("%s = %v, want %q", attr, got[attr], want)
+		}
+	}
+	if data, ok := got["data"].(map[string]any); !ok || data["image"] != event.Image {
+		t.Errorf("data = %v, want the event", got["data"])
+	}
+}
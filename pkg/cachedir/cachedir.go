@@ -0,0 +1,64 @@
+// Package cachedir locates the files bpfman-catalog keeps between
+// runs and writes them safely. Everything cached can be fetched again,
+// so callers log a failure to write the cache rather than fail.
+package cachedir
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Names of the entries under the cache directory.
+const (
+	BundleIndexes    = "bundles"            // Bundle tag metadata indexes
+	BundleReferences = "bundle-references"  // Images referenced by each bundle
+	PullRequests     = "pull-requests.json" // Pull requests resolved for on-pr- tags
+	WatchState       = "watch-state.json"   // Builds already reported by watch-bundles
+)
+
+// Default returns the bpfman-catalog directory under the user cache
+// directory, or "" if the user has none.
+func Default() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "bpfman-catalog")
+}
+
+// Path returns the entry called name under dir, or "" if dir is "",
+// so that an unset cache directory disables caching.
+func Path(dir, name string) string {
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, name)
+}
+
+// WriteFile writes data to path, creating its directory, and replaces
+// any previous file atomically so readers never see a partial write.
+func WriteFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating directory %s: %w", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replacing %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package cachedir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+
+	for _, content := range []string{"first", "second"} {
+		if err := WriteFile(path, []byte(content)); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("content = %q, want %q", data, content)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want only the written file", len(entries))
+	}
+}
+
+func TestPath(t *testing.T) {
+	if got := Path("", PullRequests); got != "" {
+		t.Errorf("Path with no cache directory = %q, want \"\"", got)
+	}
+	if got, want := Path("/cache", PullRequests), filepath.Join("/cache", "pull-requests.json"); got != want {
+		t.Errorf("Path = %q, want %q", got, want)
+	}
+}
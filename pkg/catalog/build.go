@@ -0,0 +1,252 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing/fstest"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/openshift/bpfman-catalog/pkg/bundle"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// Catalog image conventions followed by the generated Dockerfile.
+const (
+	ConfigsDir           = "/configs"
+	ConfigsLabel         = "operators.operatorframework.io.index.configs.v1"
+	releaseOperatorLabel = "io.openshift.release.operator"
+)
+
+// BuildOptions configures BuildImage.
+type BuildOptions struct {
+	Catalog     string            // Rendered catalog file or directory
+	Package     string            // Package the image is labelled for and a catalog file is stored under; required if the catalog has several (default: its only package)
+	BaseImage   string            // opm image the catalog is layered on
+	Destination string            // Registry reference, or oci:<dir>:<tag> and other local transports
+	OS          string            // Platform of the base image to use (default: linux)
+	Arch        string            // (default: amd64)
+	Version     string            // Version label (default: the newest bundle's version in the package)
+	Commit      string            // upstream-vcs-ref label
+	Labels      map[string]string // Extra image labels, overriding the defaults
+	Created     time.Time         // Creation time recorded in the image (default: now)
+
+	// SkipServeCache acknowledges that the image is built without
+	// the serve cache the Dockerfile pre-builds; BuildImage refuses
+	// to build otherwise.
+	SkipServeCache bool
+}
+
+// BuildResult describes a built and pushed catalog image.
+type BuildResult struct {
+	Destination string        `json:"destination"`
+	Digest      digest.Digest `json:"digest"`
+	BaseImage   string        `json:"base_image"`
+}
+
+// BuildImage builds a catalog image without a container engine: the
+// catalog, after validation, is added as a layer under /configs on
+// top of the opm base image, with the Dockerfile's labels and an opm
+// serve entrypoint, and the image is copied to the destination. The
+// Dockerfile also pre-builds the serve cache, which only the base
+// image's own opm can do, so BuildImage needs opts.SkipServeCache:
+// the image serves without --cache-dir and builds its cache on every
+// start.
+func BuildImage(ctx context.Context, opts BuildOptions) (*BuildResult, error) {
+	if !opts.SkipServeCache {
+		return nil, fmt.Errorf("the serve cache can only be pre-built by running the base image's opm, which needs a container engine: " +
+			"build with the generated Dockerfile, or pass --skip-serve-cache to build an image that builds its cache on every start")
+	}
+	if opts.OS == "" {
+		opts.OS = "linux"
+	}
+	if opts.Arch == "" {
+		opts.Arch = "amd64"
+	}
+	if opts.Created.IsZero() {
+		opts.Created = time.Now()
+	}
+	opts.Created = opts.Created.UTC()
+
+	files, single, err := catalogFiles(opts.Catalog)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := validateCatalog(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+	// The image is labelled for one package, whose directory a
+	// single catalog file is stored in.
+	if opts.Package, err = SelectPackage(CatalogPackages(cfg), opts.Package); err != nil {
+		return nil, err
+	}
+	if single {
+		files = inPackageDir(files, opts.Package)
+	}
+	if opts.Version == "" {
+		opts.Version = newestVersion(cfg, opts.Package)
+	}
+
+	// Check the destination before the base image is pulled.
+	if _, err := registry.ParseReference(opts.Destination); err != nil {
+		return nil, err
+	}
+
+	workDir, err := os.MkdirTemp("", "bpfman-catalog-build-")
+	if err != nil {
+		return nil, fmt.Errorf("creating build directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	l, err := registry.PullLayout(ctx, opts.BaseImage, workDir, opts.OS, opts.Arch)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := appendConfigsLayer(l, files, opts); err != nil {
+		return nil, err
+	}
+
+	d, err := l.Push(ctx, opts.Destination)
+	if err != nil {
+		return nil, err
+	}
+	return &BuildResult{Destination: opts.Destination, Digest: d, BaseImage: opts.BaseImage}, nil
+}
+
+// catalogFiles returns the files of a rendered catalog keyed by their
+// path under /configs, and whether the catalog is a single file,
+// which is keyed by its base name; see inPackageDir.
+func catalogFiles(source string) (map[string][]byte, bool, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, false, fmt.Errorf("reading catalog: %w", err)
+	}
+
+	files := make(map[string][]byte)
+	if !info.IsDir() {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, false, fmt.Errorf("reading catalog: %w", err)
+		}
+		name := "index.yaml"
+		if strings.HasSuffix(source, ".json") {
+			name = "index.json"
+		}
+		files[name] = data
+		return files, true, nil
+	}
+
+	err = filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("reading catalog directory %s: %w", source, err)
+	}
+	if len(files) == 0 {
+		return nil, false, fmt.Errorf("catalog directory %s is empty", source)
+	}
+	return files, false, nil
+}
+
+// inPackageDir moves the single file of a catalog into the package
+// directory, as the Dockerfile does.
+func inPackageDir(files map[string][]byte, packageName string) map[string][]byte {
+	moved := make(map[string][]byte, len(files))
+	for name, data := range files {
+		moved[packageName+"/"+name] = data
+	}
+	return moved
+}
+
+// newestVersion returns the highest bundle version of packageName, or
+// "" if it has none.
+func newestVersion(cfg *declcfg.DeclarativeConfig, packageName string) string {
+	var newest *semver.Version
+	for _, b := range cfg.Bundles {
+		if b.Package != packageName {
+			continue
+		}
+		if v, err := bundle.BundleVersion(b); err == nil && (newest == nil || v.GT(*newest)) {
+			newest = &v
+		}
+	}
+	if newest == nil {
+		return ""
+	}
+	return newest.String()
+}
+
+// validateCatalog checks the catalog as opm validate does, by loading
+// it and converting it to the package model.
+func validateCatalog(ctx context.Context, files map[string][]byte) (*declcfg.DeclarativeConfig, error) {
+	fsys := make(fstest.MapFS, len(files))
+	for name, data := range files {
+		fsys[name] = &fstest.MapFile{Data: data, Mode: 0644}
+	}
+	cfg, err := declcfg.LoadFS(ctx, fsys)
+	if err != nil {
+		return nil, fmt.Errorf("loading catalog: %w", err)
+	}
+	if _, err := declcfg.ConvertToModel(*cfg); err != nil {
+		return nil, fmt.Errorf("invalid catalog: %w", err)
+	}
+	return cfg, nil
+}
+
+// appendConfigsLayer adds the catalog files under /configs as a new
+// top layer of the image in l, sets the Dockerfile's labels and points
+// the image config at opm serve.
+func appendConfigsLayer(l *registry.Layout, files map[string][]byte, opts BuildOptions) error {
+	root := strings.TrimPrefix(ConfigsDir, "/")
+	layerFiles := make(map[string][]byte, len(files))
+	for name, data := range files {
+		layerFiles[root+"/"+name] = data
+	}
+	layer, diffID, err := registry.TarGzLayer(layerFiles, opts.Created)
+	if err != nil {
+		return err
+	}
+
+	return l.AppendLayer(layer, diffID, func(config *imgspecv1.Image) {
+		if config.Config.Labels == nil {
+			config.Config.Labels = make(map[string]string)
+		}
+		config.Config.Labels[ConfigsLabel] = ConfigsDir
+		config.Config.Labels[releaseOperatorLabel] = "true"
+		for k, v := range bundle.CatalogImageLabels(opts.Package, opts.Version, opts.Commit) {
+			config.Config.Labels[k] = v
+		}
+		for k, v := range opts.Labels {
+			config.Config.Labels[k] = v
+		}
+		config.Config.Entrypoint = []string{"/bin/opm"}
+		config.Config.Cmd = []string{"serve", ConfigsDir}
+		config.Created = &opts.Created
+		config.History = append(config.History, imgspecv1.History{
+			Created:   &opts.Created,
+			CreatedBy: "bpfman-catalog build-catalog-image",
+			Comment:   "catalog configs",
+		})
+	})
+}
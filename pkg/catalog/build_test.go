@@ -0,0 +1,232 @@
+package catalog
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
+	"github.com/openshift/bpfman-catalog/pkg/registry/registrytest"
+)
+
+const testCatalog = `---
+schema: olm.package
+name: bpfman-operator
+defaultChannel: stable
+---
+schema: olm.channel
+package: bpfman-operator
+name: stable
+entries:
+  - name: bpfman-operator.v0.5.10
+---
+schema: olm.bundle
+package: bpfman-operator
+name: bpfman-operator.v0.5.10
+image: quay.io/bpfman/bpfman-operator-bundle@sha256:f015580da52da53c9fa9e5629804b9ac6b3026208d271f8d7ac0bd55f887bad7
+properties:
+  - type: olm.package
+    value:
+      packageName: bpfman-operator
+      version: 0.5.10
+`
+
+func testContext() context.Context {
+	opts := registry.DefaultOptions()
+	opts.TLSVerify = false
+	return registry.WithOptions(context.Background(), opts)
+}
+
+func writeCatalog(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "catalog.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestBuildImage layers a catalog on a base image from one registry
+// repository and pushes the result to another.
+func TestBuildImage(t *testing.T) {
+	server := registrytest.NewServer(t)
+	server.Push(t, "openshift4/opm", "v4.20", registrytest.Image{
+		Files:  map[string]string{"bin/opm": "#!/bin/sh\n"},
+		Labels: map[string]string{"base": "opm"},
+	})
+
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	result, err := BuildImage(testContext(), BuildOptions{
+		Catalog:        writeCatalog(t, testCatalog),
+		BaseImage:      server.Host() + "/openshift4/opm:v4.20",
+		Destination:    server.Host() + "/tenant/catalog:dev",
+		Commit:         "abc123",
+		Labels:         map[string]string{"org.opencontainers.image.version": "0.5.10"},
+		Created:        created,
+		SkipServeCache: true,
+	})
+	if err != nil {
+		t.Fatalf("BuildImage: %v", err)
+	}
+
+	manifestJSON, mediaType, ok := server.Manifest("tenant/catalog", "dev")
+	if !ok {
+		t.Fatal("catalog image was not pushed")
+	}
+	if mediaType != imgspecv1.MediaTypeImageManifest {
+		t.Errorf("manifest media type = %s", mediaType)
+	}
+	if _, _, ok := server.Manifest("tenant/catalog", result.Digest.String()); !ok {
+		t.Errorf("reported digest %s was not pushed", result.Digest)
+	}
+
+	var m imgspecv1.Manifest
+	if err := json.Unmarshal(manifestJSON, &m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Layers) != 2 {
+		t.Fatalf("got %d layers, want the base layer and the configs layer", len(m.Layers))
+	}
+
+	configJSON, ok := server.Blob(m.Config.Digest)
+	if !ok {
+		t.Fatal("config blob was not pushed")
+	}
+	var config imgspecv1.Image
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{
+		ConfigsLabel:                       "/configs",
+		"base":                             "opm",
+		"org.opencontainers.image.version": "0.5.10",
+		"version":                          "0.5.10",
+		"upstream-vcs-ref":                 "abc123",
+		"name":                             "bpfman-operator-catalog",
+	} {
+		if got := config.Config.Labels[k]; got != want {
+			t.Errorf("label %s = %q, want %q", k, got, want)
+		}
+	}
+	if !reflect.DeepEqual(config.Config.Entrypoint, []string{"/bin/opm"}) ||
+		!reflect.DeepEqual(config.Config.Cmd, []string{"serve", "/configs"}) {
+		t.Errorf("entrypoint %v, cmd %v", config.Config.Entrypoint, config.Config.Cmd)
+	}
+	if len(config.RootFS.DiffIDs) != 2 {
+		t.Errorf("got %d diff IDs, want 2", len(config.RootFS.DiffIDs))
+	}
+	if config.Created == nil || !config.Created.Equal(created) {
+		t.Errorf("created = %v, want %v", config.Created, created)
+	}
+
+	layer, ok := server.Blob(m.Layers[1].Digest)
+	if !ok {
+		t.Fatal("configs layer was not pushed")
+	}
+	files := layerFiles(t, layer)
+	if files["configs/bpfman-operator/index.yaml"] != testCatalog {
+		t.Errorf("configs layer files = %v", files)
+	}
+}
+
+func TestBuildImageToOCILayout(t *testing.T) {
+	server := registrytest.NewServer(t)
+	server.Push(t, "openshift4/opm", "v4.20", registrytest.Image{Files: map[string]string{"bin/opm": "opm"}})
+
+	dir := filepath.Join(t.TempDir(), "layout")
+	result, err := BuildImage(testContext(), BuildOptions{
+		Catalog:        writeCatalog(t, testCatalog),
+		BaseImage:      server.Host() + "/openshift4/opm:v4.20",
+		Destination:    "oci:" + dir + ":catalog",
+		SkipServeCache: true,
+	})
+	if err != nil {
+		t.Fatalf("BuildImage: %v", err)
+	}
+
+	cat, err := Load(testContext(), "oci:"+dir+":catalog")
+	if err != nil {
+		t.Fatalf("loading built catalog: %v", err)
+	}
+	if len(cat.Config.Bundles) != 1 {
+		t.Errorf("built catalog has %d bundles, want 1", len(cat.Config.Bundles))
+	}
+	if !strings.HasPrefix(result.Digest.String(), "sha256:") {
+		t.Errorf("digest = %s", result.Digest)
+	}
+}
+
+func TestBuildImageRejectsInvalidCatalog(t *testing.T) {
+	invalid := strings.Replace(testCatalog, "  - name: bpfman-operator.v0.5.10", "  - name: bpfman-operator.v0.5.11", 1)
+	_, err := BuildImage(testContext(), BuildOptions{
+		Catalog:        writeCatalog(t, invalid),
+		BaseImage:      "registry.invalid/opm:v4.20",
+		Destination:    "registry.invalid/catalog:dev",
+		SkipServeCache: true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid catalog") {
+		t.Errorf("error = %v, want an invalid catalog error", err)
+	}
+}
+
+// TestBuildImageRequiresPackage refuses to choose a directory for a
+// catalog file holding several packages.
+func TestBuildImageRequiresPackage(t *testing.T) {
+	companion := strings.ReplaceAll(testCatalog, "bpfman-operator", "companion-operator")
+	_, err := BuildImage(testContext(), BuildOptions{
+		Catalog:        writeCatalog(t, testCatalog+companion),
+		BaseImage:      "registry.invalid/opm:v4.20",
+		Destination:    "registry.invalid/catalog:dev",
+		SkipServeCache: true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "choose one with --package") {
+		t.Errorf("error = %v, want a --package error", err)
+	}
+}
+
+// TestBuildImageRequiresSkipServeCache refuses to build an image
+// without the serve cache unless told to.
+func TestBuildImageRequiresSkipServeCache(t *testing.T) {
+	_, err := BuildImage(testContext(), BuildOptions{
+		Catalog:     writeCatalog(t, testCatalog),
+		BaseImage:   "registry.invalid/opm:v4.20",
+		Destination: "registry.invalid/catalog:dev",
+	})
+	if err == nil || !strings.Contains(err.Error(), "--skip-serve-cache") {
+		t.Errorf("error = %v, want a --skip-serve-cache error", err)
+	}
+}
+
+// layerFiles returns the regular files in a gzip-compressed tar layer.
+func layerFiles(t *testing.T, layer []byte) map[string]string {
+	t.Helper()
+	gr, err := gzip.NewReader(bytes.NewReader(layer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	files := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			data, _ := io.ReadAll(tr)
+			files[hdr.Name] = string(data)
+		}
+	}
+}
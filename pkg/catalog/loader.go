@@ -0,0 +1,127 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/openshift/bpfman-catalog/pkg/bundle"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"sigs.k8s.io/yaml"
+)
+
+// SourceKind identifies where a catalog was loaded from.
+type SourceKind string
+
+const (
+	SourceImage    SourceKind = "image"    // FBC catalog image
+	SourceRendered SourceKind = "rendered" // Rendered catalog file or directory
+	SourceTemplate SourceKind = "template" // Basic catalog template
+)
+
+// basicTemplateSchema is the schema of a basic catalog template.
+const basicTemplateSchema = "olm.template.basic"
+
+// Catalog is a loaded File-Based Catalog together with its origin.
+type Catalog struct {
+	Source string
+	Kind   SourceKind
+	Config *declcfg.DeclarativeConfig
+}
+
+// Load loads a File-Based Catalog from a catalog image, a rendered
+// catalog file or directory, or a basic catalog template. Local paths
+// take precedence; anything that does not exist on disk is treated as
+// an image reference.
+func Load(ctx context.Context, source string) (*Catalog, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("checking %s: %w", source, err)
+		}
+		cfg, err := loadImageConfig(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		return &Catalog{Source: source, Kind: SourceImage, Config: cfg}, nil
+	}
+
+	if info.IsDir() {
+		cfg, err := declcfg.LoadFS(ctx, os.DirFS(source))
+		if err != nil {
+			return nil, fmt.Errorf("loading FBC catalog from %s: %w", source, err)
+		}
+		return &Catalog{Source: source, Kind: SourceRendered, Config: cfg}, nil
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", source, err)
+	}
+
+	if isBasicTemplate(data) {
+		cfg, err := bundle.RenderTemplate(ctx, bytes.NewReader(data), bundle.DefaultMigrateLevel)
+		if err != nil {
+			return nil, fmt.Errorf("rendering template %s: %w", source, err)
+		}
+		return &Catalog{Source: source, Kind: SourceTemplate, Config: cfg}, nil
+	}
+
+	cfg, err := declcfg.LoadFile(os.DirFS(filepath.Dir(source)), filepath.Base(source))
+	if err != nil {
+		return nil, fmt.Errorf("loading FBC catalog from %s: %w", source, err)
+	}
+	return &Catalog{Source: source, Kind: SourceRendered, Config: cfg}, nil
+}
+
+// isBasicTemplate reports whether data is a basic catalog template
+// rather than a rendered catalog.
+func isBasicTemplate(data []byte) bool {
+	var header struct {
+		Schema string `json:"schema"`
+	}
+	if err := yaml.Unmarshal(data, &header); err != nil {
+		return false
+	}
+	return header.Schema == basicTemplateSchema
+}
+
+// BundleEntry describes a bundle in a catalog along with the channels
+// that reference it.
+type BundleEntry struct {
+	Package  string   `json:"package"`
+	Channels []string `json:"channels"`
+	Name     string   `json:"name"`
+	Image    string   `json:"image"`
+}
+
+// Bundles returns every bundle in the catalog ordered by package and
+// then by the order in which bundles appear in the catalog.
+func (c *Catalog) Bundles() []BundleEntry {
+	channelsByBundle := make(map[string][]string)
+	for _, ch := range c.Config.Channels {
+		for _, entry := range ch.Entries {
+			key := ch.Package + "/" + entry.Name
+			channelsByBundle[key] = append(channelsByBundle[key], ch.Name)
+		}
+	}
+
+	var entries []BundleEntry
+	for _, pkg := range c.Config.Packages {
+		for _, b := range c.Config.Bundles {
+			if b.Package != pkg.Name {
+				continue
+			}
+			entries = append(entries, BundleEntry{
+				Package:  b.Package,
+				Channels: channelsByBundle[b.Package+"/"+b.Name],
+				Name:     b.Name,
+				Image:    b.Image,
+			})
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,146 @@
+package catalog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openshift/bpfman-catalog/pkg/registry/registrytest"
+)
+
+// loaderTemplate is a basic template whose single bundle image is
+// filled in by writeTemplate.
+const loaderTemplate = `schema: olm.template.basic
+entries:
+  - schema: olm.package
+    name: bpfman-operator
+    defaultChannel: stable
+  - schema: olm.channel
+    package: bpfman-operator
+    name: stable
+    entries:
+      - name: bpfman-operator.v0.5.9
+  - schema: olm.bundle
+    image: BUNDLE
+`
+
+const loaderCSV = `apiVersion: operators.coreos.com/v1alpha1
+kind: ClusterServiceVersion
+metadata:
+  name: bpfman-operator.v0.5.9
+spec:
+  version: 0.5.9
+`
+
+// writeTemplate writes loaderTemplate referencing a bundle image in a
+// local OCI layout, so rendering it needs no registry.
+func writeTemplate(t *testing.T) string {
+	t.Helper()
+	layoutDir := t.TempDir()
+	annotations := map[string]string{
+		"operators.operatorframework.io.bundle.mediatype.v1": "registry+v1",
+		"operators.operatorframework.io.bundle.manifests.v1": "manifests/",
+		"operators.operatorframework.io.bundle.metadata.v1":  "metadata/",
+		"operators.operatorframework.io.bundle.package.v1":   "bpfman-operator",
+		"operators.operatorframework.io.bundle.channels.v1":  "stable",
+	}
+	var sb strings.Builder
+	sb.WriteString("annotations:\n")
+	for k, v := range annotations {
+		fmt.Fprintf(&sb, "  %s: %s\n", k, v)
+	}
+	registrytest.WriteOCILayout(t, layoutDir, "bundle", registrytest.Image{
+		Files: map[string]string{
+			"manifests/bpfman-operator.clusterserviceversion.yaml": loaderCSV,
+			"metadata/annotations.yaml":                            sb.String(),
+		},
+		Labels: annotations,
+	})
+	return writeCatalog(t, strings.Replace(loaderTemplate, "BUNDLE", "oci:"+layoutDir+":bundle", 1))
+}
+
+func TestIsBasicTemplate(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{name: "template", data: loaderTemplate, want: true},
+		{name: "json template", data: `{"schema": "olm.template.basic", "entries": []}`, want: true},
+		{name: "rendered catalog", data: queryCatalog, want: false},
+		{name: "semver template", data: "schema: olm.semver\n", want: false},
+		{name: "no schema", data: "name: bpfman-operator\n", want: false},
+		{name: "not yaml", data: "\t: [", want: false},
+		{name: "empty", data: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBasicTemplate([]byte(tt.data)); got != tt.want {
+				t.Errorf("isBasicTemplate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "catalog.yaml"), []byte(queryCatalog), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		source      string
+		wantKind    SourceKind
+		wantBundles []string
+	}{
+		{
+			name:        "rendered file",
+			source:      writeCatalog(t, queryCatalog),
+			wantKind:    SourceRendered,
+			wantBundles: []string{"bpfman-operator.v0.5.9", "bpfman-operator.v0.5.10", "bpfman-operator.v0.6.0", "companion-operator.v1.0.0"},
+		},
+		{
+			name:        "rendered directory",
+			source:      dir,
+			wantKind:    SourceRendered,
+			wantBundles: []string{"bpfman-operator.v0.5.9", "bpfman-operator.v0.5.10", "bpfman-operator.v0.6.0", "companion-operator.v1.0.0"},
+		},
+		{
+			name:        "basic template",
+			source:      writeTemplate(t),
+			wantKind:    SourceTemplate,
+			wantBundles: []string{"bpfman-operator.v0.5.9"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cat, err := Load(testContext(), tt.source)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cat.Kind != tt.wantKind {
+				t.Errorf("Kind = %s, want %s", cat.Kind, tt.wantKind)
+			}
+			if cat.Source != tt.source {
+				t.Errorf("Source = %s, want %s", cat.Source, tt.source)
+			}
+			var names []string
+			for _, b := range cat.Bundles() {
+				names = append(names, b.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantBundles) {
+				t.Errorf("bundles = %v, want %v", names, tt.wantBundles)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidCatalog(t *testing.T) {
+	if _, err := Load(testContext(), writeCatalog(t, "schema: olm.package\nname: [\n")); err == nil {
+		t.Error("expected an error for an unparseable catalog")
+	}
+}
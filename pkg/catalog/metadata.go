@@ -7,19 +7,18 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/containers/image/v5/docker"
-	"github.com/containers/image/v5/types"
 	"github.com/opencontainers/go-digest"
+	"github.com/openshift/bpfman-catalog/pkg/imageref"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
 	"github.com/operator-framework/operator-registry/alpha/declcfg"
-	"github.com/operator-framework/operator-registry/pkg/containertools"
 	"github.com/operator-framework/operator-registry/pkg/image"
-	"github.com/operator-framework/operator-registry/pkg/image/execregistry"
 	"github.com/sirupsen/logrus"
 )
 
 // ImageMetadata contains metadata extracted from an image reference.
 type ImageMetadata struct {
 	OriginalRef    string        // Original image reference provided
+	Transport      string        // e.g., oci for local OCI layouts; empty for registries
 	Registry       string        // e.g., quay.io
 	Namespace      string        // e.g., redhat-user-workloads/ocp-bpfman-tenant
 	Repository     string        // e.g., catalog-ystream
@@ -27,13 +26,18 @@ type ImageMetadata struct {
 	Digest         digest.Digest // e.g., sha256:abc123...
 	ShortDigest    string        // First 8 chars of digest
 	CatalogType    string        // e.g., catalog-ystream, catalog-zstream
+	Package        string        // Package the channels are of, e.g., bpfman-operator
 	DefaultChannel string        // Default channel from catalog
 	Channels       []string      // Available channels
+
+	ref imageref.Reference // OriginalRef, parsed
 }
 
 // ExtractMetadata extracts metadata from an image reference. If the
-// reference uses a tag, it will resolve it to a digest.
-func ExtractMetadata(ctx context.Context, imageRef string) (*ImageMetadata, error) {
+// reference uses a tag, it will resolve it to a digest. Channels are
+// read for packageName, or for the catalog's only package if
+// packageName is empty; see SelectPackage.
+func ExtractMetadata(ctx context.Context, imageRef, packageName string) (*ImageMetadata, error) {
 	meta := &ImageMetadata{
 		OriginalRef: imageRef,
 	}
@@ -62,191 +66,157 @@ func ExtractMetadata(ctx context.Context, imageRef string) (*ImageMetadata, erro
 		}
 	}
 
-	if err := extractChannelInfo(ctx, meta.GetDigestRef(), meta); err != nil {
+	if err := extractChannelInfo(ctx, meta.GetDigestRef(), packageName, meta); err != nil {
 		return nil, fmt.Errorf("extracting channel information: %w", err)
 	}
 
 	return meta, nil
 }
 
-// GetDigestRef returns a digest-based image reference.
+// GetDigestRef returns a digest-based image reference, or the
+// original reference if it is local or its digest is unknown.
 func (m *ImageMetadata) GetDigestRef() string {
-	if m.Digest == "" {
+	if m.Digest == "" || m.ref.IsLocal() {
 		return m.OriginalRef
 	}
-
-	var ref strings.Builder
-	ref.WriteString(m.Registry)
-	if m.Namespace != "" {
-		ref.WriteString("/")
-		ref.WriteString(m.Namespace)
-	}
-	ref.WriteString("/")
-	ref.WriteString(m.Repository)
-	ref.WriteString("@")
-	ref.WriteString(string(m.Digest))
-
-	return ref.String()
+	ref := m.ref
+	ref.Digest = m.Digest
+	return ref.DigestRef()
 }
 
-// parseImageReference parses an image reference into its components.
+// parseImageReference parses an image reference into its components;
+// see imageref.Parse. Local references only set Transport, and
+// Repository from the last element of the path.
 func parseImageReference(imageRef string, meta *ImageMetadata) error {
-	// Handle different formats:
-	// quay.io/namespace/repo:tag
-	// quay.io/namespace/repo@sha256:digest
-	// registry.redhat.io/namespace/repo:tag.
-
-	imageRef = strings.TrimPrefix(imageRef, "docker://")
-
-	var baseRef string
-	if idx := strings.Index(imageRef, "@"); idx != -1 {
-		baseRef = imageRef[:idx]
-		digestStr := imageRef[idx+1:]
-		if d, err := digest.Parse(digestStr); err == nil {
-			meta.Digest = d
-		}
-	} else {
-		baseRef = imageRef
-	}
-
-	parts := strings.Split(baseRef, ":")
-	if len(parts) == 2 {
-		meta.Tag = parts[1]
-		baseRef = parts[0]
-	}
-
-	pathParts := strings.Split(baseRef, "/")
-	if len(pathParts) < 2 {
-		return fmt.Errorf("invalid image reference format: %s", imageRef)
-	}
-
-	if strings.Contains(pathParts[0], ".") || strings.Contains(pathParts[0], ":") {
-		meta.Registry = pathParts[0]
-		pathParts = pathParts[1:]
-	} else {
-		meta.Registry = "docker.io"
+	parsed, err := imageref.Parse(imageRef)
+	if err != nil {
+		return err
 	}
-
-	if len(pathParts) > 0 {
-		meta.Repository = pathParts[len(pathParts)-1]
-		if len(pathParts) > 1 {
-			meta.Namespace = strings.Join(pathParts[:len(pathParts)-1], "/")
-		}
+	meta.ref = parsed
+	if parsed.IsLocal() {
+		meta.Transport = parsed.Transport
+		meta.Repository = filepath.Base(strings.SplitN(parsed.Path, ":", 2)[0])
+		return nil
 	}
 
+	meta.Registry = parsed.Registry
+	meta.Namespace = parsed.Namespace()
+	meta.Repository = parsed.Base()
+	meta.Tag = parsed.Tag
+	meta.Digest = parsed.Digest
 	return nil
 }
 
 // fetchDigest resolves the digest for an image reference using
 // containers/image.
 func fetchDigest(ctx context.Context, imageRef string, meta *ImageMetadata) error {
-	sysCtx := &types.SystemContext{}
-
-	ref, err := docker.ParseReference("//" + imageRef)
+	manifestDigest, err := registry.Digest(ctx, imageRef)
 	if err != nil {
-		return fmt.Errorf("parsing image reference: %w", err)
+		return err
 	}
+	meta.Digest = manifestDigest
+	return nil
+}
 
-	src, err := ref.NewImageSource(ctx, sysCtx)
+// extractChannelInfo inspects the FBC catalog image to determine
+// the package and its available channels.
+func extractChannelInfo(ctx context.Context, imageRef, packageName string, meta *ImageMetadata) error {
+	cfg, err := loadImageConfig(ctx, imageRef)
 	if err != nil {
-		return fmt.Errorf("creating image source: %w", err)
+		return err
 	}
-	defer src.Close()
 
-	manifestBlob, _, err := src.GetManifest(ctx, nil)
+	packageName, err = SelectPackage(CatalogPackages(cfg), packageName)
 	if err != nil {
-		return fmt.Errorf("getting manifest: %w", err)
+		return err
+	}
+	var pkg declcfg.Package
+	for _, p := range cfg.Packages {
+		if p.Name == packageName {
+			pkg = p
+			break
+		}
 	}
 
-	manifestDigest := digest.FromBytes(manifestBlob)
-	meta.Digest = manifestDigest
+	var channels []declcfg.Channel
+	for _, ch := range cfg.Channels {
+		if ch.Package == packageName {
+			channels = append(channels, ch)
+		}
+	}
+
+	if len(channels) == 0 {
+		return fmt.Errorf("no channels found for %s package", packageName)
+	}
+
+	meta.Package = packageName
+	meta.Channels = make([]string, len(channels))
+	for i, channel := range channels {
+		meta.Channels[i] = channel.Name
+	}
+
+	meta.DefaultChannel = pkg.DefaultChannel
+	if meta.DefaultChannel == "" && len(meta.Channels) > 0 {
+		meta.DefaultChannel = meta.Channels[0]
+	}
 
 	return nil
 }
 
-// extractChannelInfo inspects the FBC catalog image to determine
-// available channels.
-func extractChannelInfo(ctx context.Context, imageRef string, meta *ImageMetadata) error {
+// loadImageConfig pulls and unpacks an FBC catalog image and loads
+// the declarative config stored under its configs directory.
+func loadImageConfig(ctx context.Context, imageRef string) (*declcfg.DeclarativeConfig, error) {
 	tmpDir, err := os.MkdirTemp("", "catalog-extract-*")
 	if err != nil {
-		return fmt.Errorf("creating temporary directory: %w", err)
+		return nil, fmt.Errorf("creating temporary directory: %w", err)
 	}
 	defer os.RemoveAll(tmpDir)
 
 	logger := logrus.NewEntry(logrus.New())
 	logger.Logger.SetLevel(logrus.ErrorLevel) // Minimise logging noise.
 
-	registry, err := execregistry.NewRegistry(containertools.PodmanTool, logger)
+	reg, err := registry.New(ctx, logger)
 	if err != nil {
-		registry, err = execregistry.NewRegistry(containertools.DockerTool, logger)
-		if err != nil {
-			return fmt.Errorf("creating container registry client: %w", err)
-		}
+		return nil, fmt.Errorf("creating container registry client: %w", err)
 	}
-	defer registry.Destroy()
+	defer reg.Destroy()
 
 	imgRef := image.SimpleReference(imageRef)
 
-	if err := registry.Pull(ctx, imgRef); err != nil {
-		return fmt.Errorf("pulling catalog image: %w", err)
+	if err := reg.Pull(ctx, imgRef); err != nil {
+		return nil, fmt.Errorf("pulling catalog image: %w", err)
 	}
 
-	if err := registry.Unpack(ctx, imgRef, tmpDir); err != nil {
-		return fmt.Errorf("unpacking catalog image: %w", err)
+	if err := reg.Unpack(ctx, imgRef, tmpDir); err != nil {
+		return nil, fmt.Errorf("unpacking catalog image: %w", err)
 	}
 
-	labels, err := registry.Labels(ctx, imgRef)
+	labels, err := reg.Labels(ctx, imgRef)
 	if err != nil {
-		return fmt.Errorf("getting image labels: %w", err)
+		return nil, fmt.Errorf("getting image labels: %w", err)
 	}
 
-	configsDir := "/configs" // Default location.
-	if loc, ok := labels[containertools.ConfigsLocationLabel]; ok {
-		configsDir = loc
-	}
-
-	configsPath := filepath.Join(tmpDir, configsDir)
-	if _, err := os.Stat(configsPath); os.IsNotExist(err) {
-		return fmt.Errorf("configs directory not found at %s", configsPath)
+	configsPath := configsPath(tmpDir, labels)
+	if info, err := os.Stat(configsPath); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("configs directory %s not found in catalog image", strings.TrimPrefix(configsPath, tmpDir))
 	}
 
 	cfg, err := declcfg.LoadFS(ctx, os.DirFS(configsPath))
 	if err != nil {
-		return fmt.Errorf("loading FBC catalog from %s: %w", configsPath, err)
-	}
-
-	var bpfmanPackage *declcfg.Package
-	for _, pkg := range cfg.Packages {
-		if pkg.Name == "bpfman-operator" {
-			bpfmanPackage = &pkg
-			break
-		}
-	}
-
-	if bpfmanPackage == nil {
-		return fmt.Errorf("bpfman-operator package not found in FBC catalog")
-	}
-
-	var channels []declcfg.Channel
-	for _, ch := range cfg.Channels {
-		if ch.Package == "bpfman-operator" {
-			channels = append(channels, ch)
-		}
+		return nil, fmt.Errorf("loading FBC catalog from %s: %w", configsPath, err)
 	}
 
-	if len(channels) == 0 {
-		return fmt.Errorf("no channels found for bpfman-operator package")
-	}
-
-	meta.Channels = make([]string, len(channels))
-	for i, channel := range channels {
-		meta.Channels[i] = channel.Name
-	}
+	return cfg, nil
+}
 
-	meta.DefaultChannel = bpfmanPackage.DefaultChannel
-	if meta.DefaultChannel == "" && len(meta.Channels) > 0 {
-		meta.DefaultChannel = meta.Channels[0]
+// configsPath returns where the catalog configs of an image unpacked
+// at root are, from the configs label, or /configs if the image has
+// none. The label is an absolute path in the image, so it is kept
+// within root.
+func configsPath(root string, labels map[string]string) string {
+	dir := strings.TrimSpace(labels[ConfigsLabel])
+	if dir == "" {
+		dir = ConfigsDir
 	}
-
-	return nil
+	return filepath.Join(root, filepath.Clean("/"+dir))
 }
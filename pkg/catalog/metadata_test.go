@@ -0,0 +1,79 @@
+package catalog
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openshift/bpfman-catalog/pkg/registry/registrytest"
+)
+
+// TestLoadImageConfigsLabel loads a catalog image whose configs label
+// points somewhere other than /configs.
+func TestLoadImageConfigsLabel(t *testing.T) {
+	dir := t.TempDir()
+	registrytest.WriteOCILayout(t, dir, "catalog", registrytest.Image{
+		Files:  map[string]string{"catalog/bpfman-operator/index.yaml": testCatalog},
+		Labels: map[string]string{ConfigsLabel: "/catalog/"},
+	})
+
+	cat, err := Load(testContext(), "oci:"+dir+":catalog")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cat.Config.Bundles) != 1 {
+		t.Errorf("got %d bundles, want 1", len(cat.Config.Bundles))
+	}
+}
+
+func TestConfigsPath(t *testing.T) {
+	root := filepath.FromSlash("/tmp/unpacked")
+	for _, tc := range []struct {
+		label string
+		want  string
+	}{
+		{"", "/tmp/unpacked/configs"},
+		{"/configs", "/tmp/unpacked/configs"},
+		{"/catalog/", "/tmp/unpacked/catalog"},
+		{"configs", "/tmp/unpacked/configs"},
+		{"/../../etc", "/tmp/unpacked/etc"},
+	} {
+		labels := map[string]string{}
+		if tc.label != "" {
+			labels[ConfigsLabel] = tc.label
+		}
+		if got := configsPath(root, labels); got != filepath.FromSlash(tc.want) {
+			t.Errorf("configsPath(%q) = %q, want %q", tc.label, got, tc.want)
+		}
+	}
+}
+
+// TestExtractMetadataPackage reads the channels of the package chosen
+// from a catalog holding two.
+func TestExtractMetadataPackage(t *testing.T) {
+	companion := strings.ReplaceAll(testCatalog, "bpfman-operator", "companion-operator")
+	companion = strings.Replace(companion, "defaultChannel: stable", "defaultChannel: fast", 1)
+	companion = strings.Replace(companion, "name: stable", "name: fast", 1)
+	dir := t.TempDir()
+	registrytest.WriteOCILayout(t, dir, "catalog", registrytest.Image{
+		Files: map[string]string{
+			"configs/bpfman-operator/index.yaml":    testCatalog,
+			"configs/companion-operator/index.yaml": companion,
+		},
+		Labels: map[string]string{ConfigsLabel: ConfigsDir},
+	})
+	ref := "oci:" + dir + ":catalog"
+
+	_, err := ExtractMetadata(testContext(), ref, "")
+	if err == nil || !strings.Contains(err.Error(), "--package") || !strings.Contains(err.Error(), "companion-operator") {
+		t.Errorf("error = %v, want a request to choose a package", err)
+	}
+
+	meta, err := ExtractMetadata(testContext(), ref, "companion-operator")
+	if err != nil {
+		t.Fatalf("ExtractMetadata: %v", err)
+	}
+	if meta.Package != "companion-operator" || meta.DefaultChannel != "fast" || len(meta.Channels) != 1 {
+		t.Errorf("metadata = %+v", meta)
+	}
+}
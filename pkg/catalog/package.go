@@ -0,0 +1,56 @@
+package catalog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/openshift/bpfman-catalog/pkg/bundle"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// DefaultNamespace is the namespace bpfman-operator is installed in.
+const DefaultNamespace = "bpfman"
+
+// PackageNamespace returns the namespace a package is installed in
+// by default: DefaultNamespace for bundle.DefaultPackage, otherwise
+// the package name.
+func PackageNamespace(packageName string) string {
+	if packageName == "" || packageName == bundle.DefaultPackage {
+		return DefaultNamespace
+	}
+	return packageName
+}
+
+// CatalogPackages returns the names of the packages in a catalog,
+// sorted.
+func CatalogPackages(cfg *declcfg.DeclarativeConfig) []string {
+	names := make([]string, 0, len(cfg.Packages))
+	for _, p := range cfg.Packages {
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SelectPackage returns the package to use from those in a catalog:
+// want if it is set, otherwise the only package. A catalog holding
+// several packages needs want to choose one of them.
+func SelectPackage(packages []string, want string) (string, error) {
+	if want != "" {
+		for _, p := range packages {
+			if p == want {
+				return want, nil
+			}
+		}
+		return "", fmt.Errorf("package %s not found in catalog (packages: %s)", want, strings.Join(packages, ", "))
+	}
+	switch len(packages) {
+	case 0:
+		return "", fmt.Errorf("catalog has no packages")
+	case 1:
+		return packages[0], nil
+	default:
+		return "", fmt.Errorf("catalog has %d packages, choose one with --package: %s", len(packages), strings.Join(packages, ", "))
+	}
+}
@@ -0,0 +1,43 @@
+package catalog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openshift/bpfman-catalog/pkg/bundle"
+)
+
+func TestSelectPackage(t *testing.T) {
+	for _, tc := range []struct {
+		packages []string
+		want     string
+		got      string
+		err      string
+	}{
+		{packages: []string{"bpfman-operator"}, got: "bpfman-operator"},
+		{packages: []string{"bpfman-operator", "companion-operator"}, want: "companion-operator", got: "companion-operator"},
+		{packages: []string{"bpfman-operator", "companion-operator"}, err: "choose one with --package: bpfman-operator, companion-operator"},
+		{packages: []string{"bpfman-operator"}, want: "other-operator", err: "package other-operator not found"},
+		{err: "no packages"},
+	} {
+		got, err := SelectPackage(tc.packages, tc.want)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("SelectPackage(%q, %q) error = %v, want %q", tc.packages, tc.want, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || got != tc.got {
+			t.Errorf("SelectPackage(%q, %q) = %q, %v, want %q", tc.packages, tc.want, got, err, tc.got)
+		}
+	}
+}
+
+func TestPackageNamespace(t *testing.T) {
+	if got := PackageNamespace(bundle.DefaultPackage); got != DefaultNamespace {
+		t.Errorf("PackageNamespace(%s) = %q, want %q", bundle.DefaultPackage, got, DefaultNamespace)
+	}
+	if got := PackageNamespace("companion-operator"); got != "companion-operator" {
+		t.Errorf("PackageNamespace(companion-operator) = %q", got)
+	}
+}
@@ -0,0 +1,314 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/openshift/bpfman-catalog/pkg/bundle"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// QueryFilter selects the catalog contents a query reports. Empty
+// fields match everything.
+type QueryFilter struct {
+	Package      string // Package name
+	Channel      string // Channel name
+	Bundle       string // Bundle name, e.g., bpfman-operator.v0.5.10
+	Version      string // Bundle version, exactly or as a glob such as 0.6.*
+	RelatedImage string // Substring of a related image, such as its digest
+	PropertyType string // Property type, e.g., olm.package
+}
+
+// PackageSummary describes a package in a catalog.
+type PackageSummary struct {
+	Name           string   `json:"name"`
+	DefaultChannel string   `json:"defaultChannel"`
+	Channels       []string `json:"channels"`
+	Bundles        int      `json:"bundles"`
+}
+
+// ChannelSummary describes a channel in a catalog. Head is the one
+// entry no other entry replaces or skips, and is empty if the channel
+// has none or several.
+type ChannelSummary struct {
+	Package string `json:"package"`
+	Name    string `json:"name"`
+	Default bool   `json:"default"`
+	Head    string `json:"head"`
+	Entries int    `json:"entries"`
+}
+
+// BundleSummary describes a bundle as an entry of one channel; a
+// bundle in several channels has a summary for each. Bundles in no
+// channel have an empty Channel.
+type BundleSummary struct {
+	Package   string   `json:"package"`
+	Channel   string   `json:"channel"`
+	Name      string   `json:"name"`
+	Version   string   `json:"version"`
+	Replaces  string   `json:"replaces,omitempty"`
+	Skips     []string `json:"skips,omitempty"`
+	SkipRange string   `json:"skipRange,omitempty"`
+	Image     string   `json:"image"`
+}
+
+// PropertySummary is a property declared by a bundle.
+type PropertySummary struct {
+	Package string          `json:"package"`
+	Bundle  string          `json:"bundle"`
+	Type    string          `json:"type"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// validate rejects a filter naming a package or channel the catalog
+// does not have, or holding an invalid version glob, so that a typo
+// is not reported as an empty result.
+func (c *Catalog) validate(f QueryFilter) error {
+	if f.Version != "" {
+		if _, err := path.Match(f.Version, ""); err != nil {
+			return fmt.Errorf("invalid version pattern %q: %w", f.Version, err)
+		}
+	}
+	if f.Package != "" {
+		found := false
+		for _, p := range c.Config.Packages {
+			found = found || p.Name == f.Package
+		}
+		if !found {
+			return fmt.Errorf("package %s not found in catalog (available: %s)", f.Package, strings.Join(CatalogPackages(c.Config), ", "))
+		}
+	}
+	if f.Channel != "" {
+		found := false
+		for _, ch := range c.Config.Channels {
+			found = found || (ch.Name == f.Channel && (f.Package == "" || ch.Package == f.Package))
+		}
+		if !found {
+			return fmt.Errorf("channel %s not found in catalog", f.Channel)
+		}
+	}
+	return nil
+}
+
+// QueryPackages returns the packages matching f, ordered by name.
+func (c *Catalog) QueryPackages(f QueryFilter) ([]PackageSummary, error) {
+	if err := c.validate(f); err != nil {
+		return nil, err
+	}
+
+	var packages []PackageSummary
+	for _, p := range c.Config.Packages {
+		if f.Package != "" && p.Name != f.Package {
+			continue
+		}
+		summary := PackageSummary{Name: p.Name, DefaultChannel: p.DefaultChannel, Channels: []string{}}
+		for _, ch := range c.Config.Channels {
+			if ch.Package == p.Name {
+				summary.Channels = append(summary.Channels, ch.Name)
+			}
+		}
+		sort.Strings(summary.Channels)
+		for _, b := range c.Config.Bundles {
+			if b.Package == p.Name {
+				summary.Bundles++
+			}
+		}
+		packages = append(packages, summary)
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+	return packages, nil
+}
+
+// QueryChannels returns the channels matching f, ordered by package
+// and name.
+func (c *Catalog) QueryChannels(f QueryFilter) ([]ChannelSummary, error) {
+	if err := c.validate(f); err != nil {
+		return nil, err
+	}
+
+	defaults := make(map[string]string)
+	for _, p := range c.Config.Packages {
+		defaults[p.Name] = p.DefaultChannel
+	}
+
+	var channels []ChannelSummary
+	for _, ch := range c.Config.Channels {
+		if !matches(f.Package, ch.Package) || !matches(f.Channel, ch.Name) {
+			continue
+		}
+		channels = append(channels, ChannelSummary{
+			Package: ch.Package,
+			Name:    ch.Name,
+			Default: defaults[ch.Package] == ch.Name,
+			Head:    channelHead(ch),
+			Entries: len(ch.Entries),
+		})
+	}
+	sort.Slice(channels, func(i, j int) bool {
+		if channels[i].Package != channels[j].Package {
+			return channels[i].Package < channels[j].Package
+		}
+		return channels[i].Name < channels[j].Name
+	})
+	return channels, nil
+}
+
+// QueryBundles returns an entry for each channel of each bundle
+// matching f, ordered by package, channel and version.
+func (c *Catalog) QueryBundles(f QueryFilter) ([]BundleSummary, error) {
+	if err := c.validate(f); err != nil {
+		return nil, err
+	}
+
+	bundles := make(map[string]declcfg.Bundle)
+	for _, b := range c.Config.Bundles {
+		bundles[b.Package+"/"+b.Name] = b
+	}
+
+	var summaries []BundleSummary
+	inChannel := make(map[string]bool)
+	add := func(b declcfg.Bundle, channel string, entry declcfg.ChannelEntry) {
+		if !matches(f.Package, b.Package) || !matches(f.Bundle, b.Name) || !matchesRelatedImage(b, f.RelatedImage) {
+			return
+		}
+		version := bundleVersion(b)
+		if f.Version != "" {
+			if ok, _ := path.Match(f.Version, version); !ok {
+				return
+			}
+		}
+		summaries = append(summaries, BundleSummary{
+			Package:   b.Package,
+			Channel:   channel,
+			Name:      b.Name,
+			Version:   version,
+			Replaces:  entry.Replaces,
+			Skips:     entry.Skips,
+			SkipRange: entry.SkipRange,
+			Image:     b.Image,
+		})
+	}
+
+	for _, ch := range c.Config.Channels {
+		for _, entry := range ch.Entries {
+			key := ch.Package + "/" + entry.Name
+			inChannel[key] = true
+			b, ok := bundles[key]
+			if !ok || !matches(f.Channel, ch.Name) {
+				continue
+			}
+			add(b, ch.Name, entry)
+		}
+	}
+	if f.Channel == "" {
+		for _, b := range c.Config.Bundles {
+			if !inChannel[b.Package+"/"+b.Name] {
+				add(b, "", declcfg.ChannelEntry{})
+			}
+		}
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if a.Channel != b.Channel {
+			return a.Channel < b.Channel
+		}
+		return compareVersions(a.Version, b.Version) < 0
+	})
+	return summaries, nil
+}
+
+// QueryProperties returns the properties of the bundles matching f,
+// ordered by package and bundle, with each bundle's properties in the
+// order it declares them.
+func (c *Catalog) QueryProperties(f QueryFilter) ([]PropertySummary, error) {
+	bundles, err := c.QueryBundles(f)
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[string]bool)
+	for _, b := range bundles {
+		selected[b.Package+"/"+b.Name] = true
+	}
+
+	var properties []PropertySummary
+	for _, b := range c.Config.Bundles {
+		if !selected[b.Package+"/"+b.Name] {
+			continue
+		}
+		for _, p := range b.Properties {
+			if !matches(f.PropertyType, p.Type) {
+				continue
+			}
+			properties = append(properties, PropertySummary{Package: b.Package, Bundle: b.Name, Type: p.Type, Value: p.Value})
+		}
+	}
+	sort.SliceStable(properties, func(i, j int) bool {
+		if properties[i].Package != properties[j].Package {
+			return properties[i].Package < properties[j].Package
+		}
+		return properties[i].Bundle < properties[j].Bundle
+	})
+	return properties, nil
+}
+
+// channelHead returns the head of ch, or "" if it does not have
+// exactly one; see bundle.ChannelHead.
+func channelHead(ch declcfg.Channel) string {
+	head, err := bundle.ChannelHead(ch)
+	if err != nil {
+		return ""
+	}
+	return head
+}
+
+// bundleVersion returns the version of b, or "" if it has none.
+func bundleVersion(b declcfg.Bundle) string {
+	version, err := bundle.BundleVersion(b)
+	if err != nil {
+		return ""
+	}
+	return version.String()
+}
+
+// compareVersions orders semantic versions, placing any that do not
+// parse after those that do.
+func compareVersions(a, b string) int {
+	va, errA := semver.Parse(a)
+	vb, errB := semver.Parse(b)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return 1
+	case errB != nil:
+		return -1
+	}
+	return va.Compare(vb)
+}
+
+// matchesRelatedImage reports whether b has a related image, or is
+// itself an image, containing image.
+func matchesRelatedImage(b declcfg.Bundle, image string) bool {
+	if image == "" || strings.Contains(b.Image, image) {
+		return true
+	}
+	for _, ri := range b.RelatedImages {
+		if strings.Contains(ri.Image, image) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether value equals want, or want is empty.
+func matches(want, value string) bool {
+	return want == "" || want == value
+}
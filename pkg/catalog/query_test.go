@@ -0,0 +1,195 @@
+package catalog
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// queryCatalog has a stable channel of three versions, the newest
+// skipping the middle one, a candidate channel holding only the
+// newest, and a second package.
+const queryCatalog = `---
+schema: olm.package
+name: bpfman-operator
+defaultChannel: stable
+---
+schema: olm.channel
+package: bpfman-operator
+name: stable
+entries:
+  - name: bpfman-operator.v0.6.0
+    replaces: bpfman-operator.v0.5.9
+    skips: [bpfman-operator.v0.5.10]
+    skipRange: ">=0.5.0 <0.6.0"
+  - name: bpfman-operator.v0.5.10
+    replaces: bpfman-operator.v0.5.9
+  - name: bpfman-operator.v0.5.9
+---
+schema: olm.channel
+package: bpfman-operator
+name: candidate
+entries:
+  - name: bpfman-operator.v0.6.0
+---
+schema: olm.bundle
+package: bpfman-operator
+name: bpfman-operator.v0.5.9
+image: quay.io/bpfman/bundle@sha256:0509
+properties:
+  - type: olm.package
+    value: {packageName: bpfman-operator, version: 0.5.9}
+---
+schema: olm.bundle
+package: bpfman-operator
+name: bpfman-operator.v0.5.10
+image: quay.io/bpfman/bundle@sha256:0510
+properties:
+  - type: olm.package
+    value: {packageName: bpfman-operator, version: 0.5.10}
+relatedImages:
+  - name: agent
+    image: quay.io/bpfman/agent@sha256:aaaa
+---
+schema: olm.bundle
+package: bpfman-operator
+name: bpfman-operator.v0.6.0
+image: quay.io/bpfman/bundle@sha256:0600
+properties:
+  - type: olm.package
+    value: {packageName: bpfman-operator, version: 0.6.0}
+  - type: olm.gvk
+    value: {group: bpfman.io, kind: BpfApplication, version: v1alpha1}
+relatedImages:
+  - name: agent
+    image: quay.io/bpfman/agent@sha256:bbbb
+---
+schema: olm.package
+name: companion-operator
+defaultChannel: fast
+---
+schema: olm.channel
+package: companion-operator
+name: fast
+entries:
+  - name: companion-operator.v1.0.0
+---
+schema: olm.bundle
+package: companion-operator
+name: companion-operator.v1.0.0
+image: quay.io/companion/bundle@sha256:1000
+properties:
+  - type: olm.package
+    value: {packageName: companion-operator, version: 1.0.0}
+`
+
+func loadQueryCatalog(t *testing.T) *Catalog {
+	t.Helper()
+	cat, err := Load(testContext(), writeCatalog(t, queryCatalog))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return cat
+}
+
+func TestQueryPackages(t *testing.T) {
+	got, err := loadQueryCatalog(t).QueryPackages(QueryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PackageSummary{
+		{Name: "bpfman-operator", DefaultChannel: "stable", Channels: []string{"candidate", "stable"}, Bundles: 3},
+		{Name: "companion-operator", DefaultChannel: "fast", Channels: []string{"fast"}, Bundles: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("packages = %+v, want %+v", got, want)
+	}
+}
+
+func TestQueryChannels(t *testing.T) {
+	got, err := loadQueryCatalog(t).QueryChannels(QueryFilter{Package: "bpfman-operator"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ChannelSummary{
+		{Package: "bpfman-operator", Name: "candidate", Head: "bpfman-operator.v0.6.0", Entries: 1},
+		{Package: "bpfman-operator", Name: "stable", Default: true, Head: "bpfman-operator.v0.6.0", Entries: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("channels = %+v, want %+v", got, want)
+	}
+}
+
+func TestQueryBundles(t *testing.T) {
+	cat := loadQueryCatalog(t)
+	names := func(bundles []BundleSummary) []string {
+		var s []string
+		for _, b := range bundles {
+			s = append(s, b.Channel+"/"+b.Version)
+		}
+		return s
+	}
+
+	for _, tt := range []struct {
+		name   string
+		filter QueryFilter
+		want   []string
+	}{
+		{"stable in version order", QueryFilter{Channel: "stable"}, []string{"stable/0.5.9", "stable/0.5.10", "stable/0.6.0"}},
+		{"every channel", QueryFilter{Bundle: "bpfman-operator.v0.6.0"}, []string{"candidate/0.6.0", "stable/0.6.0"}},
+		{"version glob", QueryFilter{Package: "bpfman-operator", Version: "0.5.*"}, []string{"stable/0.5.9", "stable/0.5.10"}},
+		{"related image digest", QueryFilter{RelatedImage: "sha256:aaaa"}, []string{"stable/0.5.10"}},
+		{"bundle image", QueryFilter{RelatedImage: "companion/bundle"}, []string{"fast/1.0.0"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cat.QueryBundles(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(names(got), tt.want) {
+				t.Errorf("bundles = %v, want %v", names(got), tt.want)
+			}
+		})
+	}
+
+	got, err := cat.QueryBundles(QueryFilter{Channel: "stable", Bundle: "bpfman-operator.v0.6.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := BundleSummary{
+		Package:   "bpfman-operator",
+		Channel:   "stable",
+		Name:      "bpfman-operator.v0.6.0",
+		Version:   "0.6.0",
+		Replaces:  "bpfman-operator.v0.5.9",
+		Skips:     []string{"bpfman-operator.v0.5.10"},
+		SkipRange: ">=0.5.0 <0.6.0",
+		Image:     "quay.io/bpfman/bundle@sha256:0600",
+	}
+	if len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Errorf("bundle = %+v, want %+v", got, want)
+	}
+}
+
+func TestQueryProperties(t *testing.T) {
+	got, err := loadQueryCatalog(t).QueryProperties(QueryFilter{PropertyType: "olm.gvk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Bundle != "bpfman-operator.v0.6.0" || !strings.Contains(string(got[0].Value), "BpfApplication") {
+		t.Errorf("properties = %+v", got)
+	}
+}
+
+func TestQueryUnknownFilter(t *testing.T) {
+	cat := loadQueryCatalog(t)
+	for _, f := range []QueryFilter{
+		{Package: "missing-operator"},
+		{Package: "companion-operator", Channel: "stable"},
+		{Version: "["},
+	} {
+		if _, err := cat.QueryBundles(f); err == nil {
+			t.Errorf("filter %+v: expected error", f)
+		}
+	}
+}
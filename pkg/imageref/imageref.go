@@ -0,0 +1,127 @@
+// Package imageref parses the image references accepted on the
+// command line: registry references, normalised as docker and podman
+// do, and references to images on the local filesystem.
+package imageref
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/opencontainers/go-digest"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
+)
+
+// Reference is a parsed image reference. Registry references set
+// Registry and Repository, and Tag and Digest if given; local
+// references (oci:, oci-archive:, docker-archive: or dir:) set
+// Transport and Path instead.
+type Reference struct {
+	Transport  string        // Local transport, empty for registry references
+	Path       string        // Transport-specific remainder of a local reference
+	Registry   string        // e.g., quay.io, localhost:5000 or docker.io
+	Repository string        // e.g., redhat-user-workloads/ocp-bpfman-tenant/catalog-ystream
+	Tag        string        // e.g., latest; empty if not given
+	Digest     digest.Digest // e.g., sha256:abc123...; empty if not given
+}
+
+// Parse parses an image reference. Registry references may carry a
+// "docker://" prefix, a registry with a port, nested namespaces, and
+// both a tag and a digest. References without a registry are
+// normalised to docker.io, and single-element docker.io repositories
+// to library/<name>, as docker and podman pull them.
+func Parse(ref string) (Reference, error) {
+	if ref == "" {
+		return Reference{}, fmt.Errorf("empty image reference")
+	}
+
+	transport, rest := registry.SplitTransport(ref)
+	if transport != registry.TransportDocker {
+		if rest == "" {
+			return Reference{}, fmt.Errorf("invalid %s reference, missing path: %s", transport, ref)
+		}
+		return Reference{Transport: transport, Path: rest}, nil
+	}
+
+	named, err := reference.ParseNormalizedNamed(rest)
+	if err != nil {
+		return Reference{}, fmt.Errorf("parsing image reference %s: %w", ref, err)
+	}
+	r := Reference{
+		Registry:   reference.Domain(named),
+		Repository: reference.Path(named),
+	}
+	if tagged, ok := named.(reference.Tagged); ok {
+		r.Tag = tagged.Tag()
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		r.Digest = digested.Digest()
+	}
+	return r, nil
+}
+
+// IsLocal reports whether r refers to an image on the local
+// filesystem.
+func (r Reference) IsLocal() bool {
+	return r.Transport != ""
+}
+
+// Name returns the registry and repository, e.g.,
+// quay.io/bpfman/bpfman.
+func (r Reference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// Namespace returns the repository without its last element, e.g.,
+// redhat-user-workloads/ocp-bpfman-tenant, or "" if it has one
+// element.
+func (r Reference) Namespace() string {
+	namespace, _, ok := cutLast(r.Repository)
+	if !ok {
+		return ""
+	}
+	return namespace
+}
+
+// Base returns the last element of the repository, e.g.,
+// catalog-ystream.
+func (r Reference) Base() string {
+	_, base, _ := cutLast(r.Repository)
+	return base
+}
+
+// String returns the reference in its canonical form: the name
+// followed by the tag and digest that were given, or the transport
+// and path of a local reference.
+func (r Reference) String() string {
+	if r.IsLocal() {
+		return r.Transport + ":" + r.Path
+	}
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest.String()
+	}
+	return s
+}
+
+// DigestRef returns the name pinned by digest, dropping any tag, or
+// String() if r has no digest or is local.
+func (r Reference) DigestRef() string {
+	if r.IsLocal() || r.Digest == "" {
+		return r.String()
+	}
+	return r.Name() + "@" + r.Digest.String()
+}
+
+// cutLast splits path around its last "/", returning the whole of
+// path as the second value if it has none.
+func cutLast(path string) (string, string, bool) {
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return "", path, false
+	}
+	return path[:i], path[i+1:], true
+}
@@ -0,0 +1,150 @@
+package imageref
+
+import (
+	"testing"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		ref       string
+		want      Reference
+		namespace string
+		base      string
+		str       string
+		wantErr   bool
+	}{
+		{
+			name:      "tenant workspace",
+			ref:       "quay.io/redhat-user-workloads/ocp-bpfman-tenant/catalog-ystream:latest",
+			want:      Reference{Registry: "quay.io", Repository: "redhat-user-workloads/ocp-bpfman-tenant/catalog-ystream", Tag: "latest"},
+			namespace: "redhat-user-workloads/ocp-bpfman-tenant",
+			base:      "catalog-ystream",
+			str:       "quay.io/redhat-user-workloads/ocp-bpfman-tenant/catalog-ystream:latest",
+		},
+		{
+			name:      "registry with port",
+			ref:       "localhost:5000/tenant/bundle:v0.5.9",
+			want:      Reference{Registry: "localhost:5000", Repository: "tenant/bundle", Tag: "v0.5.9"},
+			namespace: "tenant",
+			base:      "bundle",
+			str:       "localhost:5000/tenant/bundle:v0.5.9",
+		},
+		{
+			name:      "registry with port and no tag",
+			ref:       "127.0.0.1:35123/bundle",
+			want:      Reference{Registry: "127.0.0.1:35123", Repository: "bundle"},
+			namespace: "",
+			base:      "bundle",
+			str:       "127.0.0.1:35123/bundle",
+		},
+		{
+			name:      "digest",
+			ref:       "registry.redhat.io/bpfman/bpfman-rhel9-operator@" + testDigest,
+			want:      Reference{Registry: "registry.redhat.io", Repository: "bpfman/bpfman-rhel9-operator", Digest: testDigest},
+			namespace: "bpfman",
+			base:      "bpfman-rhel9-operator",
+			str:       "registry.redhat.io/bpfman/bpfman-rhel9-operator@" + testDigest,
+		},
+		{
+			name:      "tag and digest",
+			ref:       "localhost:5000/a/b/c:v1@" + testDigest,
+			want:      Reference{Registry: "localhost:5000", Repository: "a/b/c", Tag: "v1", Digest: testDigest},
+			namespace: "a/b",
+			base:      "c",
+			str:       "localhost:5000/a/b/c:v1@" + testDigest,
+		},
+		{
+			name:      "docker transport",
+			ref:       "docker://quay.io/bpfman/bpfman:latest",
+			want:      Reference{Registry: "quay.io", Repository: "bpfman/bpfman", Tag: "latest"},
+			namespace: "bpfman",
+			base:      "bpfman",
+			str:       "quay.io/bpfman/bpfman:latest",
+		},
+		{
+			name:      "docker hub official image",
+			ref:       "busybox",
+			want:      Reference{Registry: "docker.io", Repository: "library/busybox"},
+			namespace: "library",
+			base:      "busybox",
+			str:       "docker.io/library/busybox",
+		},
+		{
+			name:      "docker hub user image",
+			ref:       "bpfman/bpfman:v0.5.9",
+			want:      Reference{Registry: "docker.io", Repository: "bpfman/bpfman", Tag: "v0.5.9"},
+			namespace: "bpfman",
+			base:      "bpfman",
+			str:       "docker.io/bpfman/bpfman:v0.5.9",
+		},
+		{
+			name:      "index.docker.io",
+			ref:       "index.docker.io/library/fedora:42",
+			want:      Reference{Registry: "docker.io", Repository: "library/fedora", Tag: "42"},
+			namespace: "library",
+			base:      "fedora",
+			str:       "docker.io/library/fedora:42",
+		},
+		{
+			name: "oci layout",
+			ref:  "oci:/tmp/layout:catalog",
+			want: Reference{Transport: "oci", Path: "/tmp/layout:catalog"},
+			str:  "oci:/tmp/layout:catalog",
+		},
+		{
+			name: "docker archive",
+			ref:  "docker-archive:/tmp/bundle.tar",
+			want: Reference{Transport: "docker-archive", Path: "/tmp/bundle.tar"},
+			str:  "docker-archive:/tmp/bundle.tar",
+		},
+		{name: "empty", ref: "", wantErr: true},
+		{name: "local transport without path", ref: "oci:", wantErr: true},
+		{name: "uppercase repository", ref: "quay.io/Tenant/bundle", wantErr: true},
+		{name: "empty tag", ref: "quay.io/tenant/bundle:", wantErr: true},
+		{name: "truncated digest", ref: "quay.io/tenant/bundle@sha256:abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.ref, got, tt.want)
+			}
+			if got.Namespace() != tt.namespace || got.Base() != tt.base {
+				t.Errorf("namespace, base = %q, %q, want %q, %q", got.Namespace(), got.Base(), tt.namespace, tt.base)
+			}
+			if got.String() != tt.str {
+				t.Errorf("String() = %q, want %q", got.String(), tt.str)
+			}
+		})
+	}
+}
+
+func TestDigestRef(t *testing.T) {
+	r, err := Parse("localhost:5000/tenant/catalog:latest@" + testDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.DigestRef(), "localhost:5000/tenant/catalog@"+testDigest; got != want {
+		t.Errorf("DigestRef() = %q, want %q", got, want)
+	}
+
+	r, err = Parse("localhost:5000/tenant/catalog:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.DigestRef(); got != r.String() {
+		t.Errorf("DigestRef() without digest = %q, want %q", got, r.String())
+	}
+}
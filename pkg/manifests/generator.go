@@ -11,9 +11,19 @@ import (
 // GeneratorConfig contains configuration for manifest generation.
 type GeneratorConfig struct {
 	ImageRef      string // Catalog or bundle image reference
-	Namespace     string // Target namespace (default: bpfman)
+	Package       string // Package to install (default: the catalog's only package)
+	Namespace     string // Target namespace (default: catalog.PackageNamespace of the package)
 	UseDigestName bool   // Whether to suffix resources with digest
 	SkipIDMS      bool   // Whether to skip generating the ImageDigestMirrorSet
+
+	// Time stamped into the CatalogSource display name and
+	// publisher (default: now)
+	Time time.Time
+
+	// Subscription settings
+	Channel             string // Channel to subscribe to (default: the catalog's default channel)
+	StartingCSV         string // CSV to install first instead of the channel head
+	InstallPlanApproval string // Automatic (default) or Manual
 }
 
 // LabelContext contains labeling information for consistent resource labeling.
@@ -31,8 +41,11 @@ type Generator struct {
 
 // NewGenerator creates a new manifest generator.
 func NewGenerator(config GeneratorConfig) *Generator {
-	if config.Namespace == "" {
-		config.Namespace = "bpfman"
+	if config.InstallPlanApproval == "" {
+		config.InstallPlanApproval = "Automatic"
+	}
+	if config.Time.IsZero() {
+		config.Time = time.Now()
 	}
 	config.UseDigestName = true // Always use digest naming for clarity
 
@@ -42,10 +55,10 @@ func NewGenerator(config GeneratorConfig) *Generator {
 }
 
 // setupLabelContext initialises the label context with digest and
-// standard labels.
-func (g *Generator) setupLabelContext(shortDigest string) {
+// standard labels for a package.
+func (g *Generator) setupLabelContext(shortDigest, packageName string) {
 	standardLabels := map[string]string{
-		"app.kubernetes.io/name":       "bpfman-operator",
+		"app.kubernetes.io/name":       packageName,
 		"app.kubernetes.io/created-by": "bpfman-catalog-cli",
 		"app.kubernetes.io/version":    "latest", // Could be made configurable
 	}
@@ -124,7 +137,7 @@ func (g *Generator) NewCatalogSource(meta CatalogMetadata) *CatalogSource {
 			Labels:    g.getMergedLabels(nil),
 		},
 		Spec: func() CatalogSourceSpec {
-			timestamp := time.Now().Format("2006-01-02T15:04:05")
+			timestamp := g.config.Time.Format("2006-01-02T15:04:05")
 			return CatalogSourceSpec{
 				SourceType:  "grpc",
 				Image:       meta.Image,
@@ -153,8 +166,9 @@ func (g *Generator) NewOperatorGroup(namespace string) *OperatorGroup {
 }
 
 // NewSubscription creates a subscription manifest with consistent
-// labelling.
-func (g *Generator) NewSubscription(namespace, catalogSourceName, channel string) *Subscription {
+// labelling to a package. The starting CSV and install plan approval
+// are taken from the generator configuration.
+func (g *Generator) NewSubscription(namespace, catalogSourceName, packageName, channel string) *Subscription {
 	return &Subscription{
 		TypeMeta: TypeMeta{
 			APIVersion: "operators.coreos.com/v1alpha1",
@@ -167,10 +181,11 @@ func (g *Generator) NewSubscription(namespace, catalogSourceName, channel string
 		},
 		Spec: SubscriptionSpec{
 			Channel:             channel,
-			Name:                "bpfman-operator",
+			Name:                packageName,
 			Source:              catalogSourceName,
 			SourceNamespace:     "openshift-marketplace",
-			InstallPlanApproval: "Automatic",
+			InstallPlanApproval: g.config.InstallPlanApproval,
+			StartingCSV:         g.config.StartingCSV,
 		},
 	}
 }
@@ -203,16 +218,24 @@ func (g *Generator) NewImageDigestMirrorSet() *ImageDigestMirrorSet {
 
 // GenerateFromCatalog generates manifests for a catalog image.
 func (g *Generator) GenerateFromCatalog(ctx context.Context) (*ManifestSet, error) {
-	meta, err := catalog.ExtractMetadata(ctx, g.config.ImageRef)
+	meta, err := catalog.ExtractMetadata(ctx, g.config.ImageRef, g.config.Package)
 	if err != nil {
 		return nil, fmt.Errorf("extracting catalog metadata: %w", err)
 	}
+	if g.config.Namespace == "" {
+		g.config.Namespace = catalog.PackageNamespace(meta.Package)
+	}
 
 	digestSuffix := getDigestSuffix(g.config.UseDigestName, meta.ShortDigest)
-	g.setupLabelContext(digestSuffix)
+	g.setupLabelContext(digestSuffix, meta.Package)
+
+	channel := meta.DefaultChannel
+	if g.config.Channel != "" {
+		channel = g.config.Channel
+	}
 
 	catalogMeta := createCatalogMetadata(meta)
-	return g.buildManifestSet(catalogMeta, meta.DefaultChannel)
+	return g.buildManifestSet(catalogMeta, channel)
 }
 
 func getDigestSuffix(useDigestName bool, shortDigest string) string {
@@ -228,6 +251,7 @@ func createCatalogMetadata(meta *catalog.ImageMetadata) CatalogMetadata {
 		Digest:      string(meta.Digest),
 		ShortDigest: meta.ShortDigest,
 		CatalogType: meta.CatalogType,
+		Package:     meta.Package,
 	}
 }
 
@@ -251,7 +275,7 @@ func (g *Generator) buildManifestSet(catalogMeta CatalogMetadata, channel string
 	manifestSet.OperatorGroup = g.NewOperatorGroup(namespaceName)
 
 	catalogSourceName := manifestSet.CatalogSource.ObjectMeta.Name
-	manifestSet.Subscription = g.NewSubscription(namespaceName, catalogSourceName, channel)
+	manifestSet.Subscription = g.NewSubscription(namespaceName, catalogSourceName, catalogMeta.Package, channel)
 
 	return manifestSet, nil
 }
@@ -61,6 +61,7 @@ type SubscriptionSpec struct {
 	Source              string `json:"source"`
 	SourceNamespace     string `json:"sourceNamespace"`
 	InstallPlanApproval string `json:"installPlanApproval"`
+	StartingCSV         string `json:"startingCSV,omitempty"`
 }
 
 // ImageDigestMirrorSet represents an OpenShift ImageDigestMirrorSet.
@@ -87,6 +88,7 @@ type CatalogMetadata struct {
 	Digest      string // Full digest
 	ShortDigest string // Short digest for naming
 	CatalogType string // Type of catalog (e.g., catalog-ystream)
+	Package     string // Package subscribed to (e.g., bpfman-operator)
 }
 
 // ManifestSet contains all manifests needed for deployment.
@@ -0,0 +1,387 @@
+// Package provenance records how a directory of generated artefacts
+// was produced, in an artefacts.json file beside them, and detects
+// later changes to the directory.
+package provenance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/openshift/bpfman-catalog/pkg/registry"
+)
+
+// FileName is the name of the provenance file in an artefacts
+// directory.
+const FileName = "artefacts.json"
+
+// schemaVersion is the version of the provenance file format.
+const schemaVersion = 1
+
+// operatorRegistryModule is the module whose version renders
+// catalogs in library mode.
+const operatorRegistryModule = "github.com/operator-framework/operator-registry"
+
+// Kinds of recorded inputs.
+const (
+	InputBundle           = "bundle"
+	InputRebuiltBundle    = "rebuilt-bundle"
+	InputReleasedTemplate = "released-template"
+	InputCatalog          = "catalog"
+	InputCatalogImage     = "catalog-image"
+	InputTemplate         = "template"
+)
+
+// Render modes.
+const (
+	RenderLibrary = "library"
+	RenderOpm     = "opm"
+)
+
+// secretFlags are flags whose values are not recorded.
+var secretFlags = []string{"--creds", "--github-token"}
+
+// Manifest is the content of an artefacts.json file.
+type Manifest struct {
+	Version int       `json:"version"`
+	Tool    Tool      `json:"tool"`
+	Command []string  `json:"command"`
+	Created time.Time `json:"created"`
+	Inputs  []Input   `json:"inputs,omitempty"`
+	Render  *Render   `json:"render,omitempty"`
+	Files   []File    `json:"files"`
+}
+
+// Tool identifies the bpfman-catalog build that generated the
+// artefacts.
+type Tool struct {
+	Version  string `json:"version"`
+	Commit   string `json:"commit,omitempty"`
+	Modified bool   `json:"modified,omitempty"` // Built from a tree with uncommitted changes
+}
+
+// Input is an image or file the artefacts were generated from.
+type Input struct {
+	Kind      string `json:"kind"`
+	Reference string `json:"reference"` // As given on the command line
+	Digest    string `json:"digest"`    // Manifest digest of an image, or SHA-256 of a file
+}
+
+// Render records how the catalog was rendered.
+type Render struct {
+	Mode    string `json:"mode"`          // library or opm
+	Opm     string `json:"opm,omitempty"` // opm binary, in opm mode
+	Version string `json:"version"`       // opm or operator-registry version
+}
+
+// File is a generated file and its SHA-256.
+type File struct {
+	Path   string `json:"path"` // Slash-separated, relative to the artefacts directory
+	SHA256 string `json:"sha256"`
+}
+
+// Difference is a change to an artefacts directory since its
+// artefacts.json was written.
+type Difference struct {
+	Path   string `json:"path"`
+	Change string `json:"change"` // modified, missing or unrecorded
+}
+
+// Changes reported in a Difference.
+const (
+	Modified   = "modified"
+	Missing    = "missing"
+	Unrecorded = "unrecorded"
+)
+
+// New returns a manifest for the running tool, invoked with args
+// (os.Args[1:]), created at t. Values of flags that carry
+// credentials are redacted, as is every occurrence of secrets, the
+// credentials in effect however they were supplied.
+func New(args []string, t time.Time, secrets ...string) *Manifest {
+	return &Manifest{
+		Version: schemaVersion,
+		Tool:    CurrentTool(),
+		Command: append([]string{"bpfman-catalog"}, redact(args, secrets)...),
+		Created: t.UTC(),
+	}
+}
+
+// CurrentTool returns the version and commit of the running binary
+// from its build information.
+func CurrentTool() Tool {
+	tool := Tool{Version: "unknown"}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return tool
+	}
+	tool.Version = info.Main.Version
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			tool.Commit = setting.Value
+		case "vcs.modified":
+			tool.Modified = setting.Value == "true"
+		}
+	}
+	return tool
+}
+
+// redact replaces the values of secretFlags in args, and any
+// occurrence of a non-empty secret elsewhere.
+func redact(args []string, secrets []string) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		for _, secret := range secrets {
+			if secret != "" {
+				arg = strings.ReplaceAll(arg, secret, "REDACTED")
+			}
+		}
+		out[i] = arg
+	}
+	for i := 0; i < len(out); i++ {
+		for _, flag := range secretFlags {
+			if out[i] == flag && i+1 < len(out) {
+				out[i+1] = "REDACTED"
+				i++
+			} else if strings.HasPrefix(out[i], flag+"=") {
+				out[i] = flag + "=REDACTED"
+			}
+		}
+	}
+	return out
+}
+
+// AddImage records an image input, resolved to its manifest digest.
+func (m *Manifest) AddImage(ctx context.Context, kind, ref string) error {
+	d, err := registry.Digest(ctx, ref)
+	if err != nil {
+		return fmt.Errorf("resolving digest of %s: %w", ref, err)
+	}
+	m.AddResolvedImage(kind, ref, d)
+	return nil
+}
+
+// AddResolvedImage records an image input whose manifest digest was
+// resolved when it was read, so that the recorded digest is the one
+// the artefacts were generated from.
+func (m *Manifest) AddResolvedImage(kind, ref string, d digest.Digest) {
+	m.Inputs = append(m.Inputs, Input{Kind: kind, Reference: ref, Digest: d.String()})
+}
+
+// AddFile records a file input with its SHA-256.
+func (m *Manifest) AddFile(kind, path string) error {
+	sum, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+	m.Inputs = append(m.Inputs, Input{Kind: kind, Reference: path, Digest: "sha256:" + sum})
+	return nil
+}
+
+// LibraryRender records rendering with the built-in
+// operator-registry library.
+func LibraryRender() *Render {
+	render := &Render{Mode: RenderLibrary, Version: "unknown"}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == operatorRegistryModule {
+				render.Version = dep.Version
+			}
+		}
+	}
+	return render
+}
+
+// OpmRender records rendering with the opm binary at path, with the
+// version it reports.
+func OpmRender(ctx context.Context, path string) (*Render, error) {
+	output, err := exec.CommandContext(ctx, path, "version").Output()
+	if err != nil {
+		return nil, fmt.Errorf("running %s version: %w", path, err)
+	}
+	return &Render{Mode: RenderOpm, Opm: path, Version: strings.TrimSpace(string(output))}, nil
+}
+
+// Write hashes every file in dir and writes the manifest to
+// dir/artefacts.json.
+func Write(dir string, m *Manifest) error {
+	files, err := hashTree(dir)
+	if err != nil {
+		return err
+	}
+	return writeManifest(dir, m, files)
+}
+
+// writeManifest writes m, recording files, to dir/artefacts.json.
+func writeManifest(dir string, m *Manifest, files []File) error {
+	m.Files = files
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling %s: %w", FileName, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, FileName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", FileName, err)
+	}
+	return nil
+}
+
+// Enclosing returns the nearest directory above dir that holds an
+// artefacts.json, or "" if there is none.
+func Enclosing(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for parent := filepath.Dir(abs); parent != abs; abs, parent = parent, filepath.Dir(parent) {
+		if _, err := os.Stat(filepath.Join(parent, FileName)); err == nil {
+			return parent, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// Merge records the files under sub, a directory inside root, in
+// root/artefacts.json, replacing those previously recorded there,
+// and replaces the recorded inputs of the same kinds as inputs.
+// Other entries are left as recorded, so that Verify still reports
+// changes to them.
+func Merge(root, sub string, inputs ...Input) error {
+	m, err := Load(root)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, sub)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("%s is not inside %s", sub, root)
+	}
+	prefix := filepath.ToSlash(rel) + "/"
+
+	subFiles, err := hashTree(sub)
+	if err != nil {
+		return err
+	}
+	files := slices.DeleteFunc(m.Files, func(f File) bool { return strings.HasPrefix(f.Path, prefix) })
+	for _, f := range subFiles {
+		if f.Path == FileName {
+			continue
+		}
+		files = append(files, File{Path: prefix + f.Path, SHA256: f.SHA256})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	for _, in := range inputs {
+		m.Inputs = slices.DeleteFunc(m.Inputs, func(old Input) bool { return old.Kind == in.Kind })
+	}
+	m.Inputs = append(m.Inputs, inputs...)
+
+	return writeManifest(root, m, files)
+}
+
+// Load reads dir/artefacts.json.
+func Load(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", FileName, err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", FileName, err)
+	}
+	if m.Version != schemaVersion {
+		return nil, fmt.Errorf("%s has version %d, want %d", FileName, m.Version, schemaVersion)
+	}
+	return &m, nil
+}
+
+// Verify compares the files in dir with those recorded in its
+// artefacts.json, and returns the manifest and every file that was
+// modified, removed or added since, ordered by path.
+func Verify(dir string) (*Manifest, []Difference, error) {
+	m, err := Load(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	files, err := hashTree(dir)
+	if err != nil {
+		return m, nil, err
+	}
+
+	current := make(map[string]string, len(files))
+	for _, f := range files {
+		current[f.Path] = f.SHA256
+	}
+	var diffs []Difference
+	for _, f := range m.Files {
+		sum, ok := current[f.Path]
+		switch {
+		case !ok:
+			diffs = append(diffs, Difference{Path: f.Path, Change: Missing})
+		case sum != f.SHA256:
+			diffs = append(diffs, Difference{Path: f.Path, Change: Modified})
+		}
+		delete(current, f.Path)
+	}
+	for path := range current {
+		diffs = append(diffs, Difference{Path: path, Change: Unrecorded})
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return m, diffs, nil
+}
+
+// hashTree returns the SHA-256 of every regular file under dir
+// except artefacts.json, ordered by path.
+func hashTree(dir string) ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == FileName {
+			return nil
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Path: rel, SHA256: sum})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("hashing %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("reading %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
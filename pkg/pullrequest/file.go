@@ -0,0 +1,125 @@
+package pullrequest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/openshift/bpfman-catalog/pkg/cachedir"
+	"github.com/sirupsen/logrus"
+)
+
+// File is a provider backed by a JSON file mapping
+// "owner/name@commit" to pull request details. It serves offline
+// lookups on its own and caches lookups made by another provider.
+type File struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]*PullRequest
+}
+
+var _ Provider = &File{}
+
+// fileContent is the on-disk format of a File.
+type fileContent struct {
+	PullRequests map[string]*PullRequest `json:"pull_requests"`
+}
+
+// LoadFile reads a pull request file. A missing file is treated as
+// empty.
+func LoadFile(path string) (*File, error) {
+	f := &File{path: path, entries: make(map[string]*PullRequest)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading pull request file: %w", err)
+	}
+
+	var content fileContent
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("parsing pull request file %s: %w", path, err)
+	}
+	for key, pr := range content.PullRequests {
+		if pr != nil {
+			f.entries[key] = pr
+		}
+	}
+	return f, nil
+}
+
+func fileKey(repository, commit string) string {
+	return repository + "@" + commit
+}
+
+// Lookup returns the recorded pull request for commit.
+func (f *File) Lookup(_ context.Context, repository, commit string) (*PullRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if pr, ok := f.entries[fileKey(repository, commit)]; ok {
+		return pr, nil
+	}
+	return nil, ErrNotFound
+}
+
+// Store records the pull request for commit.
+func (f *File) Store(repository, commit string, pr *PullRequest) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries[fileKey(repository, commit)] = pr
+}
+
+// Save writes the file, replacing the previous content atomically.
+func (f *File) Save() error {
+	f.mu.Lock()
+	data, err := json.MarshalIndent(fileContent{PullRequests: f.entries}, "", "  ")
+	f.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("marshalling pull requests: %w", err)
+	}
+
+	if err := cachedir.WriteFile(f.path, data); err != nil {
+		return fmt.Errorf("saving pull requests: %w", err)
+	}
+	return nil
+}
+
+// cached serves lookups from a file, falling back to another provider
+// and recording pull requests that can no longer change.
+type cached struct {
+	file     *File
+	upstream Provider
+}
+
+// Cached returns a provider that answers from file where it can and
+// otherwise asks upstream. Merged and closed pull requests found
+// upstream are written back to file; open ones are looked up again
+// next time as their state may change.
+func Cached(file *File, upstream Provider) Provider {
+	return &cached{file: file, upstream: upstream}
+}
+
+func (c *cached) Lookup(ctx context.Context, repository, commit string) (*PullRequest, error) {
+	if pr, err := c.file.Lookup(ctx, repository, commit); err == nil {
+		return pr, nil
+	}
+
+	pr, err := c.upstream.Lookup(ctx, repository, commit)
+	if err != nil {
+		return nil, err
+	}
+	if pr.Final() {
+		c.file.Store(repository, commit, pr)
+		if err := c.file.Save(); err != nil {
+			logrus.WithError(err).Warnf("not caching pull request for %s@%s", repository, commit)
+		}
+	}
+	return pr, nil
+}
@@ -0,0 +1,103 @@
+package pullrequest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultGitHubURL is the GitHub REST API endpoint.
+const DefaultGitHubURL = "https://api.github.com"
+
+// GitHub looks up pull requests with the GitHub REST API. Failed
+// requests are not retried: pull requests only annotate a listing, so
+// a lookup that fails is skipped rather than allowed to hold it up.
+type GitHub struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+var _ Provider = &GitHub{}
+
+// NewGitHub creates a provider for the API at baseURL, authenticating
+// with token if it is not empty.
+func NewGitHub(baseURL, token string) *GitHub {
+	return &GitHub{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// githubPull is the subset of the GitHub pull request schema used.
+type githubPull struct {
+	Number   int     `json:"number"`
+	Title    string  `json:"title"`
+	State    string  `json:"state"`
+	HTMLURL  string  `json:"html_url"`
+	MergedAt *string `json:"merged_at"`
+	User     struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Head struct {
+		SHA string `json:"sha"`
+	} `json:"head"`
+}
+
+// Lookup returns the pull request associated with commit. When a
+// commit belongs to several pull requests, the one whose head is the
+// commit is preferred.
+func (g *GitHub) Lookup(ctx context.Context, repository, commit string) (*PullRequest, error) {
+	url := fmt.Sprintf("%s/repos/%s/commits/%s/pulls", g.baseURL, repository, commit)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: HTTP %s", url, resp.Status)
+	}
+	var pulls []githubPull
+	if err := json.NewDecoder(resp.Body).Decode(&pulls); err != nil {
+		return nil, fmt.Errorf("decoding pull requests: %w", err)
+	}
+
+	if len(pulls) == 0 {
+		return nil, ErrNotFound
+	}
+	chosen := pulls[0]
+	for _, p := range pulls {
+		if p.Head.SHA == commit {
+			chosen = p
+			break
+		}
+	}
+
+	state := chosen.State
+	if chosen.MergedAt != nil {
+		state = StateMerged
+	}
+	return &PullRequest{
+		Number: chosen.Number,
+		Title:  chosen.Title,
+		Author: chosen.User.Login,
+		State:  state,
+		URL:    chosen.HTMLURL,
+	}, nil
+}
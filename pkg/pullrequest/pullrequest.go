@@ -0,0 +1,112 @@
+// Package pullrequest resolves the git commits that on-pr-<commit>
+// bundle builds are tagged with to the pull requests that built them.
+package pullrequest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultRepository is the GitHub repository bundles are built from.
+const DefaultRepository = "openshift/bpfman-operator"
+
+// tagPrefix prefixes the tags of images built for a pull request.
+const tagPrefix = "on-pr-"
+
+// Pull request states.
+const (
+	StateOpen   = "open"
+	StateClosed = "closed"
+	StateMerged = "merged"
+)
+
+// ErrNotFound is returned when no pull request is known for a commit.
+var ErrNotFound = errors.New("no pull request found for commit")
+
+// PullRequest describes the pull request a commit was built for.
+type PullRequest struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	State  string `json:"state"` // open, closed or merged
+	URL    string `json:"url"`
+}
+
+// Final reports whether the pull request can no longer change state,
+// so its details are safe to cache indefinitely.
+func (pr *PullRequest) Final() bool {
+	return pr.State == StateMerged || pr.State == StateClosed
+}
+
+// Provider looks up the pull request for a commit in a repository
+// given as owner/name.
+type Provider interface {
+	Lookup(ctx context.Context, repository, commit string) (*PullRequest, error)
+}
+
+// CommitLength is the length of a full git commit SHA, as build tags
+// carry it.
+const CommitLength = 40
+
+// IsCommit reports whether s is a full, lowercase git commit SHA.
+func IsCommit(s string) bool {
+	if len(s) != CommitLength {
+		return false
+	}
+	for _, c := range s {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
+			return false
+		}
+	}
+	return true
+}
+
+// CommitFromTag returns the commit of an on-pr-<commit> tag. It is
+// the one parser of pull request build tags, shared by the bundle
+// listing filters and the resolver.
+func CommitFromTag(tag string) (string, bool) {
+	commit, ok := strings.CutPrefix(tag, tagPrefix)
+	if !ok || !IsCommit(commit) {
+		return "", false
+	}
+	return commit, true
+}
+
+// Resolver resolves pull request build tags with a provider.
+type Resolver struct {
+	Provider   Provider
+	Repository string // Repository used when the caller does not know one
+}
+
+// ResolveTag returns the pull request an on-pr-<commit> tag was built
+// for. It returns nil and no error for any other tag.
+func (r *Resolver) ResolveTag(ctx context.Context, repository, tag string) (*PullRequest, error) {
+	commit, ok := CommitFromTag(tag)
+	if !ok {
+		return nil, nil
+	}
+	if repository == "" {
+		repository = r.Repository
+	}
+	pr, err := r.Provider.Lookup(ctx, repository, commit)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s in %s: %w", tag, repository, err)
+	}
+	return pr, nil
+}
+
+type resolverKey struct{}
+
+// WithResolver returns a context carrying a pull request resolver.
+func WithResolver(ctx context.Context, r *Resolver) context.Context {
+	return context.WithValue(ctx, resolverKey{}, r)
+}
+
+// ResolverFromContext returns the resolver carried by ctx, or nil if
+// pull requests are not to be resolved.
+func ResolverFromContext(ctx context.Context) *Resolver {
+	r, _ := ctx.Value(resolverKey{}).(*Resolver)
+	return r
+}
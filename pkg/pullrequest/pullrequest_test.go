@@ -0,0 +1,119 @@
+package pullrequest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	mergedCommit = "1111111111111111111111111111111111111111"
+	openCommit   = "2222222222222222222222222222222222222222"
+)
+
+// newGitHubStandIn serves the commit pulls endpoint for two commits
+// and counts the requests it receives.
+func newGitHubStandIn(t *testing.T, requests *int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q, want bearer token", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/openshift/bpfman-operator/commits/" + mergedCommit + "/pulls":
+			// The commit is also in an older pull request; the one
+			// whose head is the commit must be chosen.
+			_, _ = w.Write([]byte(`[
+				{"number": 10, "title": "Older", "state": "closed", "html_url": "https://github.com/openshift/bpfman-operator/pull/10",
+				 "merged_at": null, "user": {"login": "someone"}, "head": {"sha": "3333333333333333333333333333333333333333"}},
+				{"number": 42, "title": "Add widget", "state": "closed", "html_url": "https://github.com/openshift/bpfman-operator/pull/42",
+				 "merged_at": "2025-03-01T00:00:00Z", "user": {"login": "octocat"}, "head": {"sha": "` + mergedCommit + `"}}
+			]`))
+		case "/repos/openshift/bpfman-operator/commits/" + openCommit + "/pulls":
+			_, _ = w.Write([]byte(`[{"number": 43, "title": "WIP", "state": "open", "html_url": "https://github.com/openshift/bpfman-operator/pull/43",
+				"merged_at": null, "user": {"login": "hubot"}, "head": {"sha": "` + openCommit + `"}}]`))
+		default:
+			_, _ = w.Write([]byte(`[]`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGitHubLookup(t *testing.T) {
+	var requests int
+	srv := newGitHubStandIn(t, &requests)
+	gh := NewGitHub(srv.URL, "secret")
+	ctx := context.Background()
+
+	pr, err := gh.Lookup(ctx, DefaultRepository, mergedCommit)
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	want := PullRequest{Number: 42, Title: "Add widget", Author: "octocat", State: StateMerged, URL: "https://github.com/openshift/bpfman-operator/pull/42"}
+	if *pr != want {
+		t.Errorf("Lookup = %+v, want %+v", *pr, want)
+	}
+
+	if _, err := gh.Lookup(ctx, DefaultRepository, strings.Repeat("9", 40)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup of unknown commit error = %v, want ErrNotFound", err)
+	}
+}
+
+// TestCachedLookup serves merged pull requests from the cache file on
+// later lookups but asks again for open ones.
+func TestCachedLookup(t *testing.T) {
+	var requests int
+	srv := newGitHubStandIn(t, &requests)
+	cachePath := filepath.Join(t.TempDir(), "pull-requests.json")
+	ctx := context.Background()
+
+	lookupBoth := func() {
+		t.Helper()
+		file, err := LoadFile(cachePath)
+		if err != nil {
+			t.Fatalf("LoadFile: %v", err)
+		}
+		resolver := &Resolver{Provider: Cached(file, NewGitHub(srv.URL, "secret")), Repository: DefaultRepository}
+		for _, commit := range []string{mergedCommit, openCommit} {
+			pr, err := resolver.ResolveTag(ctx, "", "on-pr-"+commit)
+			if err != nil || pr == nil {
+				t.Fatalf("ResolveTag(%s) = %v, %v", commit, pr, err)
+			}
+		}
+	}
+
+	lookupBoth()
+	if requests != 2 {
+		t.Fatalf("first lookups made %d requests, want 2", requests)
+	}
+	lookupBoth()
+	if requests != 3 {
+		t.Errorf("second lookups made %d requests in total, want 3 (only the open pull request)", requests)
+	}
+
+	// The cache file alone answers for the merged pull request.
+	file, err := LoadFile(cachePath)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if pr, err := file.Lookup(ctx, DefaultRepository, mergedCommit); err != nil || pr.Number != 42 {
+		t.Errorf("file Lookup = %v, %v, want pull request 42", pr, err)
+	}
+}
+
+func TestResolveTagIgnoresOtherTags(t *testing.T) {
+	resolver := &Resolver{Provider: &File{entries: map[string]*PullRequest{}}, Repository: DefaultRepository}
+	for _, tag := range []string{mergedCommit, "latest", "on-pr-abc"} {
+		pr, err := resolver.ResolveTag(context.Background(), "", tag)
+		if pr != nil || err != nil {
+			t.Errorf("ResolveTag(%q) = %v, %v, want nil, nil", tag, pr, err)
+		}
+	}
+}
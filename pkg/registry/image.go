@@ -0,0 +1,305 @@
+package registry
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/image/v5/copy"
+	ciimage "github.com/containers/image/v5/image"
+	"github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/pkg/compression"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/types"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/operator-framework/operator-registry/pkg/image"
+)
+
+// Registry implements the operator-registry image.Registry interface
+// on containers/image without a container engine. Pulled images are
+// copied into an OCI layout in a temporary directory that is removed
+// by Destroy.
+type Registry struct {
+	sys      *types.SystemContext
+	cacheDir string
+}
+
+var _ image.Registry = &Registry{}
+
+// NewImageRegistry creates a Registry that accesses images with the
+// registry options carried by ctx.
+func NewImageRegistry(ctx context.Context) (*Registry, error) {
+	cacheDir, err := os.MkdirTemp("", "bpfman-catalog-images-")
+	if err != nil {
+		return nil, fmt.Errorf("creating image cache directory: %w", err)
+	}
+
+	sys := SystemContext(ctx)
+	sys.OSChoice = "linux"
+
+	return &Registry{
+		sys:      sys,
+		cacheDir: cacheDir,
+	}, nil
+}
+
+// cacheReference returns the OCI layout reference under which ref is
+// stored in the cache.
+func (r *Registry) cacheReference(ref image.Reference) (types.ImageReference, error) {
+	sum := sha256.Sum256([]byte(ref.String()))
+	return layout.NewReference(r.cacheDir, hex.EncodeToString(sum[:]))
+}
+
+// Pull fetches an image by reference and stores it in the cache.
+func (r *Registry) Pull(ctx context.Context, ref image.Reference) error {
+	srcRef, err := ParseReference(ref.String())
+	if err != nil {
+		return err
+	}
+
+	destRef, err := r.cacheReference(ref)
+	if err != nil {
+		return fmt.Errorf("creating cache reference: %w", err)
+	}
+
+	policyContext, err := signature.NewPolicyContext(&signature.Policy{
+		Default: signature.PolicyRequirements{signature.NewPRInsecureAcceptAnything()},
+	})
+	if err != nil {
+		return fmt.Errorf("creating signature policy: %w", err)
+	}
+	defer policyContext.Destroy()
+
+	err = Retry(ctx, func() error {
+		_, err := copy.Image(ctx, policyContext, destRef, srcRef, &copy.Options{
+			SourceCtx:                             r.sys,
+			DestinationCtx:                        &types.SystemContext{},
+			OptimizeDestinationImageAlreadyExists: true,
+			RemoveSignatures:                      true,
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("pulling %s: %w", ref, err)
+	}
+
+	return nil
+}
+
+// Unpack writes the filesystem content of a pulled image to dir,
+// applying layers in order.
+func (r *Registry) Unpack(ctx context.Context, ref image.Reference, dir string) error {
+	cacheRef, err := r.cacheReference(ref)
+	if err != nil {
+		return fmt.Errorf("creating cache reference: %w", err)
+	}
+
+	src, err := cacheRef.NewImageSource(ctx, &types.SystemContext{})
+	if err != nil {
+		return fmt.Errorf("image %s has not been pulled: %w", ref, err)
+	}
+	defer src.Close()
+
+	img, err := ciimage.FromSource(ctx, &types.SystemContext{}, src)
+	if err != nil {
+		return fmt.Errorf("reading image %s: %w", ref, err)
+	}
+	defer img.Close()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating unpack directory: %w", err)
+	}
+
+	for _, info := range img.LayerInfos() {
+		if err := unpackLayer(ctx, src, info, dir); err != nil {
+			return fmt.Errorf("unpacking layer %s of %s: %w", info.Digest, ref, err)
+		}
+	}
+
+	return nil
+}
+
+// Labels returns the labels of a pulled image.
+func (r *Registry) Labels(ctx context.Context, ref image.Reference) (map[string]string, error) {
+	config, err := r.Config(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return config.Config.Labels, nil
+}
+
+// Config returns the OCI config of a pulled image, which records its
+// platform as well as its labels.
+func (r *Registry) Config(ctx context.Context, ref image.Reference) (*imgspecv1.Image, error) {
+	cacheRef, err := r.cacheReference(ref)
+	if err != nil {
+		return nil, fmt.Errorf("creating cache reference: %w", err)
+	}
+
+	img, err := cacheRef.NewImage(ctx, &types.SystemContext{})
+	if err != nil {
+		return nil, fmt.Errorf("image %s has not been pulled: %w", ref, err)
+	}
+	defer img.Close()
+
+	config, err := img.OCIConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading config of %s: %w", ref, err)
+	}
+	return config, nil
+}
+
+// Destroy removes the image cache.
+func (r *Registry) Destroy() error {
+	return os.RemoveAll(r.cacheDir)
+}
+
+// unpackLayer extracts a single layer blob into dir.
+func unpackLayer(ctx context.Context, src types.ImageSource, info types.BlobInfo, dir string) error {
+	blob, _, err := src.GetBlob(ctx, info, nil)
+	if err != nil {
+		return fmt.Errorf("fetching blob: %w", err)
+	}
+	defer blob.Close()
+
+	stream, _, err := compression.AutoDecompress(blob)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	return untar(stream, dir)
+}
+
+// untar extracts a layer tar stream into dir. Whiteout entries remove
+// content from earlier layers. Ownership is not preserved and
+// permissions are widened so the caller can always read and remove
+// what was extracted.
+func untar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading tar: %w", err)
+		}
+
+		target, err := securePath(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		base := filepath.Base(target)
+		parent := filepath.Dir(target)
+		if base == ".wh..wh..opq" {
+			entries, err := os.ReadDir(parent)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			for _, entry := range entries {
+				if err := os.RemoveAll(filepath.Join(parent, entry.Name())); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if name, ok := strings.CutPrefix(base, ".wh."); ok {
+			if err := os.RemoveAll(filepath.Join(parent, name)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return err
+		}
+		if err := checkResolvedParent(dir, parent); err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, hdr.FileInfo().Mode().Perm()|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+			if err := writeFile(target, tr, hdr.FileInfo().Mode().Perm()|0600); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			source, err := securePath(dir, hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+			if err := os.Link(source, target); err != nil {
+				return err
+			}
+		default:
+			// Device nodes and FIFOs are not needed to read
+			// catalog or bundle content.
+		}
+	}
+}
+
+// securePath joins name onto dir, rejecting entries that would escape
+// dir.
+func securePath(dir, name string) (string, error) {
+	dir = filepath.Clean(dir)
+	target := filepath.Join(dir, filepath.Clean("/"+name))
+	if target != dir && !strings.HasPrefix(target, dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("tar entry %q escapes destination", name)
+	}
+	return target, nil
+}
+
+// checkResolvedParent rejects entries whose parent directory resolves
+// outside dir through a symlink extracted from an earlier entry.
+func checkResolvedParent(dir, parent string) error {
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(parent)
+	if err != nil {
+		return err
+	}
+	if resolved != resolvedDir && !strings.HasPrefix(resolved, resolvedDir+string(filepath.Separator)) {
+		return fmt.Errorf("path %s escapes destination through a symlink", parent)
+	}
+	return nil
+}
+
+// writeFile writes the content of r to a new file at path.
+func writeFile(path string, r io.Reader, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
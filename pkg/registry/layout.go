@@ -0,0 +1,297 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// layoutTag is the tag of the image in a working layout.
+const layoutTag = "image"
+
+// Layout is a single image in an OCI layout directory, used to
+// assemble images without a container engine: start from a pulled
+// base image or from scratch, append layers, then push.
+type Layout struct {
+	dir string
+	ref types.ImageReference
+}
+
+func newLayout(dir string) (*Layout, error) {
+	ref, err := layout.NewReference(dir, layoutTag)
+	if err != nil {
+		return nil, fmt.Errorf("creating layout reference: %w", err)
+	}
+	return &Layout{dir: dir, ref: ref}, nil
+}
+
+// PullLayout copies the image for the given platform from src into a
+// new layout at dir, converting its manifest to the OCI format.
+func PullLayout(ctx context.Context, src, dir, osName, arch string) (*Layout, error) {
+	srcRef, err := ParseReference(src)
+	if err != nil {
+		return nil, err
+	}
+	l, err := newLayout(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sys := SystemContext(ctx)
+	sys.OSChoice = osName
+	sys.ArchitectureChoice = arch
+	if _, err := copyImage(ctx, l.ref, srcRef, sys, &types.SystemContext{}, imgspecv1.MediaTypeImageManifest); err != nil {
+		return nil, fmt.Errorf("pulling %s: %w", src, err)
+	}
+	return l, nil
+}
+
+// ScratchLayout creates a layout at dir holding an image with no
+// layers for the given platform.
+func ScratchLayout(dir string, platform imgspecv1.Platform) (*Layout, error) {
+	if err := os.MkdirAll(filepath.Join(dir, imgspecv1.ImageBlobsDir, "sha256"), 0755); err != nil {
+		return nil, fmt.Errorf("creating layout: %w", err)
+	}
+	if err := writeJSON(filepath.Join(dir, imgspecv1.ImageLayoutFile), imgspecv1.ImageLayout{Version: imgspecv1.ImageLayoutVersion}); err != nil {
+		return nil, fmt.Errorf("writing layout marker: %w", err)
+	}
+
+	l, err := newLayout(dir)
+	if err != nil {
+		return nil, err
+	}
+	config := imgspecv1.Image{
+		Platform: platform,
+		RootFS:   imgspecv1.RootFS{Type: "layers", DiffIDs: []digest.Digest{}},
+	}
+	m := imgspecv1.Manifest{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Layers:    []imgspecv1.Descriptor{},
+	}
+	if err := l.write(m, config); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// AppendLayer adds a gzip-compressed layer with the given
+// uncompressed digest on top of the image, then lets update adjust
+// the image config before it is written.
+func (l *Layout) AppendLayer(layer []byte, diffID digest.Digest, update func(*imgspecv1.Image)) error {
+	m, config, err := l.read()
+	if err != nil {
+		return err
+	}
+
+	layerDesc, err := l.writeBlob(imgspecv1.MediaTypeImageLayerGzip, layer)
+	if err != nil {
+		return err
+	}
+	m.Layers = append(m.Layers, layerDesc)
+	config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, diffID)
+	if update != nil {
+		update(config)
+	}
+	return l.write(*m, *config)
+}
+
+// Push copies the image to destination, a registry reference or a
+// local transport reference, and returns its manifest digest.
+func (l *Layout) Push(ctx context.Context, destination string) (digest.Digest, error) {
+	destRef, err := ParseReference(destination)
+	if err != nil {
+		return "", err
+	}
+	manifestBytes, err := copyImage(ctx, destRef, l.ref, &types.SystemContext{}, SystemContext(ctx), "")
+	if err != nil {
+		return "", fmt.Errorf("pushing %s: %w", destination, err)
+	}
+	d, err := manifest.Digest(manifestBytes)
+	if err != nil {
+		return "", fmt.Errorf("computing manifest digest: %w", err)
+	}
+	return d, nil
+}
+
+// copyImage copies an image, retrying under the policy carried by
+// ctx, and returns the manifest written.
+func copyImage(ctx context.Context, dest, src types.ImageReference, srcSys, destSys *types.SystemContext, manifestType string) ([]byte, error) {
+	policyContext, err := signature.NewPolicyContext(&signature.Policy{
+		Default: signature.PolicyRequirements{signature.NewPRInsecureAcceptAnything()},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating signature policy: %w", err)
+	}
+	defer policyContext.Destroy()
+
+	var manifestBytes []byte
+	err = Retry(ctx, func() error {
+		var err error
+		manifestBytes, err = copy.Image(ctx, policyContext, dest, src, &copy.Options{
+			SourceCtx:             srcSys,
+			DestinationCtx:        destSys,
+			ForceManifestMIMEType: manifestType,
+			RemoveSignatures:      true,
+		})
+		return err
+	})
+	return manifestBytes, err
+}
+
+// read returns the manifest and config of the layout's image.
+func (l *Layout) read() (*imgspecv1.Manifest, *imgspecv1.Image, error) {
+	var index imgspecv1.Index
+	if err := readJSON(filepath.Join(l.dir, imgspecv1.ImageIndexFile), &index); err != nil {
+		return nil, nil, fmt.Errorf("reading layout index: %w", err)
+	}
+	if len(index.Manifests) != 1 {
+		return nil, nil, fmt.Errorf("layout index has %d manifests, want 1", len(index.Manifests))
+	}
+
+	var m imgspecv1.Manifest
+	if err := readJSON(l.blobPath(index.Manifests[0].Digest), &m); err != nil {
+		return nil, nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	var config imgspecv1.Image
+	if err := readJSON(l.blobPath(m.Config.Digest), &config); err != nil {
+		return nil, nil, fmt.Errorf("reading image config: %w", err)
+	}
+	return &m, &config, nil
+}
+
+// write stores the config and manifest and makes the manifest the
+// layout's only image.
+func (l *Layout) write(m imgspecv1.Manifest, config imgspecv1.Image) error {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("marshalling image config: %w", err)
+	}
+	configDesc, err := l.writeBlob(imgspecv1.MediaTypeImageConfig, configJSON)
+	if err != nil {
+		return err
+	}
+
+	m.Config = configDesc
+	manifestJSON, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("marshalling manifest: %w", err)
+	}
+	manifestDesc, err := l.writeBlob(imgspecv1.MediaTypeImageManifest, manifestJSON)
+	if err != nil {
+		return err
+	}
+	manifestDesc.Annotations = map[string]string{imgspecv1.AnnotationRefName: layoutTag}
+
+	index := imgspecv1.Index{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageIndex,
+		Manifests: []imgspecv1.Descriptor{manifestDesc},
+	}
+	if err := writeJSON(filepath.Join(l.dir, imgspecv1.ImageIndexFile), index); err != nil {
+		return fmt.Errorf("writing layout index: %w", err)
+	}
+	return nil
+}
+
+// writeBlob stores data in the layout and returns its descriptor.
+func (l *Layout) writeBlob(mediaType string, data []byte) (imgspecv1.Descriptor, error) {
+	desc := imgspecv1.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(data), Size: int64(len(data))}
+	if err := os.WriteFile(l.blobPath(desc.Digest), data, 0644); err != nil {
+		return desc, fmt.Errorf("writing blob: %w", err)
+	}
+	return desc, nil
+}
+
+func (l *Layout) blobPath(d digest.Digest) string {
+	return filepath.Join(l.dir, imgspecv1.ImageBlobsDir, d.Algorithm().String(), d.Encoded())
+}
+
+// TarGzLayer returns a gzip-compressed tar layer holding files, keyed
+// by slash-separated path, and the digest of the uncompressed tar.
+// Parent directories are added, and entries are written in name
+// order with the given modification time, so that the same files
+// always give the same layer.
+func TarGzLayer(files map[string][]byte, modTime time.Time) ([]byte, digest.Digest, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	dirs := make(map[string]bool)
+	for _, name := range names {
+		var parents []string
+		for dir := path.Dir(name); dir != "." && dir != "/" && !dirs[dir]; dir = path.Dir(dir) {
+			parents = append([]string{dir}, parents...)
+		}
+		for _, dir := range parents {
+			dirs[dir] = true
+			if err := tw.WriteHeader(&tar.Header{Name: dir + "/", Mode: 0755, Typeflag: tar.TypeDir, ModTime: modTime}); err != nil {
+				return nil, "", fmt.Errorf("writing layer: %w", err)
+			}
+		}
+
+		data := files[name]
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+			ModTime:  modTime,
+		}); err != nil {
+			return nil, "", fmt.Errorf("writing layer: %w", err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, "", fmt.Errorf("writing layer: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, "", fmt.Errorf("writing layer: %w", err)
+	}
+
+	var gzBuf bytes.Buffer
+	gw := gzip.NewWriter(&gzBuf)
+	if _, err := gw.Write(tarBuf.Bytes()); err != nil {
+		return nil, "", fmt.Errorf("compressing layer: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return nil, "", fmt.Errorf("compressing layer: %w", err)
+	}
+	return gzBuf.Bytes(), digest.FromBytes(tarBuf.Bytes()), nil
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func writeJSON(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
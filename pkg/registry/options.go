@@ -0,0 +1,115 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/containers/image/v5/types"
+)
+
+// Options configures how container registries are accessed. A single
+// set of options is carried on the context so that every package
+// builds its SystemContext the same way.
+type Options struct {
+	AuthFile       string // Path to a containers-auth.json file
+	Creds          string // Credentials as username[:password]
+	RegistriesConf string // Path to a registries.conf file
+	TLSVerify      bool   // Require HTTPS and verify certificates
+	ContainerTool  string // Tool used to pull registry images: none, podman or docker
+	Retry          RetryPolicy
+}
+
+// DefaultOptions returns options that rely on the default auth file
+// lookup, verify TLS, pull images without a container engine and
+// retry transient failures with the default policy.
+func DefaultOptions() Options {
+	return Options{TLSVerify: true, ContainerTool: ContainerToolNone, Retry: DefaultRetryPolicy()}
+}
+
+// Validate checks that the options refer to usable files and are
+// internally consistent.
+func (o Options) Validate() error {
+	if o.AuthFile != "" {
+		if _, err := os.Stat(o.AuthFile); err != nil {
+			return fmt.Errorf("auth file %s: %w", o.AuthFile, err)
+		}
+	}
+	if o.RegistriesConf != "" {
+		if _, err := os.Stat(o.RegistriesConf); err != nil {
+			return fmt.Errorf("registries.conf %s: %w", o.RegistriesConf, err)
+		}
+	}
+	if o.Creds != "" && strings.HasPrefix(o.Creds, ":") {
+		return fmt.Errorf("credentials must be in the form username[:password]")
+	}
+	switch o.ContainerTool {
+	case "", ContainerToolNone, ContainerToolPodman, ContainerToolDocker:
+	default:
+		return fmt.Errorf("unsupported container tool %q (supported: none, podman, docker)", o.ContainerTool)
+	}
+	return o.Retry.Validate()
+}
+
+type optionsKey struct{}
+
+// WithOptions returns a context carrying registry access options.
+func WithOptions(ctx context.Context, opts Options) context.Context {
+	return context.WithValue(ctx, optionsKey{}, opts)
+}
+
+// OptionsFromContext returns the registry access options carried by
+// ctx, or DefaultOptions if none were set.
+func OptionsFromContext(ctx context.Context) Options {
+	if opts, ok := ctx.Value(optionsKey{}).(Options); ok {
+		return opts
+	}
+	return DefaultOptions()
+}
+
+// SystemContext builds a containers/image SystemContext from the
+// registry access options carried by ctx. A new value is returned on
+// every call so callers may adjust it, for example to select a
+// platform.
+func SystemContext(ctx context.Context) *types.SystemContext {
+	opts := OptionsFromContext(ctx)
+
+	sys := &types.SystemContext{
+		AuthFilePath:             opts.AuthFile,
+		SystemRegistriesConfPath: opts.RegistriesConf,
+	}
+
+	if opts.Creds != "" {
+		username, password, _ := strings.Cut(opts.Creds, ":")
+		sys.DockerAuthConfig = &types.DockerAuthConfig{
+			Username: username,
+			Password: password,
+		}
+	}
+
+	if !opts.TLSVerify {
+		sys.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
+		sys.OCIInsecureSkipTLSVerify = true
+		sys.DockerDaemonInsecureSkipTLSVerify = true
+	}
+
+	return sys
+}
+
+// ExportEnvironment exports the auth file and registries.conf
+// locations so that podman, skopeo and other containers tools run as
+// subprocesses use the same configuration.
+func ExportEnvironment(opts Options) error {
+	if opts.AuthFile != "" {
+		if err := os.Setenv("REGISTRY_AUTH_FILE", opts.AuthFile); err != nil {
+			return fmt.Errorf("setting REGISTRY_AUTH_FILE: %w", err)
+		}
+	}
+	if opts.RegistriesConf != "" {
+		if err := os.Setenv("CONTAINERS_REGISTRIES_CONF", opts.RegistriesConf); err != nil {
+			return fmt.Errorf("setting CONTAINERS_REGISTRIES_CONF: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package registry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	authFile := filepath.Join(t.TempDir(), "auth.json")
+	if err := os.WriteFile(authFile, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	tests := []struct {
+		name    string
+		modify  func(*Options)
+		wantErr bool
+	}{
+		{name: "defaults", modify: func(*Options) {}},
+		{name: "auth file", modify: func(o *Options) { o.AuthFile = authFile }},
+		{name: "missing auth file", modify: func(o *Options) { o.AuthFile = missing }, wantErr: true},
+		{name: "registries.conf", modify: func(o *Options) { o.RegistriesConf = authFile }},
+		{name: "missing registries.conf", modify: func(o *Options) { o.RegistriesConf = missing }, wantErr: true},
+		{name: "username only", modify: func(o *Options) { o.Creds = "user" }},
+		{name: "username and password", modify: func(o *Options) { o.Creds = "user:pass" }},
+		{name: "password only", modify: func(o *Options) { o.Creds = ":pass" }, wantErr: true},
+		{name: "podman", modify: func(o *Options) { o.ContainerTool = ContainerToolPodman }},
+		{name: "docker", modify: func(o *Options) { o.ContainerTool = ContainerToolDocker }},
+		{name: "unset container tool", modify: func(o *Options) { o.ContainerTool = "" }},
+		{name: "unknown container tool", modify: func(o *Options) { o.ContainerTool = "buildah" }, wantErr: true},
+		{name: "invalid retry policy", modify: func(o *Options) { o.Retry.MaxAttempts = 0 }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			tt.modify(&opts)
+			err := opts.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSystemContext(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want *types.SystemContext
+	}{
+		{
+			name: "defaults",
+			opts: DefaultOptions(),
+			want: &types.SystemContext{},
+		},
+		{
+			name: "files",
+			opts: Options{AuthFile: "/auth.json", RegistriesConf: "/registries.conf", TLSVerify: true},
+			want: &types.SystemContext{AuthFilePath: "/auth.json", SystemRegistriesConfPath: "/registries.conf"},
+		},
+		{
+			name: "credentials",
+			opts: Options{Creds: "user:pa:ss", TLSVerify: true},
+			want: &types.SystemContext{DockerAuthConfig: &types.DockerAuthConfig{Username: "user", Password: "pa:ss"}},
+		},
+		{
+			name: "username only",
+			opts: Options{Creds: "user", TLSVerify: true},
+			want: &types.SystemContext{DockerAuthConfig: &types.DockerAuthConfig{Username: "user"}},
+		},
+		{
+			name: "insecure",
+			opts: Options{},
+			want: &types.SystemContext{
+				DockerInsecureSkipTLSVerify:       types.OptionalBoolTrue,
+				OCIInsecureSkipTLSVerify:          true,
+				DockerDaemonInsecureSkipTLSVerify: true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SystemContext(WithOptions(context.Background(), tt.opts))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SystemContext = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := SystemContext(context.Background()); !reflect.DeepEqual(got, &types.SystemContext{}) {
+		t.Errorf("SystemContext without options = %+v, want defaults", got)
+	}
+}
@@ -0,0 +1,110 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+
+	// Register the transports accepted by ParseReference.
+	_ "github.com/containers/image/v5/directory"
+	_ "github.com/containers/image/v5/docker/archive"
+	_ "github.com/containers/image/v5/oci/archive"
+	_ "github.com/containers/image/v5/oci/layout"
+)
+
+// Transport names accepted in image references.
+const (
+	TransportDocker        = "docker"
+	TransportOCI           = "oci"
+	TransportOCIArchive    = "oci-archive"
+	TransportDockerArchive = "docker-archive"
+	TransportDir           = "dir"
+)
+
+// localTransports are transports that refer to images on the local
+// filesystem rather than in a registry.
+var localTransports = []string{
+	TransportOCI,
+	TransportOCIArchive,
+	TransportDockerArchive,
+	TransportDir,
+}
+
+// SplitTransport splits an image reference into its transport and
+// the transport-specific remainder. References without a recognised
+// local transport prefix use the docker transport, with any
+// "docker://" prefix removed.
+func SplitTransport(ref string) (string, string) {
+	for _, name := range localTransports {
+		if rest, ok := strings.CutPrefix(ref, name+":"); ok {
+			return name, rest
+		}
+	}
+	return TransportDocker, strings.TrimPrefix(ref, "docker://")
+}
+
+// IsLocal reports whether ref uses a transport that reads from the
+// local filesystem, such as an OCI layout or an archive.
+func IsLocal(ref string) bool {
+	transport, _ := SplitTransport(ref)
+	return transport != TransportDocker
+}
+
+// ParseReference parses an image reference that may carry a
+// transport prefix (oci:, oci-archive:, docker-archive:, dir: or
+// docker://). References without a prefix are registry references.
+func ParseReference(ref string) (types.ImageReference, error) {
+	transport, rest := SplitTransport(ref)
+	if transport == TransportDocker {
+		imgRef, err := docker.ParseReference("//" + rest)
+		if err != nil {
+			return nil, fmt.Errorf("parsing reference %s: %w", ref, err)
+		}
+		return imgRef, nil
+	}
+
+	t := transports.Get(transport)
+	if t == nil {
+		return nil, fmt.Errorf("unsupported transport %q in %s", transport, ref)
+	}
+	imgRef, err := t.ParseReference(rest)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s reference %s: %w", transport, ref, err)
+	}
+	return imgRef, nil
+}
+
+// Digest returns the digest of the manifest ref points at, which is
+// the manifest list for multi-arch images, using the registry options
+// carried by ctx.
+func Digest(ctx context.Context, ref string) (digest.Digest, error) {
+	imgRef, err := ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+
+	var manifestBlob []byte
+	err = Retry(ctx, func() error {
+		src, err := imgRef.NewImageSource(ctx, SystemContext(ctx))
+		if err != nil {
+			return fmt.Errorf("creating image source: %w", err)
+		}
+		defer src.Close()
+
+		manifestBlob, _, err = src.GetManifest(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("getting manifest: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return manifest.Digest(manifestBlob)
+}
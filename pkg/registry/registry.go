@@ -0,0 +1,146 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/operator-framework/operator-registry/pkg/containertools"
+	"github.com/operator-framework/operator-registry/pkg/image"
+	"github.com/operator-framework/operator-registry/pkg/image/execregistry"
+	"github.com/sirupsen/logrus"
+)
+
+// Container tools that can be selected to pull and unpack registry
+// images.
+const (
+	ContainerToolNone   = "none"
+	ContainerToolPodman = "podman"
+	ContainerToolDocker = "docker"
+)
+
+// New returns the image.Registry used to pull and unpack bundle and
+// catalog images. By default images are pulled with containers/image
+// into a temporary directory that is removed by Destroy, so no
+// container engine is needed. When the options carried by ctx select
+// podman or docker, registry images are handled by that tool instead,
+// with docker standing in for podman where only docker is installed;
+// images referenced through a local transport (OCI layouts, archives
+// and directories) are always read with containers/image.
+func New(ctx context.Context, logger *logrus.Entry) (image.Registry, error) {
+	local, err := NewImageRegistry(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name := OptionsFromContext(ctx).ContainerTool
+	if name == "" || name == ContainerToolNone {
+		return local, nil
+	}
+	tool, err := containerTool(name, logger)
+	if err != nil {
+		local.Destroy()
+		return nil, err
+	}
+
+	runner, err := execregistry.NewRegistry(tool, logger)
+	if err != nil {
+		local.Destroy()
+		return nil, fmt.Errorf("creating %s registry: %w", tool, err)
+	}
+
+	var remote image.Registry = runner
+	if args := pullArgs(OptionsFromContext(ctx)); len(args) > 0 {
+		if tool != containertools.PodmanTool {
+			local.Destroy()
+			return nil, fmt.Errorf("%s is not supported with %s; configure %s itself instead", args[0], tool, tool)
+		}
+		remote = &podmanRegistry{Registry: runner, args: args, logger: logger}
+	}
+
+	return &routingRegistry{local: local, remote: remote}, nil
+}
+
+// pullArgs returns the podman pull flags that apply opts, which
+// execregistry has no way to pass. docker pull has no equivalent of
+// any of them.
+func pullArgs(opts Options) []string {
+	var args []string
+	if opts.AuthFile != "" {
+		args = append(args, "--authfile", opts.AuthFile)
+	}
+	if !opts.TLSVerify {
+		args = append(args, "--tls-verify=false")
+	}
+	if opts.Creds != "" {
+		args = append(args, "--creds", opts.Creds)
+	}
+	return args
+}
+
+// containerTool returns the container tool called name, using docker
+// in place of podman if only docker is installed.
+func containerTool(name string, logger *logrus.Entry) (containertools.ContainerTool, error) {
+	switch name {
+	case ContainerToolPodman:
+		if _, err := exec.LookPath(ContainerToolPodman); err != nil {
+			if _, err := exec.LookPath(ContainerToolDocker); err == nil {
+				logger.Warn("podman not found, using docker")
+				return containertools.DockerTool, nil
+			}
+		}
+		return containertools.PodmanTool, nil
+	case ContainerToolDocker:
+		return containertools.DockerTool, nil
+	}
+	return containertools.NoneTool, fmt.Errorf("unsupported container tool %q", name)
+}
+
+// podmanRegistry is a podman registry that pulls with the auth
+// file, TLS and credential options; see pullArgs.
+type podmanRegistry struct {
+	*execregistry.Registry
+	args   []string
+	logger *logrus.Entry
+}
+
+func (r *podmanRegistry) Pull(ctx context.Context, ref image.Reference) error {
+	args := append(append([]string{"pull"}, r.args...), ref.String())
+	cmd := exec.CommandContext(ctx, ContainerToolPodman, args...)
+	r.logger.Infof("running podman pull %s", ref)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("pulling %s with podman: %s: %w", ref, out, err)
+	}
+	return nil
+}
+
+// routingRegistry sends local transport references to one registry
+// and everything else to another.
+type routingRegistry struct {
+	local  image.Registry
+	remote image.Registry
+}
+
+func (r *routingRegistry) route(ref image.Reference) image.Registry {
+	if IsLocal(ref.String()) {
+		return r.local
+	}
+	return r.remote
+}
+
+func (r *routingRegistry) Pull(ctx context.Context, ref image.Reference) error {
+	return r.route(ref).Pull(ctx, ref)
+}
+
+func (r *routingRegistry) Unpack(ctx context.Context, ref image.Reference, dir string) error {
+	return r.route(ref).Unpack(ctx, ref, dir)
+}
+
+func (r *routingRegistry) Labels(ctx context.Context, ref image.Reference) (map[string]string, error) {
+	return r.route(ref).Labels(ctx, ref)
+}
+
+func (r *routingRegistry) Destroy() error {
+	return errors.Join(r.local.Destroy(), r.remote.Destroy())
+}
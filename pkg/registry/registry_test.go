@@ -0,0 +1,205 @@
+package registry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openshift/bpfman-catalog/pkg/registry/registrytest"
+	"github.com/operator-framework/operator-registry/pkg/containertools"
+	"github.com/operator-framework/operator-registry/pkg/image"
+	"github.com/sirupsen/logrus"
+)
+
+func TestSplitTransport(t *testing.T) {
+	tests := []struct {
+		ref       string
+		transport string
+		rest      string
+	}{
+		{"quay.io/org/repo:tag", TransportDocker, "quay.io/org/repo:tag"},
+		{"docker://quay.io/org/repo:tag", TransportDocker, "quay.io/org/repo:tag"},
+		{"oci:/srv/layout:v1", TransportOCI, "/srv/layout:v1"},
+		{"oci-archive:/srv/bundle.tar", TransportOCIArchive, "/srv/bundle.tar"},
+		{"docker-archive:/srv/bundle.tar:quay.io/org/repo:tag", TransportDockerArchive, "/srv/bundle.tar:quay.io/org/repo:tag"},
+		{"dir:/srv/bundle", TransportDir, "/srv/bundle"},
+		{"localhost:5000/repo:tag", TransportDocker, "localhost:5000/repo:tag"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			transport, rest := SplitTransport(tt.ref)
+			if transport != tt.transport || rest != tt.rest {
+				t.Errorf("SplitTransport(%q) = (%q, %q), want (%q, %q)", tt.ref, transport, rest, tt.transport, tt.rest)
+			}
+		})
+	}
+}
+
+// TestRegistryOCILayout pulls and unpacks a bundle image from an OCI
+// layout without a registry or container engine.
+func TestRegistryOCILayout(t *testing.T) {
+	layoutDir := t.TempDir()
+	registrytest.WriteOCILayout(t, layoutDir, "v1", registrytest.Image{
+		Files: map[string]string{
+			"manifests/bpfman-config_v1_configmap.yaml": "kind: ConfigMap\n",
+			"metadata/annotations.yaml":                 "annotations: {}\n",
+		},
+		Labels: map[string]string{"version": "0.6.0"},
+	})
+
+	ctx := context.Background()
+	reg, err := NewImageRegistry(ctx)
+	if err != nil {
+		t.Fatalf("NewImageRegistry: %v", err)
+	}
+	defer reg.Destroy()
+
+	ref := image.SimpleReference("oci:" + layoutDir + ":v1")
+	if err := reg.Pull(ctx, ref); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+
+	labels, err := reg.Labels(ctx, ref)
+	if err != nil {
+		t.Fatalf("Labels: %v", err)
+	}
+	if labels["version"] != "0.6.0" {
+		t.Errorf("version label = %q, want %q", labels["version"], "0.6.0")
+	}
+
+	unpackDir := t.TempDir()
+	if err := reg.Unpack(ctx, ref, unpackDir); err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(unpackDir, "manifests", "bpfman-config_v1_configmap.yaml"))
+	if err != nil {
+		t.Fatalf("reading unpacked file: %v", err)
+	}
+	if string(data) != "kind: ConfigMap\n" {
+		t.Errorf("unpacked content = %q", data)
+	}
+
+	if err := reg.Destroy(); err != nil {
+		t.Fatalf("Destroy: %v", err)
+	}
+	if _, err := os.Stat(reg.cacheDir); !os.IsNotExist(err) {
+		t.Errorf("cache directory %s still exists after Destroy", reg.cacheDir)
+	}
+}
+
+func TestNewSelectsContainerTool(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+
+	tests := []struct {
+		tool    string
+		routing bool
+		wantErr bool
+	}{
+		{tool: "", routing: false},
+		{tool: ContainerToolNone, routing: false},
+		{tool: ContainerToolPodman, routing: true},
+		{tool: ContainerToolDocker, routing: true},
+		{tool: "buildah", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.ContainerTool = tt.tool
+			reg, err := New(WithOptions(context.Background(), opts), logger)
+			if tt.wantErr {
+				if err == nil {
+					reg.Destroy()
+					t.Fatalf("expected error for container tool %q", tt.tool)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			defer reg.Destroy()
+
+			_, routing := reg.(*routingRegistry)
+			if routing != tt.routing {
+				t.Errorf("container tool %q: got %T", tt.tool, reg)
+			}
+		})
+	}
+}
+
+func TestContainerToolFallsBackToDocker(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	if tool, err := containerTool(ContainerToolPodman, logger); err != nil || tool != containertools.PodmanTool {
+		t.Errorf("no tools installed: got %v, %v; want podman", tool, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ContainerToolDocker), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if tool, err := containerTool(ContainerToolPodman, logger); err != nil || tool != containertools.DockerTool {
+		t.Errorf("only docker installed: got %v, %v; want docker", tool, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ContainerToolPodman), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if tool, err := containerTool(ContainerToolPodman, logger); err != nil || tool != containertools.PodmanTool {
+		t.Errorf("both installed: got %v, %v; want podman", tool, err)
+	}
+}
+
+// TestPodmanPullsWithOptions checks that --authfile, --tls-verify and
+// --creds reach podman pull, and that docker, which cannot take
+// them, is refused.
+func TestPodmanPullsWithOptions(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	argsFile := filepath.Join(dir, "args")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\n"
+	if err := os.WriteFile(filepath.Join(dir, ContainerToolPodman), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.ContainerTool = ContainerToolPodman
+	opts.Creds = "user:secret"
+	opts.AuthFile = "/run/auth.json"
+	opts.TLSVerify = false
+	reg, err := New(WithOptions(context.Background(), opts), logger)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer reg.Destroy()
+
+	if err := reg.Pull(context.Background(), image.SimpleReference("quay.io/bpfman/bundle:latest")); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(args), "pull --authfile /run/auth.json --tls-verify=false --creds user:secret quay.io/bpfman/bundle:latest\n"; got != want {
+		t.Errorf("podman args = %q, want %q", got, want)
+	}
+
+	for name, o := range map[string]Options{
+		"--authfile":   {AuthFile: "/run/auth.json", TLSVerify: true},
+		"--tls-verify": {},
+		"--creds":      {Creds: "user:secret", TLSVerify: true},
+	} {
+		o.ContainerTool = ContainerToolDocker
+		if reg, err := New(WithOptions(context.Background(), o), logger); err == nil {
+			reg.Destroy()
+			t.Errorf("expected an error for %s with docker", name)
+		} else if !strings.Contains(err.Error(), name) {
+			t.Errorf("error = %v, want one naming %s", err, name)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Package registrytest provides helpers for building container images
+// in tests without a registry or container engine.
+package registrytest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Image describes a single-layer image to write.
+type Image struct {
+	Files    map[string]string  // File path to content
+	Labels   map[string]string  // Config labels
+	Platform imgspecv1.Platform // Config platform (default: linux/amd64)
+}
+
+// blob is a content-addressed blob of an image.
+type blob struct {
+	desc imgspecv1.Descriptor
+	data []byte
+}
+
+// build returns the manifest of img and the config and layer blobs it
+// references.
+func (img Image) build(t testing.TB) (blob, []blob) {
+	t.Helper()
+
+	layer, diffID := LayerTarGz(t, img.Files)
+	layerBlob := newBlob(imgspecv1.MediaTypeImageLayerGzip, layer)
+
+	platform := img.Platform
+	if platform.OS == "" {
+		platform = imgspecv1.Platform{Architecture: "amd64", OS: "linux"}
+	}
+	config := imgspecv1.Image{
+		Platform: platform,
+		Config:   imgspecv1.ImageConfig{Labels: img.Labels},
+		RootFS:   imgspecv1.RootFS{Type: "layers", DiffIDs: []digest.Digest{diffID}},
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("marshalling config: %v", err)
+	}
+	configBlob := newBlob(imgspecv1.MediaTypeImageConfig, configJSON)
+
+	manifest := imgspecv1.Manifest{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Config:    configBlob.desc,
+		Layers:    []imgspecv1.Descriptor{layerBlob.desc},
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("marshalling manifest: %v", err)
+	}
+
+	return newBlob(imgspecv1.MediaTypeImageManifest, manifestJSON), []blob{configBlob, layerBlob}
+}
+
+func newBlob(mediaType string, data []byte) blob {
+	return blob{
+		desc: imgspecv1.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(data), Size: int64(len(data))},
+		data: data,
+	}
+}
+
+// WriteOCILayout adds img to the OCI layout in dir under the given
+// tag, creating the layout if needed, and returns the manifest
+// digest.
+func WriteOCILayout(t testing.TB, dir, tag string, img Image) digest.Digest {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Join(dir, imgspecv1.ImageBlobsDir, "sha256"), 0755); err != nil {
+		t.Fatalf("creating layout: %v", err)
+	}
+	layoutMarker, _ := json.Marshal(imgspecv1.ImageLayout{Version: imgspecv1.ImageLayoutVersion})
+	writeFile(t, filepath.Join(dir, imgspecv1.ImageLayoutFile), layoutMarker)
+
+	manifest, blobs := img.build(t)
+	for _, b := range append(blobs, manifest) {
+		writeBlob(t, dir, b)
+	}
+	manifestDesc := manifest.desc
+	manifestDesc.Annotations = map[string]string{imgspecv1.AnnotationRefName: tag}
+
+	index := imgspecv1.Index{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageIndex,
+	}
+	indexPath := filepath.Join(dir, imgspecv1.ImageIndexFile)
+	if data, err := os.ReadFile(indexPath); err == nil {
+		if err := json.Unmarshal(data, &index); err != nil {
+			t.Fatalf("parsing index: %v", err)
+		}
+	}
+	index.Manifests = append(index.Manifests, manifestDesc)
+	indexJSON, err := json.Marshal(index)
+	if err != nil {
+		t.Fatalf("marshalling index: %v", err)
+	}
+	writeFile(t, indexPath, indexJSON)
+
+	return manifestDesc.Digest
+}
+
+// LayerTarGz returns a gzip-compressed tar layer holding files, and
+// the digest of the uncompressed tar.
+func LayerTarGz(t testing.TB, files map[string]string) ([]byte, digest.Digest) {
+	t.Helper()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	for _, name := range names {
+		content := files[name]
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			t.Fatalf("writing tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("writing tar content: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("closing tar: %v", err)
+	}
+
+	var gzBuf bytes.Buffer
+	gw := gzip.NewWriter(&gzBuf)
+	if _, err := gw.Write(tarBuf.Bytes()); err != nil {
+		t.Fatalf("compressing layer: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("closing gzip: %v", err)
+	}
+
+	return gzBuf.Bytes(), digest.FromBytes(tarBuf.Bytes())
+}
+
+func writeBlob(t testing.TB, dir string, b blob) {
+	t.Helper()
+	d := b.desc.Digest
+	writeFile(t, filepath.Join(dir, imgspecv1.ImageBlobsDir, d.Algorithm().String(), d.Encoded()), b.data)
+}
+
+func writeFile(t testing.TB, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+}
@@ -0,0 +1,303 @@
+package registrytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// Server is an in-process registry serving the distribution API (tag
+// listing, manifests and blobs, and monolithic or chunked pushes) over
+// TLS. Clients must skip TLS verification.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	manifests map[string]map[string]blob // repository -> tag or digest -> manifest
+	blobs     map[digest.Digest]blob
+	uploads   map[string][]byte // upload session -> data received so far
+	nextID    int
+	requests  []string
+	failures  []*failure
+}
+
+// failure is an injected error response.
+type failure struct {
+	match  string
+	status int
+	count  int
+}
+
+// NewServer starts a registry that is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	s := &Server{
+		manifests: make(map[string]map[string]blob),
+		blobs:     make(map[digest.Digest]blob),
+		uploads:   make(map[string][]byte),
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Host returns the registry host and port, for use in image
+// references.
+func (s *Server) Host() string {
+	return strings.TrimPrefix(s.URL, "https://")
+}
+
+// Push stores img in repository under tag and returns the manifest
+// digest.
+func (s *Server) Push(t testing.TB, repository, tag string, img Image) digest.Digest {
+	t.Helper()
+
+	manifest, blobs := img.build(t)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range blobs {
+		s.blobs[b.desc.Digest] = b
+	}
+	if s.manifests[repository] == nil {
+		s.manifests[repository] = make(map[string]blob)
+	}
+	s.manifests[repository][tag] = manifest
+	s.manifests[repository][manifest.desc.Digest.String()] = manifest
+	return manifest.desc.Digest
+}
+
+// Manifest returns the manifest stored in repository under a tag or
+// digest, and its media type.
+func (s *Server) Manifest(repository, ref string) ([]byte, string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.manifests[repository][ref]
+	return b.data, b.desc.MediaType, ok
+}
+
+// Blob returns the content of a stored blob.
+func (s *Server) Blob(d digest.Digest) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.blobs[d]
+	return b.data, ok
+}
+
+// Requests returns the requests served so far, as "METHOD path".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// ResetRequests clears the record of requests served.
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+// InjectFailures makes the next count requests whose path contains
+// match fail with status. Rate-limit responses ask the client to
+// retry immediately with "Retry-After: 0". A negative count fails
+// every matching request.
+func (s *Server) InjectFailures(match string, status, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failure{match: match, status: status, count: count})
+}
+
+// injectedFailure returns the status of an injected failure matching
+// path, or 0 if the request should be served. s.mu must be held.
+func (s *Server) injectedFailure(path string) int {
+	for _, f := range s.failures {
+		if f.count == 0 || !strings.Contains(path, f.match) {
+			continue
+		}
+		if f.count > 0 {
+			f.count--
+		}
+		return f.status
+	}
+	return 0
+}
+
+var (
+	routePattern  = regexp.MustCompile(`^/v2/(.+)/(tags/list|manifests/[^/]+|blobs/[^/]+)$`)
+	uploadPattern = regexp.MustCompile(`^/v2/(.+)/blobs/uploads/([^/]*)$`)
+)
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	status := s.injectedFailure(r.URL.Path)
+	s.mu.Unlock()
+
+	if status != 0 {
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "0")
+		}
+		w.WriteHeader(status)
+		return
+	}
+
+	if r.URL.Path == "/v2/" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if m := uploadPattern.FindStringSubmatch(r.URL.Path); m != nil {
+		s.serveUpload(w, r, m[1], m[2])
+		return
+	}
+
+	m := routePattern.FindStringSubmatch(r.URL.Path)
+	if m != nil && r.Method == http.MethodPut && strings.HasPrefix(m[2], "manifests/") {
+		s.putManifest(w, r, m[1], strings.TrimPrefix(m[2], "manifests/"))
+		return
+	}
+	if m == nil || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		http.NotFound(w, r)
+		return
+	}
+	repository, route := m[1], m[2]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case route == "tags/list":
+		manifests, ok := s.manifests[repository]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		tags := []string{}
+		for ref := range manifests {
+			if _, err := digest.Parse(ref); err != nil {
+				tags = append(tags, ref)
+			}
+		}
+		sort.Strings(tags)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"name": repository, "tags": tags})
+
+	case strings.HasPrefix(route, "manifests/"):
+		manifest, ok := s.manifests[repository][strings.TrimPrefix(route, "manifests/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		serveBlob(w, r, manifest)
+
+	default:
+		b, ok := s.blobs[digest.Digest(strings.TrimPrefix(route, "blobs/"))]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		serveBlob(w, r, b)
+	}
+}
+
+func serveBlob(w http.ResponseWriter, r *http.Request, b blob) {
+	w.Header().Set("Content-Type", b.desc.MediaType)
+	w.Header().Set("Docker-Content-Digest", b.desc.Digest.String())
+	w.Header().Set("Content-Length", strconv.FormatInt(b.desc.Size, 10))
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(b.data)
+}
+
+// serveUpload handles the blob upload session protocol: POST starts a
+// session, PATCH appends data and PUT appends any remaining data and
+// completes the upload under the given digest.
+func (s *Server) serveUpload(w http.ResponseWriter, r *http.Request, repository, id string) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && id == "":
+		s.nextID++
+		id = strconv.Itoa(s.nextID)
+		s.uploads[id] = data
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", repository, id))
+		w.Header().Set("Docker-Upload-UUID", id)
+		w.Header().Set("Range", "0-0")
+		w.WriteHeader(http.StatusAccepted)
+
+	case r.Method == http.MethodPatch:
+		received, ok := s.uploads[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		received = append(received, data...)
+		s.uploads[id] = received
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", repository, id))
+		w.Header().Set("Docker-Upload-UUID", id)
+		w.Header().Set("Range", fmt.Sprintf("0-%d", len(received)-1))
+		w.WriteHeader(http.StatusAccepted)
+
+	case r.Method == http.MethodPut:
+		received, ok := s.uploads[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		delete(s.uploads, id)
+		received = append(received, data...)
+		want, err := digest.Parse(r.URL.Query().Get("digest"))
+		if err != nil || digest.FromBytes(received) != want {
+			http.Error(w, "digest mismatch", http.StatusBadRequest)
+			return
+		}
+		s.blobs[want] = newBlob("application/octet-stream", received)
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", repository, want))
+		w.Header().Set("Docker-Content-Digest", want.String())
+		w.WriteHeader(http.StatusCreated)
+
+	default:
+		http.Error(w, "unsupported upload request", http.StatusMethodNotAllowed)
+	}
+}
+
+// putManifest stores a pushed manifest under ref and its digest.
+func (s *Server) putManifest(w http.ResponseWriter, r *http.Request, repository, ref string) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	manifest := newBlob(r.Header.Get("Content-Type"), data)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.manifests[repository] == nil {
+		s.manifests[repository] = make(map[string]blob)
+	}
+	s.manifests[repository][ref] = manifest
+	s.manifests[repository][manifest.desc.Digest.String()] = manifest
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/manifests/%s", repository, manifest.desc.Digest))
+	w.Header().Set("Docker-Content-Digest", manifest.desc.Digest.String())
+	w.WriteHeader(http.StatusCreated)
+}
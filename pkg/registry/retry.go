@@ -0,0 +1,180 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"regexp"
+	"syscall"
+	"time"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/docker/distribution/registry/api/errcode"
+)
+
+// RetryPolicy controls how registry operations are retried after
+// rate limiting (HTTP 429) and transient server or network failures.
+// Delays grow exponentially from InitialBackoff up to MaxBackoff,
+// with up to Jitter (a fraction of the delay) added or removed at
+// random so that concurrent requests do not retry in lockstep.
+// containers/image already waits out a registry's Retry-After on
+// each request before reporting rate limiting.
+type RetryPolicy struct {
+	MaxAttempts    int           // Total attempts including the first (1 disables retries)
+	InitialBackoff time.Duration // Delay before the first retry
+	MaxBackoff     time.Duration // Upper bound on any single delay
+	Jitter         float64       // Random variation of each delay, from 0 to 1
+}
+
+// DefaultRetryPolicy returns the policy used when none is configured.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		Jitter:         0.2,
+	}
+}
+
+// Validate checks that the policy can be applied.
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("retry attempts must be at least 1, got %d", p.MaxAttempts)
+	}
+	if p.InitialBackoff < 0 || p.MaxBackoff < 0 {
+		return fmt.Errorf("retry backoff must not be negative")
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("retry jitter must be between 0 and 1, got %g", p.Jitter)
+	}
+	return nil
+}
+
+// backoff returns the delay before retry number n (starting at 1).
+func (p RetryPolicy) backoff(n int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < n && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if p.Jitter > 0 && delay > 0 {
+		delta := (rand.Float64()*2 - 1) * p.Jitter * float64(delay)
+		delay += time.Duration(delta)
+	}
+	return delay
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500
+}
+
+// unparsedStatusPattern matches errors containers/image reports when
+// a registry error response has no parsable body, which carry the
+// status code only in their message.
+var unparsedStatusPattern = regexp.MustCompile(`(HTTP|StatusCode:) (429|408|5\d\d)\b`)
+
+// unparsedStatusRetryable reports whether err, or any error it wraps,
+// describes a retryable status in its message.
+func unparsedStatusRetryable(err error) bool {
+	for err != nil {
+		if unparsedStatusPattern.MatchString(err.Error()) {
+			return true
+		}
+		if multi, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range multi.Unwrap() {
+				if unparsedStatusRetryable(e) {
+					return true
+				}
+			}
+			return false
+		}
+		err = errors.Unwrap(err)
+	}
+	return false
+}
+
+// IsRetryable reports whether err is a rate-limit, transient server
+// or network failure that may succeed if the operation is repeated.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, docker.ErrTooManyRequests) {
+		return true
+	}
+
+	var statusErr docker.UnexpectedHTTPStatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.StatusCode)
+	}
+
+	var codeErrs errcode.Errors
+	if errors.As(err, &codeErrs) {
+		for _, e := range codeErrs {
+			if IsRetryable(e) {
+				return true
+			}
+		}
+		return false
+	}
+	var codeErr errcode.Error
+	if errors.As(err, &codeErr) {
+		return retryableStatus(codeErr.Code.Descriptor().HTTPStatusCode)
+	}
+	var code errcode.ErrorCode
+	if errors.As(err, &code) {
+		return retryableStatus(code.Descriptor().HTTPStatusCode)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	return unparsedStatusRetryable(err)
+}
+
+// Retry runs op until it succeeds, fails with an error that is not
+// retryable, or the attempts allowed by the policy carried by ctx are
+// used up. The last error is returned, annotated with the number of
+// attempts when more than one was made.
+func Retry(ctx context.Context, op func() error) error {
+	return OptionsFromContext(ctx).Retry.Do(ctx, op)
+}
+
+// Do runs op under the policy; see Retry.
+func (p RetryPolicy) Do(ctx context.Context, op func() error) error {
+	maxAttempts := max(p.MaxAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil {
+			return nil
+		}
+		if attempt >= maxAttempts || !IsRetryable(err) {
+			if attempt > 1 {
+				return fmt.Errorf("after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
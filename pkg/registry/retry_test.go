@@ -0,0 +1,125 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/openshift/bpfman-catalog/pkg/registry/registrytest"
+	"github.com/operator-framework/operator-registry/pkg/image"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"cancelled", fmt.Errorf("fetching: %w", context.Canceled), false},
+		{"too many requests", fmt.Errorf("listing tags: %w", docker.ErrTooManyRequests), true},
+		{"server error", docker.UnexpectedHTTPStatusError{StatusCode: http.StatusBadGateway}, true},
+		{"not found", docker.UnexpectedHTTPStatusError{StatusCode: http.StatusNotFound}, false},
+		{"rate limit code", errcode.Errors{errcode.ErrorCodeTooManyRequests.WithMessage("slow down")}, true},
+		{"unauthorized code", errcode.ErrorCodeUnauthorized.WithMessage("denied"), false},
+		{"unparsed 429 body", fmt.Errorf("reading manifest: %w", errors.New(`StatusCode: 429, ""`)), true},
+		{"unparsed 404 body", errors.New(`StatusCode: 404, ""`), false},
+		{"reset", fmt.Errorf("reading blob: %w", syscall.ECONNRESET), true},
+		{"truncated", fmt.Errorf("reading blob: %w", io.ErrUnexpectedEOF), true},
+		{"end of stream", io.EOF, false},
+		{"plain", errors.New("manifest unknown"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Jitter: 0.2}
+	for n, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		got := p.backoff(n)
+		lo, hi := time.Duration(float64(want)*0.8), time.Duration(float64(want)*1.2)
+		if got < lo || got > hi {
+			t.Errorf("backoff(%d) = %v, want within [%v, %v]", n, got, lo, hi)
+		}
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	ctx := context.Background()
+
+	attempts := 0
+	err := p.Do(ctx, func() error {
+		attempts++
+		if attempts < 3 {
+			return docker.ErrTooManyRequests
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("Do recovered after %d attempts with %v, want 3 attempts and no error", attempts, err)
+	}
+
+	attempts = 0
+	err = p.Do(ctx, func() error {
+		attempts++
+		return docker.ErrTooManyRequests
+	})
+	if attempts != 3 || !errors.Is(err, docker.ErrTooManyRequests) {
+		t.Errorf("Do gave up after %d attempts with %v, want 3 attempts", attempts, err)
+	}
+
+	attempts = 0
+	notFound := errors.New("manifest unknown")
+	err = p.Do(ctx, func() error {
+		attempts++
+		return notFound
+	})
+	if attempts != 1 || err != notFound {
+		t.Errorf("Do made %d attempts for a permanent error (%v), want 1", attempts, err)
+	}
+}
+
+// TestPullRetriesTransientFailures pulls an image through server
+// errors that containers/image does not retry itself.
+func TestPullRetriesTransientFailures(t *testing.T) {
+	srv := registrytest.NewServer(t)
+	srv.Push(t, "bpfman/bundle", "v1", registrytest.Image{Labels: map[string]string{"version": "0.6.0"}})
+	srv.InjectFailures("/manifests/", http.StatusBadGateway, 2)
+
+	opts := DefaultOptions()
+	opts.TLSVerify = false
+	opts.Retry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	ctx := WithOptions(context.Background(), opts)
+
+	reg, err := NewImageRegistry(ctx)
+	if err != nil {
+		t.Fatalf("NewImageRegistry: %v", err)
+	}
+	defer reg.Destroy()
+
+	ref := image.SimpleReference(srv.Host() + "/bpfman/bundle:v1")
+	if err := reg.Pull(ctx, ref); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	labels, err := reg.Labels(ctx, ref)
+	if err != nil {
+		t.Fatalf("Labels: %v", err)
+	}
+	if labels["version"] != "0.6.0" {
+		t.Errorf("version label = %q, want %q", labels["version"], "0.6.0")
+	}
+}
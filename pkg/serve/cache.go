@@ -0,0 +1,55 @@
+// Package serve serves a File-Based Catalog over the operator
+// registry gRPC API from operator-registry's cache, as opm serve and
+// catalog pods do, and queries catalogs served that way.
+package serve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing/fstest"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/pkg/cache"
+)
+
+// Cache answers registry queries from operator-registry's cache of
+// a catalog, held in a temporary directory until it is closed.
+type Cache struct {
+	cache.Cache
+	dir string
+}
+
+// LoadCache builds the cache of cfg, which validates it as opm serve
+// does: every channel must have a single head, and every bundle must
+// convert to its API form.
+func LoadCache(ctx context.Context, cfg *declcfg.DeclarativeConfig) (*Cache, error) {
+	var buf bytes.Buffer
+	if err := declcfg.WriteJSON(*cfg, &buf); err != nil {
+		return nil, fmt.Errorf("encoding catalog: %w", err)
+	}
+	fsys := fstest.MapFS{"catalog.json": &fstest.MapFile{Data: buf.Bytes(), Mode: 0644}}
+
+	dir, err := os.MkdirTemp("", "bpfman-catalog-serve-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+	c, err := cache.New(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("creating cache: %w", err)
+	}
+	loaded := &Cache{Cache: c, dir: dir}
+	if err := cache.LoadOrRebuild(ctx, c, fsys); err != nil {
+		loaded.Close()
+		return nil, fmt.Errorf("building cache: %w", err)
+	}
+	return loaded, nil
+}
+
+// Close closes the cache and removes its directory.
+func (c *Cache) Close() error {
+	return errors.Join(c.Cache.Close(), os.RemoveAll(c.dir))
+}
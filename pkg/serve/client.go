@@ -0,0 +1,126 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/operator-framework/operator-registry/pkg/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// ChannelHead is the bundle a served channel installs, as returned by
+// GetBundleForChannel.
+type ChannelHead struct {
+	Package string `json:"package"`
+	Channel string `json:"channel"`
+	Default bool   `json:"default"`
+	Bundle  string `json:"bundle"`
+	Version string `json:"version"`
+	Image   string `json:"image"`
+}
+
+// Replacement is a channel entry that replaces or skips a bundle, as
+// returned by GetChannelEntriesThatReplace.
+type Replacement struct {
+	Package  string `json:"package"`
+	Channel  string `json:"channel"`
+	Bundle   string `json:"bundle"`
+	Replaces string `json:"replaces"`
+}
+
+// Client queries a catalog served over the registry gRPC API, whether
+// by serve, opm serve or a catalog pod.
+type Client struct {
+	conn     *grpc.ClientConn
+	registry api.RegistryClient
+}
+
+// NewClient returns a client of the registry at address, e.g.,
+// localhost:50051. The connection is plaintext, as catalog pods
+// serve.
+func NewClient(address string) (*Client, error) {
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", address, err)
+	}
+	return &Client{conn: conn, registry: api.NewRegistryClient(conn)}, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// ListPackages returns the names of the served packages.
+func (c *Client) ListPackages(ctx context.Context) ([]string, error) {
+	stream, err := c.registry.ListPackages(ctx, &api.ListPackageRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("ListPackages: %w", err)
+	}
+	var names []string
+	for {
+		pkg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return names, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ListPackages: %w", err)
+		}
+		names = append(names, pkg.Name)
+	}
+}
+
+// ChannelHeads returns the bundle each channel of a package installs,
+// reading the channels with GetPackage and each head with
+// GetBundleForChannel.
+func (c *Client) ChannelHeads(ctx context.Context, packageName string) ([]ChannelHead, error) {
+	pkg, err := c.registry.GetPackage(ctx, &api.GetPackageRequest{Name: packageName})
+	if err != nil {
+		return nil, fmt.Errorf("GetPackage %s: %w", packageName, err)
+	}
+
+	var heads []ChannelHead
+	for _, ch := range pkg.Channels {
+		b, err := c.registry.GetBundleForChannel(ctx, &api.GetBundleInChannelRequest{PkgName: packageName, ChannelName: ch.Name})
+		if err != nil {
+			return nil, fmt.Errorf("GetBundleForChannel %s/%s: %w", packageName, ch.Name, err)
+		}
+		heads = append(heads, ChannelHead{
+			Package: packageName,
+			Channel: ch.Name,
+			Default: ch.Name == pkg.DefaultChannelName,
+			Bundle:  b.CsvName,
+			Version: b.Version,
+			Image:   b.BundlePath,
+		})
+	}
+	return heads, nil
+}
+
+// EntriesThatReplace returns the channel entries that replace or skip
+// bundle.
+func (c *Client) EntriesThatReplace(ctx context.Context, bundle string) ([]Replacement, error) {
+	stream, err := c.registry.GetChannelEntriesThatReplace(ctx, &api.GetAllReplacementsRequest{CsvName: bundle})
+	if err != nil {
+		return nil, fmt.Errorf("GetChannelEntriesThatReplace %s: %w", bundle, err)
+	}
+	var entries []Replacement
+	for {
+		entry, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("GetChannelEntriesThatReplace %s: %w", bundle, err)
+		}
+		entries = append(entries, Replacement{
+			Package:  entry.PackageName,
+			Channel:  entry.ChannelName,
+			Bundle:   entry.BundleName,
+			Replaces: entry.Replaces,
+		})
+	}
+}
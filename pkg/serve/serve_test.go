@@ -0,0 +1,148 @@
+package serve
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+const testCatalog = `---
+schema: olm.package
+name: bpfman-operator
+defaultChannel: stable
+---
+schema: olm.channel
+package: bpfman-operator
+name: stable
+entries:
+  - name: bpfman-operator.v0.6.0
+    replaces: bpfman-operator.v0.5.9
+    skips: [bpfman-operator.v0.5.10]
+  - name: bpfman-operator.v0.5.10
+    replaces: bpfman-operator.v0.5.9
+  - name: bpfman-operator.v0.5.9
+---
+schema: olm.channel
+package: bpfman-operator
+name: candidate
+entries:
+  - name: bpfman-operator.v0.6.0
+---
+schema: olm.bundle
+package: bpfman-operator
+name: bpfman-operator.v0.5.9
+image: quay.io/bpfman/bundle@sha256:0509
+properties:
+  - type: olm.package
+    value: {packageName: bpfman-operator, version: 0.5.9}
+---
+schema: olm.bundle
+package: bpfman-operator
+name: bpfman-operator.v0.5.10
+image: quay.io/bpfman/bundle@sha256:0510
+properties:
+  - type: olm.package
+    value: {packageName: bpfman-operator, version: 0.5.10}
+---
+schema: olm.bundle
+package: bpfman-operator
+name: bpfman-operator.v0.6.0
+image: quay.io/bpfman/bundle@sha256:0600
+properties:
+  - type: olm.package
+    value: {packageName: bpfman-operator, version: 0.6.0}
+`
+
+func loadCache(t *testing.T, content string) (*Cache, error) {
+	t.Helper()
+	cfg, err := declcfg.LoadReader(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("LoadReader: %v", err)
+	}
+	c, err := LoadCache(context.Background(), cfg)
+	if err == nil {
+		t.Cleanup(func() {
+			if err := c.Close(); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+		})
+	}
+	return c, err
+}
+
+// TestServe serves a catalog on a local port and queries it over
+// gRPC.
+func TestServe(t *testing.T) {
+	c, err := loadCache(t, testCatalog)
+	if err != nil {
+		t.Fatalf("LoadCache: %v", err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- Serve(ctx, lis, c) }()
+	defer func() {
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	}()
+
+	client, err := NewClient(lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	packages, err := client.ListPackages(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(packages, []string{"bpfman-operator"}) {
+		t.Errorf("packages = %v", packages)
+	}
+
+	heads, err := client.ChannelHeads(ctx, "bpfman-operator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHeads := []ChannelHead{
+		{Package: "bpfman-operator", Channel: "candidate", Bundle: "bpfman-operator.v0.6.0", Version: "0.6.0", Image: "quay.io/bpfman/bundle@sha256:0600"},
+		{Package: "bpfman-operator", Channel: "stable", Default: true, Bundle: "bpfman-operator.v0.6.0", Version: "0.6.0", Image: "quay.io/bpfman/bundle@sha256:0600"},
+	}
+	if !reflect.DeepEqual(heads, wantHeads) {
+		t.Errorf("heads = %+v, want %+v", heads, wantHeads)
+	}
+
+	entries, err := client.EntriesThatReplace(ctx, "bpfman-operator.v0.5.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEntries := []Replacement{{Package: "bpfman-operator", Channel: "stable", Bundle: "bpfman-operator.v0.6.0", Replaces: "bpfman-operator.v0.5.9"}}
+	if !reflect.DeepEqual(entries, wantEntries) {
+		t.Errorf("entries = %+v, want %+v", entries, wantEntries)
+	}
+
+	if _, err := client.EntriesThatReplace(ctx, "bpfman-operator.v0.6.0"); err == nil {
+		t.Error("expected an error for a bundle nothing replaces")
+	}
+	if _, err := client.ChannelHeads(ctx, "missing-operator"); err == nil {
+		t.Error("expected an error for a missing package")
+	}
+}
+
+// TestLoadCacheRejectsInvalidCatalog refuses a channel with two
+// heads, which opm serve would also refuse.
+func TestLoadCacheRejectsInvalidCatalog(t *testing.T) {
+	invalid := strings.Replace(testCatalog, "    skips: [bpfman-operator.v0.5.10]\n", "", 1)
+	if _, err := loadCache(t, invalid); err == nil {
+		t.Error("expected an error for a channel with two heads")
+	}
+}
@@ -0,0 +1,50 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/operator-framework/operator-registry/pkg/api"
+	"github.com/operator-framework/operator-registry/pkg/registry"
+	"github.com/operator-framework/operator-registry/pkg/server"
+	"google.golang.org/grpc"
+	health "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// DefaultAddress is where serve listens by default, on the port
+// catalog pods and opm serve use.
+const DefaultAddress = "localhost:50051"
+
+// NewServer returns a gRPC server exposing q through the registry
+// and health services, with reflection for grpcurl, as opm serve
+// does.
+func NewServer(q registry.GRPCQuery) *grpc.Server {
+	s := grpc.NewServer()
+	api.RegisterRegistryServer(s, server.NewRegistryServer(q))
+	health.RegisterHealthServer(s, server.NewHealthServer())
+	reflection.Register(s)
+	return s
+}
+
+// Serve serves q on lis until ctx is cancelled, then stops
+// gracefully.
+func Serve(ctx context.Context, lis net.Listener, q registry.GRPCQuery) error {
+	s := NewServer(q)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.GracefulStop()
+		case <-done:
+		}
+	}()
+
+	if err := s.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("serving catalog: %w", err)
+	}
+	return nil
+}